@@ -3,11 +3,18 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"rfid-tool-rpi/internal/config"
 	"rfid-tool-rpi/internal/hardware"
@@ -21,6 +28,14 @@ func main() {
 		hwMode     = flag.Bool("hardware", false, "Run in hardware button/LED mode")
 		port       = flag.String("port", "8080", "Web server port")
 		configFile = flag.String("config", "config.json", "Configuration file path")
+		checkKeys  = flag.String("check-keys", "", "Try the keys of a .dic dictionary file against the card and exit")
+		keysOut    = flag.String("keys-out", "", "Save the keys found by -check-keys to this key file")
+		raw        = flag.String("raw", "", "Send space-separated hex frames to the field and exit; append /n to send n bits of the last byte")
+		rawCRC     = flag.Bool("raw-crc", false, "Append CRC_A to whole-byte -raw frames and check it on the answers")
+		rawParity  = flag.Bool("raw-no-parity", false, "Send -raw frames without parity bits")
+		diagnose   = flag.Bool("diagnostics", false, "Run the MFRC522 self-test, dump its registers and exit")
+		calibrate  = flag.Bool("calibrate", false, "Tune antenna gain and receiver threshold with a reference card, save them to the config file and exit")
+		calAttempt = flag.Int("calibrate-attempts", 10, "Attempts per setting for -calibrate")
 	)
 	flag.Parse()
 
@@ -31,13 +46,37 @@ func main() {
 		cfg = config.Default()
 	}
 
-	// Initialize RFID reader
-	rfidReader, err := rfid.NewReader(cfg.RFID)
-	if err != nil {
+	// Initialize RFID reader at the speed that is reliable on this board
+	rfidConfig := cfg.RFID
+	rfidConfig.SPISpeed = cfg.GetOptimizedSPISpeed()
+	rfidReader, err := rfid.NewReader(rfidConfig)
+	switch {
+	case errors.Is(err, rfid.ErrReaderAbsent):
+		// The SPI port is open, so the watchdog can pick the reader up once it is attached
+		log.Printf("Warning: %v", err)
+		log.Println("RFID functionality will be unavailable until the reader answers")
+	case err != nil:
 		log.Printf("Warning: Failed to initialize RFID reader: %v", err)
 		log.Println("Web interface will start but RFID functionality will be unavailable")
 		rfidReader = nil
 	}
+	if rfidReader != nil {
+		rfidReader.SetOperationTimeout(time.Duration(cfg.Performance.OperationTimeoutMs) * time.Millisecond)
+		rfidReader.SetCommandTimeout(time.Duration(cfg.Performance.CommandTimeoutMs) * time.Millisecond)
+		rfidReader.SetIdlePolicy(rfid.IdlePolicy{
+			Timeout:      time.Duration(cfg.Performance.IdleTimeoutMs) * time.Millisecond,
+			PollInterval: time.Duration(cfg.Performance.IdlePollIntervalMs) * time.Millisecond,
+			PowerDown:    cfg.Performance.IdlePowerDown,
+		})
+		if cfg.RFID.SPIAutoTune && err == nil {
+			if _, err := rfidReader.TuneSPISpeed(rfidConfig.SPISpeed, cfg.System.SPIMaxSpeed); err != nil {
+				log.Printf("Warning: SPI link test failed: %v", err)
+			}
+		}
+		if interval := cfg.Performance.WatchdogIntervalMs; interval != nil && *interval > 0 {
+			rfidReader.StartWatchdog(time.Duration(*interval) * time.Millisecond)
+		}
+	}
 	defer func() {
 		if rfidReader != nil {
 			if err := rfidReader.Close(); err != nil {
@@ -50,12 +89,57 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	if *checkKeys != "" {
+		if rfidReader == nil {
+			log.Printf("Cannot check keys: RFID reader initialization failed")
+			return
+		}
+		if err := runKeyCheck(rfidReader, cfg, *checkKeys, *keysOut); err != nil {
+			log.Printf("Key check failed: %v", err)
+		}
+		return
+	}
+
+	if *diagnose {
+		if rfidReader == nil {
+			log.Printf("Cannot run diagnostics: RFID reader initialization failed")
+			return
+		}
+		if err := runDiagnostics(rfidReader); err != nil {
+			log.Printf("Diagnostics failed: %v", err)
+		}
+		return
+	}
+
+	if *calibrate {
+		if rfidReader == nil {
+			log.Printf("Cannot calibrate: RFID reader initialization failed")
+			return
+		}
+		if err := runCalibration(rfidReader, cfg, *configFile, *calAttempt); err != nil {
+			log.Printf("Calibration failed: %v", err)
+		}
+		return
+	}
+
+	if *raw != "" {
+		if rfidReader == nil {
+			log.Printf("Cannot send frames: RFID reader initialization failed")
+			return
+		}
+		if err := runRaw(rfidReader, *raw, *rawCRC, !*rawParity); err != nil {
+			log.Printf("Raw exchange failed: %v", err)
+		}
+		return
+	}
+
 	if *webMode {
 		log.Println("Starting in web interface mode...")
 		if rfidReader == nil {
 			log.Println("Note: RFID functionality will be limited due to hardware initialization failure")
 		}
 		webServer := server.NewWebServer(*port, rfidReader, cfg)
+		webServer.SetConfigFile(*configFile)
 
 		go func() {
 			if err := webServer.Start(); err != nil {
@@ -85,10 +169,13 @@ func main() {
 			}
 		}()
 
-		go hwController.Start()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go hwController.Start(ctx)
 
 		<-sigChan
 		log.Println("Shutting down hardware controller...")
+		cancel()
 	} else {
 		log.Println("Please specify either -web or -hardware mode")
 		flag.Usage()
@@ -97,3 +184,174 @@ func main() {
 
 	log.Println("Application stopped")
 }
+
+// runKeyCheck waits for a card, tries every key of a dictionary against it and prints the keys found
+func runKeyCheck(reader *rfid.Reader, cfg *config.Config, dictionaryFile, keysOut string) error {
+	dictionary, err := rfid.LoadDictionary(dictionaryFile)
+	if err != nil {
+		return fmt.Errorf("failed to load dictionary: %w", err)
+	}
+
+	log.Printf("Loaded %d keys, waiting for a card...", len(dictionary))
+	if err := waitForCard(reader, cfg); err != nil {
+		return err
+	}
+
+	lastSector := -1
+	result, err := reader.CheckKeys(dictionary, func(p rfid.KeyCheckProgress) {
+		if p.Sector != lastSector {
+			lastSector = p.Sector
+			log.Printf("Checking sector %d/%d (%d/%d attempts, %d keys found)", p.Sector+1, p.Sectors, p.Attempts, p.Total, p.Found)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	card := reader.GetLastCard()
+	for sector := 0; sector < card.Sectors(); sector++ {
+		keys := result.Sectors[sector]
+		fmt.Printf("Sector %2d: Key A %-12s Key B %-12s\n", sector, formatKey(keys.KeyA), formatKey(keys.KeyB))
+	}
+	fmt.Printf("%d of %d sectors have a known key (%d attempts)\n", len(result.Sectors), card.Sectors(), result.Attempts)
+
+	if keysOut != "" {
+		// Merge with the configured keys so that existing entries are kept
+		keyring := reader.Keyring()
+		result.Apply(keyring)
+		if err := keyring.Save(keysOut); err != nil {
+			return fmt.Errorf("failed to save key file: %w", err)
+		}
+		log.Printf("Keys saved to %s", keysOut)
+	}
+
+	return nil
+}
+
+// waitForCard polls for a card until the operation timeout
+func waitForCard(reader *rfid.Reader, cfg *config.Config) error {
+	deadline := time.Now().Add(time.Duration(cfg.Performance.OperationTimeoutMs) * time.Millisecond)
+	for !reader.IsCardPresent() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no card detected")
+		}
+		time.Sleep(time.Duration(cfg.Performance.PollingIntervalMs) * time.Millisecond)
+	}
+	return nil
+}
+
+// runCalibration sweeps the antenna settings with a reference card and saves the best one
+func runCalibration(reader *rfid.Reader, cfg *config.Config, configFile string, attempts int) error {
+	log.Println("Place the reference card where cards will be read, waiting for a card...")
+	if err := waitForCard(reader, cfg); err != nil {
+		return err
+	}
+
+	fmt.Println("Gain  MinLevel  REQA  Anticoll  Read")
+	calibration, err := reader.Calibrate(attempts, func(res rfid.CalibrationResult) {
+		fmt.Printf("%2d dB  %8d  %4d  %8d  %4d  of %d\n", res.Tuning.GainDB, res.Tuning.RxMinLevel,
+			res.Requests, res.Anticollisions, res.Reads, res.Attempts)
+	})
+	if err != nil {
+		return err
+	}
+
+	best := calibration.Best
+	fmt.Printf("Best setting: %d dB gain, MinLevel %d\n", best.GainDB, best.RxMinLevel)
+
+	best.Apply(&cfg.RFID)
+	if err := cfg.Save(configFile); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	log.Printf("Tuning saved to %s", configFile)
+	return nil
+}
+
+// runRaw sends frames to the field one after the other and prints the answers
+func runRaw(reader *rfid.Reader, frames string, crc, parity bool) error {
+	return reader.Session(context.Background(), func(s *rfid.Session) error {
+		for _, field := range strings.Fields(frames) {
+			frame, txBits, err := parseRawFrame(field)
+			if err != nil {
+				return err
+			}
+
+			// CRC_A only follows whole bytes, so short frames such as REQA go without
+			response, err := s.Transceive(frame, txBits, 0, crc && txBits == 0, parity)
+			switch {
+			case errors.Is(err, rfid.ErrNoTag):
+				fmt.Printf(">> %s\n<< (no answer)\n", field)
+				continue
+			case err != nil:
+				return fmt.Errorf("frame %s: %w", field, err)
+			}
+
+			fmt.Printf(">> %s\n<< %X (%d bits)", field, response.Data, response.Bits)
+			if response.Collision != 0 {
+				fmt.Printf(" collision at bit %d", response.Collision)
+			}
+			fmt.Println()
+		}
+		return nil
+	})
+}
+
+// parseRawFrame parses a hex frame with an optional /n suffix giving the bits of its last byte
+func parseRawFrame(field string) ([]byte, int, error) {
+	data, bits, found := strings.Cut(field, "/")
+	txBits := 0
+	if found {
+		n, err := strconv.Atoi(bits)
+		if err != nil || n < 1 || n > 8 {
+			return nil, 0, fmt.Errorf("invalid bit count in %q", field)
+		}
+		txBits = n % 8
+	}
+
+	frame, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid hex frame %q", field)
+	}
+	return frame, txBits, nil
+}
+
+// runDiagnostics prints the MFRC522 register dump and self-test result
+func runDiagnostics(reader *rfid.Reader) error {
+	d, err := reader.Diagnostics()
+	if d == nil {
+		return err
+	}
+
+	fmt.Printf("Chip: %s (VersionReg 0x%02X)\n", d.Chip, d.Version)
+	fmt.Printf("SPI clock: %d Hz\n", d.SPISpeed)
+	if d.SPILink != nil {
+		for _, res := range d.SPILink.Results {
+			fmt.Printf("  link test at %8d Hz: %d errors\n", res.Hz, res.Errors)
+		}
+	}
+	fmt.Println()
+	for _, reg := range d.Registers {
+		fmt.Printf("  0x%02X %-18s 0x%02X\n", reg.Address, reg.Name, reg.Value)
+	}
+	fmt.Println()
+
+	switch {
+	case d.SelfTest.Err != "":
+		fmt.Printf("Self-test: did not complete: %s\n", d.SelfTest.Err)
+	case d.SelfTest.Reference == nil:
+		fmt.Printf("Self-test: no reference for this chip version\n  result    %X\n", d.SelfTest.Result)
+	case d.SelfTest.Passed:
+		fmt.Println("Self-test: passed")
+	default:
+		fmt.Printf("Self-test: FAILED\n  result    %X\n  reference %X\n", d.SelfTest.Result, d.SelfTest.Reference)
+	}
+	return err
+}
+
+// formatKey returns a key as hex, or a dash if it was not found
+func formatKey(key []byte) string {
+	if key == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%X", key)
+}
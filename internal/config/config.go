@@ -4,6 +4,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"runtime"
 )
@@ -20,12 +21,23 @@ type Config struct {
 
 // RFIDConfig holds RFID-specific configuration optimized for RPi 2B v1.1
 type RFIDConfig struct {
-	SPIBus     int `json:"spi_bus"`     // SPI bus number (0 for BCM2836)
-	SPIDevice  int `json:"spi_device"`  // SPI device number (0 for CE0)
-	ResetPin   int `json:"reset_pin"`   // GPIO pin for reset (22 recommended for RPi 2B)
-	IRQPin     int `json:"irq_pin"`     // GPIO pin for IRQ (18/24 recommended)
-	SPISpeed   int `json:"spi_speed"`   // SPI speed in Hz (500kHz conservative for BCM2836)
-	RetryCount int `json:"retry_count"` // Number of retries for operations
+	SPIBus         int    `json:"spi_bus"`          // SPI bus number (0 for BCM2836)
+	SPIDevice      int    `json:"spi_device"`       // SPI device number (0 for CE0)
+	ResetPin       int    `json:"reset_pin"`        // GPIO pin for reset (22 recommended for RPi 2B)
+	IRQPin         int    `json:"irq_pin"`          // GPIO pin for IRQ (18/24 recommended)
+	SPISpeed       int    `json:"spi_speed"`        // SPI speed in Hz (500kHz conservative for BCM2836)
+	SPIAutoTune    bool   `json:"spi_auto_tune"`    // Test the SPI link at startup and raise the speed up to System.SPIMaxSpeed
+	RetryCount     int    `json:"retry_count"`      // Number of retries for operations
+	RetryBackoffMs int    `json:"retry_backoff_ms"` // Delay before the first retry, doubled for each further retry
+	KeyFile        string `json:"key_file"`         // JSON keyring with per-sector MIFARE Classic keys (optional)
+	Trace          bool   `json:"trace"`            // Record the frames exchanged with cards from startup
+
+	// Antenna settings; a missing setting keeps the MFRC522 reset value
+	AntennaGainDB *int `json:"antenna_gain_db,omitempty"` // Receiver gain: 18, 23, 33, 38, 43 or 48 dB
+	RxMinLevel    *int `json:"rx_min_level,omitempty"`    // Weakest signal the receiver decodes (0-15)
+	RxCollLevel   *int `json:"rx_coll_level,omitempty"`   // Weakest half-bit counted in a collision (0-7)
+	CWGsN         *int `json:"cw_gsn,omitempty"`          // Antenna n-driver conductance (0-15)
+	CWGsP         *int `json:"cw_gsp,omitempty"`          // Antenna p-driver conductance (0-63)
 }
 
 // HardwareConfig holds hardware interface configuration for RPi 2B v1.1
@@ -58,11 +70,16 @@ type SystemConfig struct {
 
 // PerformanceConfig holds performance tuning parameters for RPi 2B v1.1
 type PerformanceConfig struct {
-	PollingIntervalMs  int `json:"polling_interval_ms"`  // RFID polling interval in ms
-	DebounceDelayMs    int `json:"debounce_delay_ms"`    // Button debounce delay in ms
-	LEDFadeTimeMs      int `json:"led_fade_time_ms"`     // LED fade transition time
-	OperationTimeoutMs int `json:"operation_timeout_ms"` // Operation timeout in ms
-	WebRefreshRateMs   int `json:"web_refresh_rate_ms"`  // Web interface refresh rate
+	PollingIntervalMs  int  `json:"polling_interval_ms"`            // RFID polling interval in ms
+	DebounceDelayMs    int  `json:"debounce_delay_ms"`              // Button debounce delay in ms
+	LEDFadeTimeMs      int  `json:"led_fade_time_ms"`               // LED fade transition time
+	OperationTimeoutMs int  `json:"operation_timeout_ms"`           // Deadline of a whole scan, read, write or card dump in ms
+	CommandTimeoutMs   int  `json:"command_timeout_ms"`             // Time a single MFRC522 command may take in ms
+	WebRefreshRateMs   int  `json:"web_refresh_rate_ms"`            // Web interface refresh rate
+	IdleTimeoutMs      int  `json:"idle_timeout_ms"`                // Switch the antenna off after this long without a card, 0 to keep it on
+	IdlePollIntervalMs int  `json:"idle_poll_interval_ms"`          // Interval between card polls while idle
+	IdlePowerDown      bool `json:"idle_power_down"`                // Also put the MFRC522 into soft power-down while idle
+	WatchdogIntervalMs *int `json:"watchdog_interval_ms,omitempty"` // Reader health check interval, 0 to disable the watchdog; missing keeps the default
 }
 
 // CompatibilityConfig holds compatibility information
@@ -75,14 +92,17 @@ type CompatibilityConfig struct {
 
 // Default returns a default configuration optimized for Raspberry Pi 2B v1.1
 func Default() *Config {
+	watchdogIntervalMs := 5000 // Check the reader every 5 seconds
 	return &Config{
 		RFID: RFIDConfig{
-			SPIBus:     0,      // SPI0 on BCM2836
-			SPIDevice:  0,      // CE0 (GPIO8)
-			ResetPin:   22,     // GPIO22 - good for reset signal
-			IRQPin:     18,     // GPIO24 - interrupt pin (optional)
-			SPISpeed:   500000, // 500kHz - conservative for reliable operation
-			RetryCount: 3,      // 3 retries for operations
+			SPIBus:         0,      // SPI0 on BCM2836
+			SPIDevice:      0,      // CE0 (GPIO8)
+			ResetPin:       22,     // GPIO22 - good for reset signal
+			IRQPin:         18,     // GPIO24 - interrupt pin (optional)
+			SPISpeed:       500000, // 500kHz - conservative for reliable operation
+			SPIAutoTune:    true,   // Raise the speed if the link test allows
+			RetryCount:     3,      // 3 retries for operations
+			RetryBackoffMs: 10,     // 10ms, 20ms, 40ms between retries
 		},
 		Hardware: HardwareConfig{
 			ReadButton:  2,  // GPIO2 (I2C1_SDA) - has internal pull-up
@@ -111,7 +131,10 @@ func Default() *Config {
 			DebounceDelayMs:    50,   // 50ms button debounce
 			LEDFadeTimeMs:      250,  // 250ms LED transitions
 			OperationTimeoutMs: 5000, // 5 second operation timeout
+			CommandTimeoutMs:   100,  // A command takes a few ms; longer means a stuck chip
 			WebRefreshRateMs:   1000, // 1Hz web refresh rate
+			IdlePollIntervalMs: 1000, // 1Hz card polling while idle
+			WatchdogIntervalMs: &watchdogIntervalMs,
 		},
 		Compatibility: CompatibilityConfig{
 			RaspberryPiModels: []string{
@@ -175,6 +198,9 @@ func DefaultLowPower() *Config {
 	config.Performance.DebounceDelayMs = 100   // Longer debounce
 	config.Performance.LEDFadeTimeMs = 500     // Slower LED transitions
 	config.Performance.WebRefreshRateMs = 2000 // 0.5Hz web refresh
+	config.Performance.IdleTimeoutMs = 30000   // Antenna off after 30s without a card
+	config.Performance.IdlePollIntervalMs = 2000
+	config.Performance.IdlePowerDown = true
 
 	return config
 }
@@ -193,6 +219,9 @@ func Load(filename string) (*Config, error) {
 
 	// Validate configuration for RPi 2B v1.1
 	config.validateAndAdjust()
+	if err := config.validateTuningParams(); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
@@ -213,6 +242,7 @@ func (c *Config) validateAndAdjust() {
 	c.validateSPISpeed()
 	c.validateGPIOPins()
 	c.validatePerformanceParams()
+	c.validateRetryParams()
 }
 
 // validateSPISpeed validates SPI speed limits for BCM2836
@@ -253,10 +283,14 @@ func (c *Config) validateGPIOPin(pin *int, defaultValue int) {
 // validatePerformanceParams validates performance parameters
 func (c *Config) validatePerformanceParams() {
 	const (
-		minPollingInterval = 10   // Minimum 10ms (100Hz max)
-		maxPollingInterval = 5000 // Maximum 5s
-		minDebounceDelay   = 5    // Minimum 5ms
-		maxDebounceDelay   = 1000 // Maximum 1s
+		minPollingInterval      = 10   // Minimum 10ms (100Hz max)
+		maxPollingInterval      = 5000 // Maximum 5s
+		minDebounceDelay        = 5    // Minimum 5ms
+		maxDebounceDelay        = 1000 // Maximum 1s
+		minCommandTimeout       = 10   // Minimum 10ms, above the card response timer
+		defaultCommandTimeout   = 100
+		defaultIdlePollInterval = 1000
+		defaultWatchdogInterval = 5000
 	)
 
 	if c.Performance.PollingIntervalMs < minPollingInterval {
@@ -272,6 +306,87 @@ func (c *Config) validatePerformanceParams() {
 	if c.Performance.DebounceDelayMs > maxDebounceDelay {
 		c.Performance.DebounceDelayMs = maxDebounceDelay
 	}
+
+	// Configurations from before the setting existed leave it out
+	if c.Performance.CommandTimeoutMs == 0 {
+		c.Performance.CommandTimeoutMs = defaultCommandTimeout
+	}
+	if c.Performance.CommandTimeoutMs < minCommandTimeout {
+		c.Performance.CommandTimeoutMs = minCommandTimeout
+	}
+
+	if c.Performance.IdleTimeoutMs < 0 {
+		c.Performance.IdleTimeoutMs = 0
+	}
+	// A missing idle poll interval must not turn into the fastest polling
+	if c.Performance.IdlePollIntervalMs == 0 {
+		c.Performance.IdlePollIntervalMs = defaultIdlePollInterval
+	}
+	if c.Performance.IdlePollIntervalMs < minPollingInterval {
+		c.Performance.IdlePollIntervalMs = minPollingInterval
+	}
+
+	// Configurations from before the watchdog existed leave it out; only an explicit 0 disables it
+	if c.Performance.WatchdogIntervalMs == nil {
+		interval := defaultWatchdogInterval
+		c.Performance.WatchdogIntervalMs = &interval
+	}
+	if *c.Performance.WatchdogIntervalMs < 0 {
+		*c.Performance.WatchdogIntervalMs = 0
+	}
+}
+
+// validateRetryParams keeps retries short enough not to stall the reader
+func (c *Config) validateRetryParams() {
+	const (
+		maxRetryCount   = 10   // Retries beyond this only hide a wiring problem
+		maxRetryBackoff = 1000 // Maximum 1s before the first retry
+	)
+
+	if c.RFID.RetryCount < 0 {
+		c.RFID.RetryCount = 0
+	}
+	if c.RFID.RetryCount > maxRetryCount {
+		c.RFID.RetryCount = maxRetryCount
+	}
+
+	if c.RFID.RetryBackoffMs < 0 {
+		c.RFID.RetryBackoffMs = 0
+	}
+	if c.RFID.RetryBackoffMs > maxRetryBackoff {
+		c.RFID.RetryBackoffMs = maxRetryBackoff
+	}
+}
+
+// validateTuningParams checks that the antenna settings fit their register fields. Unlike
+// the other settings they are not adjusted: every value in range is a valid setting, so a
+// replacement would silently tune the antenna differently.
+func (c *Config) validateTuningParams() error {
+	if gain := c.RFID.AntennaGainDB; gain != nil {
+		switch *gain {
+		case 18, 23, 33, 38, 43, 48:
+		default:
+			return fmt.Errorf("antenna_gain_db must be 18, 23, 33, 38, 43 or 48, not %d", *gain)
+		}
+	}
+	if err := validateRange("rx_min_level", c.RFID.RxMinLevel, 15); err != nil {
+		return err
+	}
+	if err := validateRange("rx_coll_level", c.RFID.RxCollLevel, 7); err != nil {
+		return err
+	}
+	if err := validateRange("cw_gsn", c.RFID.CWGsN, 15); err != nil {
+		return err
+	}
+	return validateRange("cw_gsp", c.RFID.CWGsP, 63)
+}
+
+// validateRange checks that a register field value, if set, lies between 0 and maxValue
+func validateRange(name string, value *int, maxValue int) error {
+	if value != nil && (*value < 0 || *value > maxValue) {
+		return fmt.Errorf("%s must be between 0 and %d, not %d", name, maxValue, *value)
+	}
+	return nil
 }
 
 // GetOptimizedSPISpeed returns the optimal SPI speed based on system capabilities
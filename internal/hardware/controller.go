@@ -3,6 +3,8 @@
 package hardware
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -27,6 +29,7 @@ type Controller struct {
 	cardData    []byte
 	config      config.HardwareConfig
 	running     bool
+	health      rfid.Health
 }
 
 // NewController creates a new hardware controller
@@ -107,10 +110,12 @@ func (c *Controller) initGPIO() error {
 	return nil
 }
 
-// Start starts the hardware controller
-func (c *Controller) Start() {
+// Start starts the hardware controller. It runs until Stop is called or ctx is done;
+// cancelling ctx also aborts a card operation in progress.
+func (c *Controller) Start(ctx context.Context) {
 	c.running = true
-	_ = c.readyLED.Out(gpio.High) // Show ready state
+	c.health = c.reader.Health()
+	c.showReady() // Show ready state
 
 	log.Println("Hardware controller started")
 	log.Println("Press the read button to scan and read card")
@@ -123,9 +128,15 @@ func (c *Controller) Start() {
 			c.running = false
 			return
 
+		case <-ctx.Done():
+			c.running = false
+			return
+
 		default:
+			c.checkHealth()
+
 			// Check button presses
-			c.checkButtons()
+			c.checkButtons(ctx)
 			const pollingDelay = 50 * time.Millisecond
 			time.Sleep(pollingDelay) // Small delay to prevent busy waiting
 		}
@@ -157,18 +168,18 @@ func (c *Controller) Close() error {
 }
 
 // checkButtons checks for button presses and handles them
-func (c *Controller) checkButtons() {
+func (c *Controller) checkButtons(ctx context.Context) {
 	// Check read button
 	if c.readButton.Read() == gpio.Low {
 		// Button pressed (active low with pull-up)
-		c.handleReadButton()
+		c.handleReadButton(ctx)
 		c.waitForButtonRelease(c.readButton)
 	}
 
 	// Check write button
 	if c.writeButton.Read() == gpio.Low {
 		// Button pressed (active low with pull-up)
-		c.handleWriteButton()
+		c.handleWriteButton(ctx)
 		c.waitForButtonRelease(c.writeButton)
 	}
 }
@@ -184,27 +195,27 @@ func (c *Controller) waitForButtonRelease(button gpio.PinIO) {
 }
 
 // handleReadButton handles read button press
-func (c *Controller) handleReadButton() {
+func (c *Controller) handleReadButton(ctx context.Context) {
 	log.Println("Read button pressed")
 
 	c.setLEDState(false, false, false) // Turn off all LEDs
 	_ = c.statusLED.Out(gpio.High)     // Show scanning status
 
 	// Scan for card
-	card, err := c.reader.ScanForCard()
+	card, err := c.reader.ScanForCardContext(ctx)
 	if err != nil {
 		log.Printf("Failed to scan card: %v", err)
-		c.showError("Failed to scan card")
+		c.showError("Failed to scan card", err)
 		return
 	}
 
 	log.Printf("Card detected: %s", card.String())
 
 	// Read all data from card
-	data, err := c.reader.ReadCard()
+	data, err := c.reader.ReadCardContext(ctx)
 	if err != nil {
 		log.Printf("Failed to read card data: %v", err)
-		c.showError("Failed to read card")
+		c.showError("Failed to read card", err)
 		return
 	}
 
@@ -237,12 +248,12 @@ func (c *Controller) handleReadButton() {
 }
 
 // handleWriteButton handles write button press
-func (c *Controller) handleWriteButton() {
+func (c *Controller) handleWriteButton(ctx context.Context) {
 	log.Println("Write button pressed")
 
 	if c.cardData == nil {
 		log.Println("No data to write. Please read a card first.")
-		c.showError("No data to write")
+		c.showError("No data to write", nil)
 		return
 	}
 
@@ -250,19 +261,19 @@ func (c *Controller) handleWriteButton() {
 	_ = c.statusLED.Out(gpio.High)     // Show writing status
 
 	// Scan for card
-	card, err := c.reader.ScanForCard()
+	card, err := c.reader.ScanForCardContext(ctx)
 	if err != nil {
 		log.Printf("Failed to scan card: %v", err)
-		c.showError("Failed to scan card")
+		c.showError("Failed to scan card", err)
 		return
 	}
 
 	log.Printf("Writing to card: %s", card.String())
 
 	// Write data to block 1 (block 0 is usually read-only)
-	if writeErr := c.reader.WriteBlock(1, c.cardData); writeErr != nil {
-		log.Printf("Failed to write to card: %v", err)
-		c.showError("Failed to write card")
+	if writeErr := c.reader.WriteBlockContext(ctx, 1, c.cardData); writeErr != nil {
+		log.Printf("Failed to write to card: %v", writeErr)
+		c.showError("Failed to write card", writeErr)
 		return
 	}
 
@@ -270,7 +281,7 @@ func (c *Controller) handleWriteButton() {
 	c.showSuccess("Card written successfully")
 
 	// Verify the write by reading it back
-	readData, err := c.reader.ReadBlock(1)
+	readData, err := c.reader.ReadBlockContext(ctx, 1)
 	if err != nil {
 		log.Printf("Warning: Could not verify write: %v", err)
 	} else {
@@ -296,6 +307,31 @@ func (c *Controller) handleWriteButton() {
 	}
 }
 
+// checkHealth shows a change in reader health on the LEDs
+func (c *Controller) checkHealth() {
+	health := c.reader.Health()
+	if health == c.health {
+		return
+	}
+
+	log.Printf("Reader health: %s", health)
+	c.health = health
+	c.showReady()
+}
+
+// showReady shows the idle state for the current reader health: ready LED for a working
+// reader, error LED for a missing one, and both for a reader that answers unreliably
+func (c *Controller) showReady() {
+	switch c.reader.Health() {
+	case rfid.HealthAbsent:
+		c.setLEDState(false, false, true)
+	case rfid.HealthDegraded:
+		c.setLEDState(true, false, true)
+	default:
+		c.setLEDState(true, false, false)
+	}
+}
+
 // setLEDState sets the state of all LEDs
 func (c *Controller) setLEDState(ready, status, errorState bool) {
 	if ready {
@@ -317,21 +353,50 @@ func (c *Controller) setLEDState(ready, status, errorState bool) {
 	}
 }
 
-// showError shows an error indication
-func (c *Controller) showError(message string) {
+// blinkPattern describes how the error LED flashes for a class of errors
+type blinkPattern struct {
+	blinks   int
+	interval time.Duration
+}
+
+// defaultBlinkPattern is used for errors outside the reader error taxonomy
+var defaultBlinkPattern = blinkPattern{6, 200 * time.Millisecond}
+
+// errorBlinkPattern returns the LED pattern for an error, so that the cause can be told
+// apart without a console: slow blinks for a missing card, three for a refused key or
+// command, rapid flicker for a disturbed transmission and a long glow for a reader fault
+func errorBlinkPattern(err error) blinkPattern {
+	switch {
+	case errors.Is(err, rfid.ErrNoTag):
+		return blinkPattern{2, 500 * time.Millisecond}
+	case errors.Is(err, rfid.ErrAuthDenied), errors.Is(err, rfid.ErrNAK):
+		return blinkPattern{3, 300 * time.Millisecond}
+	case errors.Is(err, rfid.ErrCRC), errors.Is(err, rfid.ErrParity),
+		errors.Is(err, rfid.ErrProtocol), errors.Is(err, rfid.ErrCollision):
+		return blinkPattern{12, 80 * time.Millisecond}
+	case errors.Is(err, rfid.ErrTimeout), errors.Is(err, rfid.ErrBufferOverflow),
+		errors.Is(err, context.DeadlineExceeded):
+		return blinkPattern{1, 1500 * time.Millisecond}
+	default:
+		return defaultBlinkPattern
+	}
+}
+
+// showError shows an error indication whose blink pattern depends on err, which may be nil
+func (c *Controller) showError(message string, err error) {
 	log.Printf("Error: %s", message)
 	c.setLEDState(false, false, true) // Only error LED on
 
 	// Blink error LED for emphasis
+	pattern := errorBlinkPattern(err)
 	go func() {
-		for i := 0; i < 6; i++ {
+		for i := 0; i < pattern.blinks; i++ {
 			_ = c.errorLED.Out(gpio.Low)
-			const blinkInterval = 200 * time.Millisecond
-			time.Sleep(blinkInterval)
+			time.Sleep(pattern.interval)
 			_ = c.errorLED.Out(gpio.High)
-			time.Sleep(blinkInterval)
+			time.Sleep(pattern.interval)
 		}
-		c.setLEDState(true, false, false) // Back to ready state
+		c.showReady() // Back to ready state
 	}()
 }
 
@@ -343,7 +408,7 @@ func (c *Controller) showSuccess(message string) {
 	// Keep status LED on for 2 seconds, then back to ready
 	go func() {
 		time.Sleep(2 * time.Second)
-		c.setLEDState(true, false, false) // Back to ready state
+		c.showReady() // Back to ready state
 	}()
 }
 
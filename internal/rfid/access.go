@@ -0,0 +1,307 @@
+package rfid
+
+import "fmt"
+
+// Permission is the set of keys allowed to perform an operation on a MIFARE Classic block
+type Permission byte
+
+// Permissions
+const (
+	PermissionNever Permission = 0x00
+	PermissionKeyA  Permission = 0x01
+	PermissionKeyB  Permission = 0x02
+	PermissionKeyAB            = PermissionKeyA | PermissionKeyB
+)
+
+// Allows reports whether keyType is in the set
+func (p Permission) Allows(keyType KeyType) bool {
+	switch keyType {
+	case KeyA:
+		return p&PermissionKeyA != 0
+	case KeyB:
+		return p&PermissionKeyB != 0
+	}
+	return false
+}
+
+// String returns a string representation of the permission
+func (p Permission) String() string {
+	switch p {
+	case PermissionNever:
+		return "never"
+	case PermissionKeyA:
+		return "Key A"
+	case PermissionKeyB:
+		return "Key B"
+	case PermissionKeyAB:
+		return "Key A|B"
+	default:
+		return "invalid"
+	}
+}
+
+// DataBlockPermissions lists who may access a data block.
+// Decrement also covers the transfer and restore commands.
+type DataBlockPermissions struct {
+	Read      Permission
+	Write     Permission
+	Increment Permission
+	Decrement Permission
+}
+
+// String returns a human-readable description of the permissions
+func (p DataBlockPermissions) String() string {
+	return fmt.Sprintf("read %s, write %s, increment %s, decrement %s", p.Read, p.Write, p.Increment, p.Decrement)
+}
+
+// TrailerPermissions lists who may read and write each part of a sector trailer.
+// Key A can never be read back.
+type TrailerPermissions struct {
+	WriteKeyA       Permission
+	ReadAccessBits  Permission
+	WriteAccessBits Permission
+	ReadKeyB        Permission
+	WriteKeyB       Permission
+}
+
+// String returns a human-readable description of the permissions
+func (p TrailerPermissions) String() string {
+	return fmt.Sprintf("Key A write %s; access bits read %s, write %s; Key B read %s, write %s",
+		p.WriteKeyA, p.ReadAccessBits, p.WriteAccessBits, p.ReadKeyB, p.WriteKeyB)
+}
+
+// Shorthands for the condition tables
+const (
+	never = PermissionNever
+	keyA  = PermissionKeyA
+	keyB  = PermissionKeyB
+	keyAB = PermissionKeyAB
+)
+
+// dataBlockConditions lists the data block permissions for each C1C2C3 condition
+var dataBlockConditions = [8]DataBlockPermissions{
+	0x0: {keyAB, keyAB, keyAB, keyAB}, // Transport configuration
+	0x1: {keyAB, never, never, keyAB}, // Value block, decrement only
+	0x2: {keyAB, never, never, never}, // Read only
+	0x3: {keyB, keyB, never, never},
+	0x4: {keyAB, keyB, never, never},
+	0x5: {keyB, never, never, never},
+	0x6: {keyAB, keyB, keyB, keyAB}, // Value block
+	0x7: {never, never, never, never},
+}
+
+// trailerConditions lists the sector trailer permissions for each C1C2C3 condition
+var trailerConditions = [8]TrailerPermissions{
+	0x0: {keyA, keyA, never, keyA, keyA},
+	0x1: {keyA, keyA, keyA, keyA, keyA}, // Transport configuration
+	0x2: {never, keyA, never, keyA, never},
+	0x3: {keyB, keyAB, keyB, never, keyB},
+	0x4: {keyB, keyAB, never, never, keyB},
+	0x5: {never, keyAB, keyB, never, never},
+	0x6: {never, keyAB, never, never, never},
+	0x7: {never, keyAB, never, never, never},
+}
+
+// AccessCondition is the C1 C2 C3 access condition of one block group, stored as C1<<2 | C2<<1 | C3
+type AccessCondition byte
+
+// String returns the condition as its three bits
+func (c AccessCondition) String() string {
+	return fmt.Sprintf("%d%d%d", c>>2&1, c>>1&1, c&1)
+}
+
+// DataBlock returns the permissions the condition grants on a data block
+func (c AccessCondition) DataBlock() DataBlockPermissions {
+	return dataBlockConditions[c&0x07]
+}
+
+// Trailer returns the permissions the condition grants on a sector trailer
+func (c AccessCondition) Trailer() TrailerPermissions {
+	return trailerConditions[c&0x07]
+}
+
+// DataBlockCondition returns the condition granting exactly the given data block permissions
+func DataBlockCondition(p DataBlockPermissions) (AccessCondition, error) {
+	for c, permissions := range dataBlockConditions {
+		if permissions == p {
+			return AccessCondition(c), nil
+		}
+	}
+	return 0, fmt.Errorf("no access condition grants %s", p)
+}
+
+// TrailerCondition returns the condition granting exactly the given trailer permissions
+func TrailerCondition(p TrailerPermissions) (AccessCondition, error) {
+	for c, permissions := range trailerConditions {
+		if permissions == p {
+			return AccessCondition(c), nil
+		}
+	}
+	return 0, fmt.Errorf("no access condition grants %s", p)
+}
+
+// AccessBits holds the access conditions of a sector: groups 0-2 are the data blocks
+// and group 3 is the sector trailer. In the 16-block sectors of a 4K card each data
+// group covers five blocks.
+type AccessBits [4]AccessCondition
+
+// TransportAccessBits are the access bits of blank cards (FF 07 80): data blocks are open
+// to Key A and Key B, and Key A may change the whole trailer
+var TransportAccessBits = AccessBits{0x0, 0x0, 0x0, 0x1}
+
+// DecodeAccessBits extracts the access conditions from bytes 6-8 of a sector trailer and
+// checks them against their inverted copies
+func DecodeAccessBits(trailer []byte) (AccessBits, error) {
+	var bits AccessBits
+	if len(trailer) < 9 {
+		return bits, fmt.Errorf("sector trailer too short")
+	}
+
+	c1 := trailer[7] >> 4
+	c2 := trailer[8] & 0x0F
+	c3 := trailer[8] >> 4
+	if trailer[6]&0x0F != ^c1&0x0F || trailer[6]>>4 != ^c2&0x0F || trailer[7]&0x0F != ^c3&0x0F {
+		return bits, fmt.Errorf("access bits %02x%02x%02x fail the inverted-bit check", trailer[6], trailer[7], trailer[8])
+	}
+
+	for group := range bits {
+		bits[group] = AccessCondition((c1>>group&1)<<2 | (c2>>group&1)<<1 | c3>>group&1)
+	}
+	return bits, nil
+}
+
+// Bytes encodes the access bits as bytes 6-8 of a sector trailer
+func (a AccessBits) Bytes() [3]byte {
+	var c1, c2, c3 byte
+	for group, c := range a {
+		c1 |= byte(c>>2&1) << group
+		c2 |= byte(c>>1&1) << group
+		c3 |= byte(c&1) << group
+	}
+	return [3]byte{^c2<<4 | ^c1&0x0F, c1<<4 | ^c3&0x0F, c3<<4 | c2}
+}
+
+// KeyBReadable reports whether Key B can be read from the trailer, in which case it is
+// plain data and grants no access to the data blocks
+func (a AccessBits) KeyBReadable() bool {
+	return a[3].Trailer().ReadKeyB != PermissionNever
+}
+
+// DescribeBlock returns the permissions of a block as human-readable text
+func (a AccessBits) DescribeBlock(block int) string {
+	group := accessGroupOfBlock(block)
+	if group == 3 {
+		return a[3].Trailer().String()
+	}
+
+	p := a[group].DataBlock()
+	if a.KeyBReadable() && (p.Read|p.Write|p.Increment|p.Decrement)&PermissionKeyB != 0 {
+		return p.String() + " (Key B is readable and grants no access)"
+	}
+	return p.String()
+}
+
+// String returns the access bits as the hex bytes stored in the trailer
+func (a AccessBits) String() string {
+	b := a.Bytes()
+	return fmt.Sprintf("%02X%02X%02X", b[0], b[1], b[2])
+}
+
+// EncodeTrailer builds a 16-byte sector trailer from both keys, the access bits and the
+// general purpose byte
+func EncodeTrailer(keyA []byte, access AccessBits, gpb byte, keyB []byte) ([]byte, error) {
+	if len(keyA) != KeySize || len(keyB) != KeySize {
+		return nil, fmt.Errorf("key must be exactly %d bytes", KeySize)
+	}
+	for _, c := range access {
+		if c > 0x07 {
+			return nil, fmt.Errorf("invalid access condition %d", c)
+		}
+	}
+
+	bits := access.Bytes()
+	trailer := make([]byte, 0, 16)
+	trailer = append(trailer, keyA...)
+	trailer = append(trailer, bits[0], bits[1], bits[2], gpb)
+	return append(trailer, keyB...), nil
+}
+
+// SectorAccess returns the access bits of a sector if they have been read from the card
+func (c *Card) SectorAccess(sector int) (AccessBits, bool) {
+	bits, ok := c.access[sector]
+	return bits, ok
+}
+
+// accessOp is an operation guarded by access conditions
+type accessOp int
+
+const (
+	accessRead accessOp = iota
+	accessWrite
+	accessIncrement
+	accessDecrement
+)
+
+// String returns a string representation of the operation
+func (op accessOp) String() string {
+	switch op {
+	case accessRead:
+		return "read"
+	case accessWrite:
+		return "write"
+	case accessIncrement:
+		return "increment"
+	case accessDecrement:
+		return "decrement"
+	default:
+		return "unknown"
+	}
+}
+
+// accessGroupOfBlock returns the access condition group of a block within its sector
+func accessGroupOfBlock(block int) int {
+	sector := sectorOfBlock(block)
+	offset := block - firstBlockOfSector(sector)
+	if offset == blocksInSector(sector)-1 {
+		return 3
+	}
+	if blocksInSector(sector) == smallSectorBlocks {
+		return offset
+	}
+	return offset / 5
+}
+
+// permittedKeys returns the keys allowed to perform op on block.
+// Writing a trailer is permitted if any of its parts may be written.
+func (a AccessBits) permittedKeys(block int, op accessOp) Permission {
+	group := accessGroupOfBlock(block)
+	if group == 3 {
+		trailer := a[3].Trailer()
+		switch op {
+		case accessRead:
+			return trailer.ReadAccessBits
+		case accessWrite:
+			return trailer.WriteKeyA | trailer.WriteAccessBits | trailer.WriteKeyB
+		default:
+			return PermissionNever
+		}
+	}
+
+	data := a[group].DataBlock()
+	var keys Permission
+	switch op {
+	case accessRead:
+		keys = data.Read
+	case accessWrite:
+		keys = data.Write
+	case accessIncrement:
+		keys = data.Increment
+	case accessDecrement:
+		keys = data.Decrement
+	}
+
+	if a.KeyBReadable() {
+		keys &^= PermissionKeyB
+	}
+	return keys
+}
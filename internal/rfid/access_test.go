@@ -0,0 +1,142 @@
+package rfid
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAccessBits(t *testing.T) {
+	bits, err := DecodeAccessBits([]byte{0, 0, 0, 0, 0, 0, 0xFF, 0x07, 0x80, 0x69})
+	if err != nil {
+		t.Fatalf("DecodeAccessBits() error = %v", err)
+	}
+	if bits != TransportAccessBits {
+		t.Errorf("DecodeAccessBits(FF0780) = %v, want %v", bits, TransportAccessBits)
+	}
+	if !bits.KeyBReadable() {
+		t.Error("KeyBReadable() = false for the transport configuration")
+	}
+
+	bits, err = DecodeAccessBits(testTrailer())
+	if err != nil {
+		t.Fatalf("DecodeAccessBits() error = %v", err)
+	}
+	if got := bits[0].DataBlock(); got.Read != PermissionKeyAB || got.Write != PermissionKeyB {
+		t.Errorf("DecodeAccessBits(787788) data permissions = %s", got)
+	}
+	if bits.KeyBReadable() {
+		t.Error("KeyBReadable() = true for 787788")
+	}
+
+	if _, err := DecodeAccessBits([]byte{0, 0, 0, 0, 0, 0, 0xFF, 0x07, 0x00}); err == nil {
+		t.Error("DecodeAccessBits() accepted bits failing the inverted-bit check")
+	}
+}
+
+func TestAccessBitsRoundTrip(t *testing.T) {
+	for i := 0; i < 8*8*8*8; i++ {
+		bits := AccessBits{AccessCondition(i & 7), AccessCondition(i >> 3 & 7), AccessCondition(i >> 6 & 7), AccessCondition(i >> 9 & 7)}
+		encoded := bits.Bytes()
+
+		decoded, err := DecodeAccessBits(append(make([]byte, 6), encoded[:]...))
+		if err != nil {
+			t.Fatalf("DecodeAccessBits(%v) error = %v", bits, err)
+		}
+		if decoded != bits {
+			t.Fatalf("round trip of %v gave %v", bits, decoded)
+		}
+	}
+}
+
+func TestEncodePermissions(t *testing.T) {
+	data, err := DataBlockCondition(DataBlockPermissions{
+		Read:      PermissionKeyAB,
+		Write:     PermissionKeyB,
+		Increment: PermissionNever,
+		Decrement: PermissionNever,
+	})
+	if err != nil {
+		t.Fatalf("DataBlockCondition() error = %v", err)
+	}
+	trailer, err := TrailerCondition(TrailerPermissions{
+		WriteKeyA:       PermissionKeyB,
+		ReadAccessBits:  PermissionKeyAB,
+		WriteAccessBits: PermissionKeyB,
+		ReadKeyB:        PermissionNever,
+		WriteKeyB:       PermissionKeyB,
+	})
+	if err != nil {
+		t.Fatalf("TrailerCondition() error = %v", err)
+	}
+
+	got, err := EncodeTrailer(testKeyA, AccessBits{data, data, data, trailer}, 0x69, testKeyB)
+	if err != nil {
+		t.Fatalf("EncodeTrailer() error = %v", err)
+	}
+	if !bytes.Equal(got, testTrailer()) {
+		t.Errorf("EncodeTrailer() = %x, want %x", got, testTrailer())
+	}
+
+	if _, err := DataBlockCondition(DataBlockPermissions{Read: PermissionKeyA}); err == nil {
+		t.Error("DataBlockCondition() accepted permissions no condition grants")
+	}
+}
+
+func TestDescribeBlock(t *testing.T) {
+	// In 16-block sectors each data group covers five blocks
+	bits := AccessBits{0x0, 0x2, 0x7, 0x1}
+	tests := []struct {
+		block int
+		want  string
+	}{
+		{128, "read Key A|B, write Key A|B"},
+		{133, "read Key A|B, write never"},
+		{138, "read never"},
+		{143, "Key A write Key A; access bits read Key A, write Key A"},
+	}
+
+	for _, tt := range tests {
+		if got := bits.DescribeBlock(tt.block); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("DescribeBlock(%d) = %q, want prefix %q", tt.block, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTrailer(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0x31, 0x32, 0x33, 0x34})
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	bricking := testTrailer()
+	bricking[8] ^= 0x01
+	if err := reader.WriteBlock(7, bricking); err == nil {
+		t.Error("WriteBlock() wrote a trailer failing the inverted-bit check")
+	}
+	if !bytes.Equal(card.Block(7)[6:9], []byte{0xFF, 0x07, 0x80}) {
+		t.Errorf("trailer 7 changed to %x", card.Block(7))
+	}
+
+	if err := reader.WriteBlock(7, testTrailer()); err != nil {
+		t.Fatalf("WriteBlock() error = %v", err)
+	}
+	if !bytes.Equal(card.Block(7), testTrailer()) {
+		t.Errorf("trailer 7 = %x, want %x", card.Block(7), testTrailer())
+	}
+
+	// The new keys apply from now on
+	_ = reader.SetCardKey(1, KeyA, testKeyA)
+	_ = reader.SetCardKey(1, KeyB, testKeyB)
+	reader.StopCrypto()
+
+	if err := reader.WriteBlock(5, bytes.Repeat([]byte{0x55}, 16)); err != nil {
+		t.Fatalf("WriteBlock() with the new Key B error = %v", err)
+	}
+	bits, ok := reader.GetLastCard().SectorAccess(1)
+	if !ok || bits[0] != 0x4 {
+		t.Errorf("SectorAccess(1) = %v, %v", bits, ok)
+	}
+}
@@ -0,0 +1,192 @@
+package rfid
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// calibrationMinLevels are the receiver thresholds tried at each gain
+var calibrationMinLevels = []int{2, 4, 6, 8, 10, 12}
+
+// fieldResetDelay is how long the field stays off, and then on before the first command,
+// so that the card loses power and starts again in IDLE
+const fieldResetDelay = 5 * time.Millisecond
+
+// CalibrationResult counts the successful steps at one setting
+type CalibrationResult struct {
+	Tuning         Tuning
+	Attempts       int
+	Requests       int // REQA answered with an ATQA
+	Anticollisions int // Anticollision and SELECT returned the card's UID
+	Reads          int // READ of block 0 returned data
+}
+
+// Rate returns the share of attempts in which every step succeeded
+func (res CalibrationResult) Rate() float64 {
+	if res.Attempts == 0 {
+		return 0
+	}
+	return float64(res.Reads) / float64(res.Attempts)
+}
+
+// Calibration holds the results of a calibration sweep and the setting chosen
+type Calibration struct {
+	Results []CalibrationResult
+	Best    Tuning
+}
+
+// Calibrate sweeps every receiver gain and a range of receiver thresholds with the last
+// card as a reference. At each setting it power-cycles the card attempts times and
+// counts how often REQA, anticollision and READ of block 0 succeed, without retries.
+// Of the settings with the highest success rate it picks the middle gain and then
+// the middle threshold, keeping a margin on both sides, and applies it to the reader.
+// progress, if not nil, is called on the reader's worker after each setting.
+func (r *Reader) Calibrate(attempts int, progress func(CalibrationResult)) (*Calibration, error) {
+	return r.CalibrateContext(context.Background(), attempts, progress)
+}
+
+// CalibrateContext is Calibrate stopped once ctx is done, which restores the original
+// tuning. The sweep takes far longer than a single operation, so the operation timeout
+// does not apply.
+func (r *Reader) CalibrateContext(ctx context.Context, attempts int, progress func(CalibrationResult)) (*Calibration, error) {
+	return callCancelable(ctx, r, func() (*Calibration, error) {
+		return r.calibrate(attempts, progress)
+	})
+}
+
+// calibrate implements Calibrate
+func (r *Reader) calibrate(attempts int, progress func(CalibrationResult)) (*Calibration, error) {
+	if r.lastCard == nil {
+		return nil, fmt.Errorf("no card selected")
+	}
+	if attempts <= 0 {
+		return nil, fmt.Errorf("attempts must be positive")
+	}
+
+	card := r.lastCard
+	var key []byte
+	keyType := KeyA
+	if card.IsClassic() {
+		if key = card.Keys.Key(0, KeyA); key == nil {
+			keyType = KeyB
+			key = card.Keys.Key(0, KeyB)
+		}
+		if key == nil {
+			return nil, fmt.Errorf("no key known for sector 0 of the reference card")
+		}
+	}
+
+	original := r.tuning
+	calibration := &Calibration{}
+	for _, gain := range []int{18, 23, 33, 38, 43, 48} {
+		for _, minLevel := range calibrationMinLevels {
+			if err := r.canceled(); err != nil {
+				r.tuning = original
+				r.applyTuning()
+				return nil, err
+			}
+
+			result := CalibrationResult{Tuning: original}
+			result.Tuning.GainDB = gain
+			result.Tuning.RxMinLevel = minLevel
+			r.tuning = result.Tuning
+			r.applyTuning()
+
+			for i := 0; i < attempts; i++ {
+				r.calibrationAttempt(&result, key, keyType)
+			}
+
+			calibration.Results = append(calibration.Results, result)
+			if progress != nil {
+				progress(result)
+			}
+		}
+	}
+
+	best, ok := bestTuning(calibration.Results)
+	if ok {
+		r.tuning = best
+		calibration.Best = best
+	} else {
+		r.tuning = original
+	}
+	r.applyTuning()
+
+	// Leave the reference card selected again
+	r.resetField()
+	r.authSector = -1
+	if _, err := r.request(PICCReqIDL); err == nil {
+		_, _ = r.selectUID(card.UID)
+	}
+
+	if !ok {
+		return calibration, fmt.Errorf("%w: the reference card never answered", ErrNoTag)
+	}
+	return calibration, nil
+}
+
+// calibrationAttempt power-cycles the card and counts the steps that succeed
+func (r *Reader) calibrationAttempt(result *CalibrationResult, key []byte, keyType KeyType) {
+	result.Attempts++
+	r.resetField()
+	r.authSector = -1
+
+	if atqa, err := r.request(PICCReqIDL); err != nil || atqa == nil {
+		return
+	}
+	result.Requests++
+
+	uid, _, err := r.selectCard()
+	if err != nil || string(uid) != string(r.lastCard.UID) {
+		return
+	}
+	result.Anticollisions++
+
+	if key != nil {
+		if err := r.authenticate(byte(keyType), 0, key, uid); err != nil {
+			return
+		}
+		defer r.stopCrypto()
+	}
+	if _, err := r.read(0); err != nil {
+		return
+	}
+	result.Reads++
+}
+
+// resetField switches the antenna off and on again, so that every card restarts in IDLE
+func (r *Reader) resetField() {
+	r.clearRegisterBitMask(TxControlReg, 0x03)
+	time.Sleep(fieldResetDelay)
+	r.setRegisterBitMask(TxControlReg, 0x03)
+	time.Sleep(fieldResetDelay)
+}
+
+// bestTuning picks among the settings with the highest success rate the middle gain,
+// then the middle threshold at that gain. It reports false if nothing succeeded.
+func bestTuning(results []CalibrationResult) (Tuning, bool) {
+	best := 0.0
+	for _, res := range results {
+		best = max(best, res.Rate())
+	}
+	if best == 0 {
+		return Tuning{}, false
+	}
+
+	var gains []int
+	for _, res := range results {
+		if res.Rate() == best && (len(gains) == 0 || gains[len(gains)-1] != res.Tuning.GainDB) {
+			gains = append(gains, res.Tuning.GainDB)
+		}
+	}
+	gain := gains[(len(gains)-1)/2]
+
+	var tied []Tuning
+	for _, res := range results {
+		if res.Rate() == best && res.Tuning.GainDB == gain {
+			tied = append(tied, res.Tuning)
+		}
+	}
+	return tied[(len(tied)-1)/2], true
+}
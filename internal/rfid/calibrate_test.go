@@ -0,0 +1,81 @@
+package rfid
+
+import (
+	"errors"
+	"testing"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+func TestCalibrate(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44})
+	reader, sim := newSimulatedReader(t, card)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// Behind a thick enclosure only 43 and 48 dB with MinLevel up to 10 hear the card
+	sim.SetWeakField(43, 10)
+
+	settings := 0
+	calibration, err := reader.Calibrate(2, func(CalibrationResult) { settings++ })
+	if err != nil {
+		t.Fatalf("Calibrate() error = %v", err)
+	}
+	if want := 6 * len(calibrationMinLevels); len(calibration.Results) != want || settings != want {
+		t.Fatalf("got %d results and %d progress calls, want %d", len(calibration.Results), settings, want)
+	}
+
+	for _, res := range calibration.Results {
+		want := 0
+		if res.Tuning.GainDB >= 43 && res.Tuning.RxMinLevel <= 10 {
+			want = 2
+		}
+		if res.Requests != want || res.Anticollisions != want || res.Reads != want {
+			t.Errorf("%d dB, MinLevel %d: %d/%d/%d of %d succeeded, want %d",
+				res.Tuning.GainDB, res.Tuning.RxMinLevel, res.Requests, res.Anticollisions, res.Reads, res.Attempts, want)
+		}
+	}
+
+	// The middle of 43 and 48 dB, then the middle of MinLevel 2 to 10
+	want := DefaultTuning()
+	want.GainDB = 43
+	want.RxMinLevel = 6
+	if calibration.Best != want {
+		t.Errorf("Best = %+v, want %+v", calibration.Best, want)
+	}
+	if got := reader.Tuning(); got != want {
+		t.Errorf("Tuning() = %+v after calibration, want %+v", got, want)
+	}
+
+	// The card is left selected with the chosen setting
+	if _, err := reader.ReadBlock(1); err != nil {
+		t.Errorf("ReadBlock() after calibration error = %v", err)
+	}
+
+	cfg := config.Default().RFID
+	calibration.Best.Apply(&cfg)
+	if tuningFromConfig(cfg) != want {
+		t.Errorf("tuning stored in config = %+v, want %+v", tuningFromConfig(cfg), want)
+	}
+}
+
+func TestCalibrateNoAnswer(t *testing.T) {
+	reader, sim := newSimulatedReader(t, NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	sim.SetWeakField(60, 0)
+
+	if _, err := reader.Calibrate(1, nil); !errors.Is(err, ErrNoTag) {
+		t.Errorf("Calibrate() error = %v, want ErrNoTag", err)
+	}
+	if got := reader.Tuning(); got != DefaultTuning() {
+		t.Errorf("Tuning() = %+v after a failed calibration, want the previous setting", got)
+	}
+
+	reader, _ = newSimulatedReader(t)
+	if _, err := reader.Calibrate(1, nil); err == nil {
+		t.Error("Calibrate() without a card succeeded, want error")
+	}
+}
@@ -0,0 +1,121 @@
+package rfid
+
+// Additional card types identified from ATQA and SAK
+const (
+	// CardTypeMifareMini represents a MIFARE Classic Mini card type
+	CardTypeMifareMini CardType = "MIFARE Mini"
+	// CardTypeMifareULC represents a MIFARE Ultralight C card type
+	CardTypeMifareULC CardType = "MIFARE Ultralight C"
+	// CardTypeMifareULEV1 represents a MIFARE Ultralight EV1 card type
+	CardTypeMifareULEV1 CardType = "MIFARE Ultralight EV1"
+	// CardTypeNTAG213 represents an NTAG213 card type
+	CardTypeNTAG213 CardType = "NTAG213"
+	// CardTypeNTAG215 represents an NTAG215 card type
+	CardTypeNTAG215 CardType = "NTAG215"
+	// CardTypeNTAG216 represents an NTAG216 card type
+	CardTypeNTAG216 CardType = "NTAG216"
+	// CardTypeMifarePlus represents a MIFARE Plus card type
+	CardTypeMifarePlus CardType = "MIFARE Plus"
+	// CardTypeDESFire represents a MIFARE DESFire card type
+	CardTypeDESFire CardType = "MIFARE DESFire"
+	// CardTypeSmartMX represents an NXP SmartMX card with MIFARE Classic emulation
+	CardTypeSmartMX CardType = "SmartMX"
+)
+
+// atqaUIDSizeMask covers the ATQA bits that only encode the UID size
+const atqaUIDSizeMask = 0x00C0
+
+// cardTypeEntry maps an ATQA/SAK pair to a card type and its memory size
+type cardTypeEntry struct {
+	cardType CardType
+	size     int
+	blocks   int
+	atqa     uint16 // ATQA with the UID size bits cleared, 0 matches any ATQA
+	sak      byte
+}
+
+// cardTypeTable follows NXP AN10833 "MIFARE type identification procedure".
+// Ultralight and NTAG variants share ATQA 0x0044/SAK 0x00 and are reported as the
+// Ultralight baseline here.
+var cardTypeTable = []cardTypeEntry{
+	{atqa: 0x0004, sak: 0x09, cardType: CardTypeMifareMini, size: 320, blocks: 20},
+	{atqa: 0x0004, sak: 0x08, cardType: CardTypeMifare1K, size: 1024, blocks: 64},
+	{atqa: 0x0002, sak: 0x18, cardType: CardTypeMifare4K, size: 4096, blocks: 256},
+	{atqa: 0x0004, sak: 0x00, cardType: CardTypeMifareUL, size: 64, blocks: 16},
+	{atqa: 0x0004, sak: 0x10, cardType: CardTypeMifarePlus, size: 2048, blocks: 128},
+	{atqa: 0x0002, sak: 0x11, cardType: CardTypeMifarePlus, size: 4096, blocks: 256},
+	{atqa: 0x0004, sak: 0x20, cardType: CardTypeMifarePlus, size: 2048, blocks: 128},
+	{atqa: 0x0002, sak: 0x20, cardType: CardTypeMifarePlus, size: 4096, blocks: 256},
+	{atqa: 0x0304, sak: 0x20, cardType: CardTypeDESFire},
+	{sak: 0x28, cardType: CardTypeSmartMX, size: 1024, blocks: 64},
+	{sak: 0x38, cardType: CardTypeSmartMX, size: 4096, blocks: 256},
+	{sak: 0x88, cardType: CardTypeMifare1K, size: 1024, blocks: 64},
+}
+
+// ultralightVersion maps a GET_VERSION product type and storage size to an Ultralight-family card
+type ultralightVersion struct {
+	cardType    CardType
+	pages       int
+	productType byte
+	storageSize byte
+}
+
+// ultralightVersionTable lists the GET_VERSION answers of NXP Ultralight EV1 and NTAG21x chips
+var ultralightVersionTable = []ultralightVersion{
+	{productType: 0x03, storageSize: 0x0B, cardType: CardTypeMifareULEV1, pages: 20},
+	{productType: 0x03, storageSize: 0x0E, cardType: CardTypeMifareULEV1, pages: 41},
+	{productType: 0x04, storageSize: 0x0F, cardType: CardTypeNTAG213, pages: 45},
+	{productType: 0x04, storageSize: 0x11, cardType: CardTypeNTAG215, pages: 135},
+	{productType: 0x04, storageSize: 0x13, cardType: CardTypeNTAG216, pages: 231},
+}
+
+// ultralightCPages is the number of pages on a MIFARE Ultralight C
+const ultralightCPages = 48
+
+// identifyCardType looks up the card type for an ATQA/SAK pair
+func identifyCardType(atqa uint16, sak byte) cardTypeEntry {
+	masked := atqa &^ atqaUIDSizeMask
+	for _, entry := range cardTypeTable {
+		if entry.sak == sak && (entry.atqa == 0 || entry.atqa == masked) {
+			return entry
+		}
+	}
+	return cardTypeEntry{cardType: CardTypeUnknown}
+}
+
+// identifyCardTypeBySAK looks up the card type from the SAK alone, for cards whose ATQA
+// was lost in a collision with the ATQA of another card
+func identifyCardTypeBySAK(sak byte) cardTypeEntry {
+	for _, entry := range cardTypeTable {
+		if entry.sak == sak {
+			return entry
+		}
+	}
+	return cardTypeEntry{cardType: CardTypeUnknown}
+}
+
+// setUltralightType refines an Ultralight baseline card to a specific variant and page count
+func (c *Card) setUltralightType(cardType CardType, pages int) {
+	c.Type = cardType
+	c.Blocks = pages
+	c.Size = pages * UltralightPageSize
+}
+
+// IsClassic reports whether the card uses MIFARE Classic sectors and Crypto1 authentication
+func (c *Card) IsClassic() bool {
+	switch c.Type {
+	case CardTypeMifareMini, CardTypeMifare1K, CardTypeMifare4K, CardTypeSmartMX:
+		return true
+	}
+	return false
+}
+
+// IsUltralight reports whether the card uses the Ultralight/NTAG page layout
+func (c *Card) IsUltralight() bool {
+	switch c.Type {
+	case CardTypeMifareUL, CardTypeMifareULC, CardTypeMifareULEV1,
+		CardTypeNTAG213, CardTypeNTAG215, CardTypeNTAG216:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package rfid
+
+import (
+	"fmt"
+	"log"
+)
+
+// crcA computes the ISO/IEC 14443-3 Type A CRC over data.
+// The result is returned low byte first, in the order it is sent on air.
+func crcA(data []byte) [2]byte {
+	crc := uint16(0x6363)
+	for _, b := range data {
+		b ^= byte(crc & 0xFF)
+		b ^= b << 4
+		crc = (crc >> 8) ^ (uint16(b) << 8) ^ (uint16(b) << 3) ^ (uint16(b) >> 4)
+	}
+	return [2]byte{byte(crc & 0xFF), byte(crc >> 8)}
+}
+
+// appendCRCA returns data followed by its CRC_A
+func appendCRCA(data []byte) []byte {
+	crc := crcA(data)
+	out := make([]byte, 0, len(data)+2)
+	out = append(out, data...)
+	return append(out, crc[0], crc[1])
+}
+
+// checkCRCA reports whether the last two bytes of data are a valid CRC_A over the rest
+func checkCRCA(data []byte) bool {
+	if len(data) < 3 {
+		return false
+	}
+	crc := crcA(data[:len(data)-2])
+	return crc[0] == data[len(data)-2] && crc[1] == data[len(data)-1]
+}
+
+// calculateCRC computes the CRC_A of data with the MFRC522 CRC coprocessor.
+// ModeReg presets the coprocessor to 0x6363 in init. It returns false if the
+// coprocessor does not signal completion.
+func (r *Reader) calculateCRC(data []byte) ([2]byte, bool) {
+	r.writeRegister(CommandReg, PCDIdle)
+	r.writeRegister(DivIrqReg, 0x04) // Clear CRCIRq
+	r.setRegisterBitMask(FIFOLevelReg, 0x80)
+
+	for _, b := range data {
+		r.writeRegister(FIFODataReg, b)
+	}
+
+	r.writeRegister(CommandReg, PCDCalcCRC)
+
+	// The CRC of a full FIFO takes about 90 us, far less than a few register reads
+	for i := 0; i < 255; i++ {
+		if r.readRegister(DivIrqReg)&0x04 != 0 {
+			r.writeRegister(CommandReg, PCDIdle)
+			return [2]byte{r.readRegister(CRCResultRegL), r.readRegister(CRCResultRegH)}, true
+		}
+	}
+
+	r.writeRegister(CommandReg, PCDIdle)
+	return [2]byte{}, false
+}
+
+// crc computes a CRC_A on the chip, falling back to software for the rest of the
+// operation if the coprocessor does not respond
+func (r *Reader) crc(data []byte) [2]byte {
+	if !r.softwareCRC {
+		if crc, ok := r.calculateCRC(data); ok {
+			return crc
+		}
+		log.Printf("Warning: MFRC522 CRC coprocessor timed out, computing CRC_A in software")
+		r.softwareCRC = true
+	}
+	return crcA(data)
+}
+
+// appendCRC returns frame followed by its CRC_A
+func (r *Reader) appendCRC(frame []byte) []byte {
+	crc := r.crc(frame)
+	out := make([]byte, 0, len(frame)+2)
+	out = append(out, frame...)
+	return append(out, crc[0], crc[1])
+}
+
+// stripCRC checks that an answer holds n payload bytes followed by their CRC_A and returns the payload
+func (r *Reader) stripCRC(data []byte, n int) ([]byte, error) {
+	if len(data) != n+2 {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrProtocol, n+2, len(data))
+	}
+
+	crc := r.crc(data[:n])
+	if crc[0] != data[n] || crc[1] != data[n+1] {
+		return nil, ErrCRC
+	}
+
+	return data[:n], nil
+}
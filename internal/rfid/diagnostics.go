@@ -0,0 +1,241 @@
+package rfid
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// selfTestLen is the size of the digital self-test result
+const selfTestLen = 64
+
+// selfTestReference holds the expected self-test results by VersionReg value: 0x90 to
+// 0x92 are MFRC522 revisions, 0x88 is the Fudan FM17522 found on many modules
+var selfTestReference = map[byte][]byte{
+	0x88: {
+		0x00, 0xD6, 0x78, 0x8C, 0xE2, 0xAA, 0x0C, 0x18,
+		0x2A, 0xB8, 0x7A, 0x7F, 0xD3, 0x6A, 0xCF, 0x0B,
+		0xB1, 0x37, 0x63, 0x4B, 0x69, 0xAE, 0x91, 0xC7,
+		0xC3, 0x97, 0xAE, 0x77, 0xF4, 0x37, 0xD7, 0x9B,
+		0x7C, 0xF5, 0x3C, 0x11, 0x8F, 0x15, 0xC3, 0xD7,
+		0xC1, 0x5B, 0x00, 0x2A, 0xD0, 0x75, 0xDE, 0x9E,
+		0x51, 0x64, 0xAB, 0x3E, 0xE9, 0x15, 0xB5, 0xAB,
+		0x56, 0x9A, 0x98, 0x82, 0x26, 0xEA, 0x2A, 0x62,
+	},
+	0x90: {
+		0x00, 0x87, 0x98, 0x0F, 0x49, 0xFF, 0x07, 0x19,
+		0xBF, 0x22, 0x30, 0x49, 0x59, 0x63, 0xAD, 0xCA,
+		0x7F, 0xE3, 0x4E, 0x03, 0x5C, 0x4E, 0x49, 0x50,
+		0x47, 0x9A, 0x37, 0x61, 0xE7, 0xE2, 0xC6, 0x2E,
+		0x75, 0x5A, 0xED, 0x04, 0x3D, 0x02, 0x4B, 0x78,
+		0x32, 0xFF, 0x58, 0x3B, 0x7C, 0xE9, 0x00, 0x94,
+		0xB4, 0x4A, 0x59, 0x5B, 0xFD, 0xC9, 0x29, 0xDF,
+		0x35, 0x96, 0x98, 0x9E, 0x4F, 0x30, 0x32, 0x8D,
+	},
+	0x91: {
+		0x00, 0xC6, 0x37, 0xD5, 0x32, 0xB7, 0x57, 0x5C,
+		0xC2, 0xD8, 0x7C, 0x4D, 0xD9, 0x70, 0xC7, 0x73,
+		0x10, 0xE6, 0xD2, 0xAA, 0x5E, 0xA1, 0x3E, 0x5A,
+		0x14, 0xAF, 0x30, 0x61, 0xC9, 0x70, 0xDB, 0x2E,
+		0x64, 0x22, 0x72, 0xB5, 0xBD, 0x65, 0xF4, 0xEC,
+		0x22, 0xBC, 0xD3, 0x72, 0x35, 0xCD, 0xAA, 0x41,
+		0x1F, 0xA7, 0xF3, 0x53, 0x14, 0xDE, 0x7E, 0x02,
+		0xD9, 0x0F, 0xB5, 0x5E, 0x25, 0x1D, 0x29, 0x79,
+	},
+	0x92: {
+		0x00, 0xEB, 0x66, 0xBA, 0x57, 0xBF, 0x23, 0x95,
+		0xD0, 0xE3, 0x0D, 0x3D, 0x27, 0x89, 0x5C, 0xDE,
+		0x9D, 0x3B, 0xA7, 0x00, 0x21, 0x5B, 0x89, 0x82,
+		0x51, 0x3A, 0xEB, 0x02, 0x0C, 0xA5, 0x00, 0x49,
+		0x7C, 0x84, 0x4D, 0xB3, 0xCC, 0xD2, 0x1B, 0x81,
+		0x5D, 0x48, 0x76, 0xD5, 0x71, 0x61, 0x21, 0xA9,
+		0x86, 0x96, 0x83, 0x38, 0xCF, 0x9D, 0x5B, 0x6D,
+		0xDC, 0x15, 0xBA, 0x3E, 0x7D, 0x95, 0x3B, 0x2F,
+	},
+}
+
+// chipName returns the chip a VersionReg value identifies
+func chipName(version byte) string {
+	switch version {
+	case 0x88:
+		return "FM17522"
+	case 0x89:
+		return "FM17522E"
+	case 0xB2:
+		return "FM17522_1"
+	case 0x90:
+		return "MFRC522 v0.0"
+	case 0x91:
+		return "MFRC522 v1.0"
+	case 0x92:
+		return "MFRC522 v2.0"
+	case 0x12:
+		return "counterfeit MFRC522"
+	case 0x00, 0xFF:
+		return "no chip"
+	default:
+		return "unknown"
+	}
+}
+
+// registerNames lists the named MFRC522 registers in address order. FIFODataReg is left
+// out of dumps, since reading it takes a byte out of the FIFO.
+var registerNames = []struct {
+	addr byte
+	name string
+}{
+	{CommandReg, "CommandReg"},
+	{ComIEnReg, "ComIEnReg"},
+	{DivIEnReg, "DivIEnReg"},
+	{ComIrqReg, "ComIrqReg"},
+	{DivIrqReg, "DivIrqReg"},
+	{ErrorReg, "ErrorReg"},
+	{Status1Reg, "Status1Reg"},
+	{Status2Reg, "Status2Reg"},
+	{FIFOLevelReg, "FIFOLevelReg"},
+	{WaterLevelReg, "WaterLevelReg"},
+	{ControlReg, "ControlReg"},
+	{BitFramingReg, "BitFramingReg"},
+	{CollReg, "CollReg"},
+	{ModeReg, "ModeReg"},
+	{TxModeReg, "TxModeReg"},
+	{RxModeReg, "RxModeReg"},
+	{TxControlReg, "TxControlReg"},
+	{TxAutoReg, "TxAutoReg"},
+	{TxSelReg, "TxSelReg"},
+	{RxSelReg, "RxSelReg"},
+	{RxThresholdReg, "RxThresholdReg"},
+	{DemodReg, "DemodReg"},
+	{MfTxReg, "MfTxReg"},
+	{MfRxReg, "MfRxReg"},
+	{SerialSpeedReg, "SerialSpeedReg"},
+	{CRCResultRegH, "CRCResultRegH"},
+	{CRCResultRegL, "CRCResultRegL"},
+	{ModWidthReg, "ModWidthReg"},
+	{RFCfgReg, "RFCfgReg"},
+	{GsNReg, "GsNReg"},
+	{CWGsPReg, "CWGsPReg"},
+	{ModGsPReg, "ModGsPReg"},
+	{TModeReg, "TModeReg"},
+	{TPrescalerReg, "TPrescalerReg"},
+	{TReloadRegH, "TReloadRegH"},
+	{TReloadRegL, "TReloadRegL"},
+	{TCounterValueRegH, "TCounterValueRegH"},
+	{TCounterValueRegL, "TCounterValueRegL"},
+	{TestSel1Reg, "TestSel1Reg"},
+	{TestSel2Reg, "TestSel2Reg"},
+	{TestPinEnReg, "TestPinEnReg"},
+	{TestPinValueReg, "TestPinValueReg"},
+	{TestBusReg, "TestBusReg"},
+	{AutoTestReg, "AutoTestReg"},
+	{VersionReg, "VersionReg"},
+	{AnalogTestReg, "AnalogTestReg"},
+	{TestDAC1Reg, "TestDAC1Reg"},
+	{TestDAC2Reg, "TestDAC2Reg"},
+	{TestADCReg, "TestADCReg"},
+}
+
+// RegisterValue is the content of one MFRC522 register
+type RegisterValue struct {
+	Address byte
+	Name    string
+	Value   byte
+}
+
+// SelfTestResult is the outcome of the digital self-test
+type SelfTestResult struct {
+	Result    []byte // The 64 bytes the chip produced, nil if it did not complete
+	Reference []byte // The expected bytes for the chip version, nil if none is known
+	Passed    bool   // Result matches Reference
+	Err       string // Why the self-test did not complete
+}
+
+// Diagnostics is a report on the state of the MFRC522
+type Diagnostics struct {
+	Version   byte
+	Chip      string
+	Registers []RegisterValue // As configured, before the self-test
+	SelfTest  SelfTestResult
+	SPISpeed  int          // SPI clock in Hz
+	SPILink   *SPILinkTest // Link test that chose the SPI clock, nil if none ran
+}
+
+// Diagnostics dumps the registers, then runs the digital self-test of the datasheet and
+// compares its result with the reference of the chip version. A wrong result or an
+// unknown version points to a clone chip; all zeros or a timeout to a wiring fault.
+// The chip is reset and configured again afterwards, so the card must be scanned again.
+func (r *Reader) Diagnostics() (*Diagnostics, error) {
+	return call(r, r.diagnostics)
+}
+
+// diagnostics implements Diagnostics
+func (r *Reader) diagnostics() (*Diagnostics, error) {
+	version := r.readRegister(VersionReg)
+	d := &Diagnostics{
+		Version:   version,
+		Chip:      chipName(version),
+		Registers: r.dumpRegisters(),
+		SPISpeed:  r.spiSpeed,
+		SPILink:   r.spiLink,
+	}
+
+	result, err := r.selfTest()
+	d.SelfTest.Result = result
+	d.SelfTest.Reference = selfTestReference[version]
+	if err != nil {
+		d.SelfTest.Err = err.Error()
+	} else {
+		d.SelfTest.Passed = d.SelfTest.Reference != nil && bytes.Equal(result, d.SelfTest.Reference)
+	}
+
+	// The self-test leaves the chip reset and the card unpowered
+	if err := r.reinit(); err != nil {
+		return d, fmt.Errorf("failed to initialize reader after self-test: %w", err)
+	}
+
+	return d, nil
+}
+
+// dumpRegisters reads every named register
+func (r *Reader) dumpRegisters() []RegisterValue {
+	registers := make([]RegisterValue, len(registerNames))
+	for i, reg := range registerNames {
+		registers[i] = RegisterValue{Address: reg.addr, Name: reg.name, Value: r.readRegister(reg.addr)}
+	}
+	return registers
+}
+
+// selfTest runs the digital self-test of section 16.1.1 of the datasheet and returns its result
+func (r *Reader) selfTest() ([]byte, error) {
+	r.writeRegister(CommandReg, PCDResetPhase)
+	time.Sleep(50 * time.Millisecond)
+
+	// Clear the internal buffer with 25 zero bytes
+	r.setRegisterBitMask(FIFOLevelReg, 0x80)
+	for i := 0; i < 25; i++ {
+		r.writeRegister(FIFODataReg, 0x00)
+	}
+	r.writeRegister(CommandReg, PCDMem)
+
+	// Enable the self-test and start it with CalcCRC over a zero byte
+	r.writeRegister(AutoTestReg, 0x09)
+	r.writeRegister(FIFODataReg, 0x00)
+	r.writeRegister(CommandReg, PCDCalcCRC)
+	defer r.writeRegister(AutoTestReg, 0x00)
+
+	deadline := time.Now().Add(r.commandTimeout)
+	for r.readRegister(FIFOLevelReg) < selfTestLen {
+		if time.Now().After(deadline) {
+			r.writeRegister(CommandReg, PCDIdle)
+			return nil, fmt.Errorf("%w: self-test did not complete", ErrTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	r.writeRegister(CommandReg, PCDIdle)
+
+	result := make([]byte, selfTestLen)
+	for i := range result {
+		result[i] = r.readRegister(FIFODataReg)
+	}
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package rfid
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+// corruptFIFO is a transport on which every byte read from the FIFO has a bit flipped
+type corruptFIFO struct {
+	Transport
+}
+
+func (c *corruptFIFO) ReadRegister(reg byte) (byte, error) {
+	value, err := c.Transport.ReadRegister(reg)
+	if reg == FIFODataReg {
+		value ^= 0x01
+	}
+	return value, err
+}
+
+func TestDiagnosticsSelfTest(t *testing.T) {
+	tests := []struct {
+		version byte
+		chip    string
+		passed  bool
+	}{
+		{0x88, "FM17522", true},
+		{0x90, "MFRC522 v0.0", true},
+		{0x91, "MFRC522 v1.0", true},
+		{0x92, "MFRC522 v2.0", true},
+		{0x12, "counterfeit MFRC522", false},
+	}
+
+	for _, tt := range tests {
+		sim := NewSimulator()
+		sim.SetVersion(tt.version)
+		reader, err := NewReaderWithTransport(sim, config.Default().RFID)
+		if err != nil {
+			t.Fatalf("NewReaderWithTransport() error = %v", err)
+		}
+
+		d, err := reader.Diagnostics()
+		if err != nil {
+			t.Fatalf("version 0x%02x: Diagnostics() error = %v", tt.version, err)
+		}
+		if d.Version != tt.version || d.Chip != tt.chip {
+			t.Errorf("version 0x%02x: chip = 0x%02x %q, want %q", tt.version, d.Version, d.Chip, tt.chip)
+		}
+		if d.SelfTest.Passed != tt.passed || len(d.SelfTest.Result) != selfTestLen {
+			t.Errorf("version 0x%02x: self-test passed = %v with %d bytes, want %v",
+				tt.version, d.SelfTest.Passed, len(d.SelfTest.Result), tt.passed)
+		}
+		if tt.passed && !bytes.Equal(d.SelfTest.Result, d.SelfTest.Reference) {
+			t.Errorf("version 0x%02x: result %x differs from reference", tt.version, d.SelfTest.Result)
+		}
+		_ = reader.Close()
+	}
+}
+
+func TestDiagnosticsRegisters(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF}))
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	d, err := reader.Diagnostics()
+	if err != nil {
+		t.Fatalf("Diagnostics() error = %v", err)
+	}
+
+	values := make(map[string]byte)
+	for _, reg := range d.Registers {
+		values[reg.Name] = reg.Value
+	}
+	if _, ok := values["FIFODataReg"]; ok {
+		t.Error("register dump reads FIFODataReg")
+	}
+	// The dump shows the configuration made by init, before the self-test reset
+	for name, want := range map[string]byte{"VersionReg": 0x92, "TModeReg": 0x8D, "ModeReg": 0x3D, "TxAutoReg": 0x40} {
+		if values[name] != want {
+			t.Errorf("%s = 0x%02x, want 0x%02x", name, values[name], want)
+		}
+	}
+
+	// The reader works again once the card is scanned anew
+	if reader.GetLastCard() != nil {
+		t.Error("card still selected after the self-test")
+	}
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() after Diagnostics() error = %v", err)
+	}
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Errorf("ReadBlock() after Diagnostics() error = %v", err)
+	}
+}
+
+func TestDiagnosticsFaults(t *testing.T) {
+	sim := NewSimulator()
+	reader, err := NewReaderWithTransport(&corruptFIFO{sim}, config.Default().RFID)
+	if err != nil {
+		t.Fatalf("NewReaderWithTransport() error = %v", err)
+	}
+	defer reader.Close()
+
+	d, err := reader.Diagnostics()
+	if err != nil {
+		t.Fatalf("Diagnostics() error = %v", err)
+	}
+	if d.SelfTest.Passed || d.SelfTest.Err != "" {
+		t.Errorf("self-test with a faulty FIFO passed = %v, error %q", d.SelfTest.Passed, d.SelfTest.Err)
+	}
+
+	// A stuck CRC coprocessor never delivers a result
+	reader, sim = newSimulatedReader(t)
+	reader.SetCommandTimeout(20 * time.Millisecond)
+	sim.SetCRCCoprocessorStuck(true)
+	d, err = reader.Diagnostics()
+	if err != nil {
+		t.Fatalf("Diagnostics() error = %v", err)
+	}
+	if d.SelfTest.Passed || d.SelfTest.Err == "" || d.SelfTest.Result != nil {
+		t.Errorf("self-test with a stuck coprocessor = %+v, want a timeout", d.SelfTest)
+	}
+}
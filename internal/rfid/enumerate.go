@@ -0,0 +1,111 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// maxFieldCards bounds enumeration in case a card does not stay halted
+const maxFieldCards = 16
+
+// ScanForCards returns every card in the field. Each card found is halted so that the
+// next request reaches the others; afterwards no card is selected and SelectCard picks
+// the one to work with. Ultralight variants are only told apart once selected.
+func (r *Reader) ScanForCards() ([]*Card, error) {
+	return r.ScanForCardsContext(context.Background())
+}
+
+// ScanForCardsContext is ScanForCards bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) ScanForCardsContext(ctx context.Context) ([]*Card, error) {
+	return callContext(ctx, r, r.scanForCards)
+}
+
+// scanForCards implements ScanForCards
+func (r *Reader) scanForCards() ([]*Card, error) {
+	r.authSector = -1
+
+	// HALT the selected card so that WUPA wakes it together with the halted ones.
+	// Cards left READY fall back to IDLE on the first WUPA without answering it.
+	r.halt()
+	atqa, err := r.request(PICCReqAll)
+	if errors.Is(err, ErrNoTag) {
+		atqa, err = r.request(PICCReqAll)
+	}
+
+	var cards []*Card
+	for err == nil && len(cards) < maxFieldCards {
+		uid, sak, selectErr := r.selectCard()
+		if selectErr != nil {
+			return nil, fmt.Errorf("anti-collision failed: %w", selectErr)
+		}
+
+		card := r.selectedCard(uid, atqa, sak)
+		cards = append(cards, card)
+		log.Printf("Card %d in field: %s", len(cards), card.String())
+
+		// A halted card ignores REQA, so the next request is answered by the others
+		r.halt()
+		atqa, err = r.request(PICCReqIDL)
+	}
+	if err != nil && !errors.Is(err, ErrNoTag) {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	r.lastCard = nil
+	r.published.Store(nil)
+
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("no card detected: %w", ErrNoTag)
+	}
+	return cards, nil
+}
+
+// SelectCard wakes the card with the given UID, halted or not, and selects it for the
+// following operations. Other cards in the field stay silent.
+func (r *Reader) SelectCard(uid []byte) (*Card, error) {
+	return r.SelectCardContext(context.Background(), uid)
+}
+
+// SelectCardContext is SelectCard bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) SelectCardContext(ctx context.Context, uid []byte) (*Card, error) {
+	return callContext(ctx, r, func() (*Card, error) {
+		return r.selectCardByUID(uid)
+	})
+}
+
+// selectCardByUID implements SelectCard
+func (r *Reader) selectCardByUID(uid []byte) (*Card, error) {
+	if r.lastCard != nil && bytes.Equal(r.lastCard.UID, uid) && r.reactivate() == nil {
+		return r.lastCard, nil
+	}
+	r.authSector = -1
+
+	// The selected card would ignore WUPA
+	r.halt()
+	atqa, err := r.request(PICCReqAll)
+	if errors.Is(err, ErrNoTag) {
+		atqa, err = r.request(PICCReqAll)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no card detected: %w", err)
+	}
+
+	sak, err := r.selectUID(uid)
+	if err != nil {
+		return nil, fmt.Errorf("card %x not selected: %w", uid, err)
+	}
+
+	card := r.selectedCard(append([]byte(nil), uid...), atqa, sak)
+	r.lastCard = card
+	if card.Type == CardTypeMifareUL {
+		r.identifyUltralight(card)
+	}
+
+	log.Printf("Card selected: %s", card.String())
+	return r.publish(), nil
+}
@@ -0,0 +1,153 @@
+package rfid
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// uidSet returns the hex UIDs of cards, sorted
+func uidSet(cards []*Card) []string {
+	uids := make([]string, len(cards))
+	for i, card := range cards {
+		uids[i] = hex.EncodeToString(card.UID)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+func TestScanForCards(t *testing.T) {
+	tests := []struct {
+		name  string
+		cards []*VirtualCard
+	}{
+		{"single card", []*VirtualCard{
+			NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}),
+		}},
+		{"two cards", []*VirtualCard{
+			NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}),
+			NewVirtualClassic1K([]byte{0x11, 0x2A, 0x33, 0x44}),
+		}},
+		{"collision in the last UID bit", []*VirtualCard{
+			NewVirtualClassic1K([]byte{0x01, 0x02, 0x03, 0x04}),
+			NewVirtualClassic1K([]byte{0x01, 0x02, 0x03, 0x84}),
+		}},
+		{"mixed types and UID sizes", []*VirtualCard{
+			NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF}),
+			NewVirtualClassic4K([]byte{0xDE, 0xAD, 0xBE, 0xEE}),
+			NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}),
+		}},
+		{"shared first cascade level", []*VirtualCard{
+			NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}),
+			NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x99, 0x44, 0x55, 0x66}),
+			NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x67}),
+		}},
+	}
+
+	for _, tt := range tests {
+		reader, _ := newSimulatedReader(t, tt.cards...)
+		cards, err := reader.ScanForCards()
+		if err != nil {
+			t.Errorf("%s: ScanForCards() error = %v", tt.name, err)
+			continue
+		}
+
+		want := make([]*Card, len(tt.cards))
+		for i, card := range tt.cards {
+			want[i] = &Card{UID: card.UID}
+		}
+		if got, want := uidSet(cards), uidSet(want); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: ScanForCards() UIDs = %v, want %v", tt.name, got, want)
+		}
+		if reader.GetLastCard() != nil {
+			t.Errorf("%s: a card is still selected after ScanForCards()", tt.name)
+		}
+
+		// A second enumeration finds the halted cards again
+		again, err := reader.ScanForCards()
+		if err != nil || len(again) != len(tt.cards) {
+			t.Errorf("%s: second ScanForCards() = %d cards, %v", tt.name, len(again), err)
+		}
+	}
+}
+
+func TestScanForCardsTypes(t *testing.T) {
+	classic := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	classic4K := NewVirtualClassic4K([]byte{0x12, 0x34, 0x56, 0x78})
+	ultralight := NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	reader, _ := newSimulatedReader(t, classic, classic4K, ultralight)
+
+	cards, err := reader.ScanForCards()
+	if err != nil {
+		t.Fatalf("ScanForCards() error = %v", err)
+	}
+
+	want := map[string]CardType{
+		"deadbeef":       CardTypeMifare1K,
+		"12345678":       CardTypeMifare4K,
+		"04112233445566": CardTypeMifareUL,
+	}
+	for _, card := range cards {
+		if card.Type != want[hex.EncodeToString(card.UID)] {
+			t.Errorf("card %x type = %v, want %v", card.UID, card.Type, want[hex.EncodeToString(card.UID)])
+		}
+	}
+}
+
+func TestSelectCard(t *testing.T) {
+	first := NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44})
+	first.SetBlock(4, []byte("first card.....!"))
+	second := NewVirtualClassic1K([]byte{0x11, 0x2A, 0x33, 0x44})
+	second.SetBlock(4, []byte("second card....!"))
+	reader, _ := newSimulatedReader(t, first, second)
+
+	if _, err := reader.ScanForCards(); err != nil {
+		t.Fatalf("ScanForCards() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		card *VirtualCard
+		data string
+	}{
+		{second, "second card....!"},
+		{first, "first card.....!"},
+		{second, "second card....!"},
+	} {
+		card, err := reader.SelectCard(tt.card.UID)
+		if err != nil {
+			t.Fatalf("SelectCard(%x) error = %v", tt.card.UID, err)
+		}
+		if !bytes.Equal(card.UID, tt.card.UID) || reader.GetLastCard() != card {
+			t.Errorf("SelectCard(%x) selected %x", tt.card.UID, card.UID)
+		}
+
+		data, err := reader.ReadBlock(4)
+		if err != nil {
+			t.Fatalf("ReadBlock() on %x error = %v", tt.card.UID, err)
+		}
+		if string(data) != tt.data {
+			t.Errorf("ReadBlock() on %x = %q, want %q", tt.card.UID, data, tt.data)
+		}
+	}
+
+	if _, err := reader.SelectCard([]byte{0x99, 0x99, 0x99, 0x99}); err == nil {
+		t.Error("SelectCard() of an absent UID succeeded")
+	}
+}
+
+func TestScanForCardWithSeveralCards(t *testing.T) {
+	reader, _ := newSimulatedReader(t,
+		NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}),
+		NewVirtualClassic4K([]byte{0x11, 0x2A, 0x33, 0x44}),
+	)
+
+	card, err := reader.ScanForCard()
+	if err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Errorf("ReadBlock() on %x error = %v", card.UID, err)
+	}
+}
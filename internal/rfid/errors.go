@@ -0,0 +1,110 @@
+package rfid
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors reported by the reader. Operations wrap them with context, so callers
+// should test for them with errors.Is; CollisionError and NAKError carry details
+// that can be retrieved with errors.As.
+var (
+	ErrNoTag          = errors.New("no card answered")
+	ErrTimeout        = errors.New("MFRC522 command timed out")
+	ErrCollision      = errors.New("bit collision")
+	ErrParity         = errors.New("parity error")
+	ErrCRC            = errors.New("CRC_A mismatch")
+	ErrBufferOverflow = errors.New("FIFO buffer overflow")
+	ErrProtocol       = errors.New("protocol error")
+	ErrNAK            = errors.New("card answered NAK")
+	ErrAuthDenied     = errors.New("authentication denied")
+	ErrReaderClosed   = errors.New("reader closed")
+	ErrReaderAbsent   = errors.New("MFRC522 not responding")
+)
+
+// ErrorReg bits
+const (
+	errProtocol  = 0x01
+	errParity    = 0x02
+	errCRC       = 0x04
+	errColl      = 0x08
+	errBufferOvf = 0x10
+)
+
+// CollisionError reports a bit collision during anticollision
+type CollisionError struct {
+	// Position of the first collided bit within the frame, counting from 1.
+	// Zero means the MFRC522 could not locate the collision.
+	Position int
+}
+
+// Error implements error
+func (e *CollisionError) Error() string {
+	if e.Position == 0 {
+		return ErrCollision.Error()
+	}
+	return fmt.Sprintf("%s at bit %d", ErrCollision, e.Position)
+}
+
+// Is makes errors.Is(err, ErrCollision) match
+func (e *CollisionError) Is(target error) bool {
+	return target == ErrCollision
+}
+
+// NAKError reports a 4-bit NAK answer from the card
+type NAKError struct {
+	Code       byte
+	Ultralight bool // Sent by an Ultralight or NTAG card, where 0x4 has its own meaning
+}
+
+// Error implements error
+func (e *NAKError) Error() string {
+	switch {
+	case e.Code == 0x0:
+		return fmt.Sprintf("%s 0x%X (invalid argument or access denied)", ErrNAK, e.Code)
+	case e.Code == 0x1, e.Code == 0x5:
+		return fmt.Sprintf("%s 0x%X (parity or CRC error)", ErrNAK, e.Code)
+	case e.Code == 0x4 && e.Ultralight:
+		return fmt.Sprintf("%s 0x%X (authentication counter overflow)", ErrNAK, e.Code)
+	case e.Code == 0x4:
+		return fmt.Sprintf("%s 0x%X (invalid operation or access denied)", ErrNAK, e.Code)
+	default:
+		return fmt.Sprintf("%s 0x%X", ErrNAK, e.Code)
+	}
+}
+
+// Is makes errors.Is(err, ErrNAK) match
+func (e *NAKError) Is(target error) bool {
+	return target == ErrNAK
+}
+
+// nakError returns the error for a NAK from the last card
+func (r *Reader) nakError(code byte) *NAKError {
+	return &NAKError{Code: code, Ultralight: r.lastCard != nil && r.lastCard.IsUltralight()}
+}
+
+// errorFromRegisters decodes ErrorReg, using CollReg to locate collisions.
+// It returns nil if no error bit is set.
+func errorFromRegisters(errorReg, collReg byte) error {
+	switch {
+	case errorReg&errBufferOvf != 0:
+		return ErrBufferOverflow
+	case errorReg&errColl != 0:
+		// CollPosNotValid is set when the collision lies outside the 32-bit range
+		if collReg&0x20 != 0 {
+			return &CollisionError{}
+		}
+		position := int(collReg & 0x1F)
+		if position == 0 {
+			position = 32
+		}
+		return &CollisionError{Position: position}
+	case errorReg&errCRC != 0:
+		return ErrCRC
+	case errorReg&errParity != 0:
+		return ErrParity
+	case errorReg&errProtocol != 0:
+		return ErrProtocol
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package rfid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorFromRegisters(t *testing.T) {
+	tests := []struct {
+		errorReg byte
+		collReg  byte
+		want     error
+	}{
+		{0x00, 0x00, nil},
+		{errProtocol, 0x00, ErrProtocol},
+		{errParity, 0x00, ErrParity},
+		{errCRC, 0x00, ErrCRC},
+		{errColl | errParity, 0x05, ErrCollision},
+		{errBufferOvf | errColl, 0x00, ErrBufferOverflow},
+	}
+
+	for _, tt := range tests {
+		err := errorFromRegisters(tt.errorReg, tt.collReg)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("errorFromRegisters(0x%02x, 0x%02x) = %v, want %v", tt.errorReg, tt.collReg, err, tt.want)
+		}
+	}
+
+	var collision *CollisionError
+	if err := errorFromRegisters(errColl, 0x05); !errors.As(err, &collision) || collision.Position != 5 {
+		t.Errorf("collision at CollPos 5 = %v, want position 5", err)
+	}
+	if err := errorFromRegisters(errColl, 0x00); !errors.As(err, &collision) || collision.Position != 32 {
+		t.Errorf("collision at CollPos 0 = %v, want position 32", err)
+	}
+	if err := errorFromRegisters(errColl, 0x20); !errors.As(err, &collision) || collision.Position != 0 {
+		t.Errorf("collision with CollPosNotValid = %v, want position 0", err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	reader, _ := newSimulatedReader(t)
+	if _, err := reader.ScanForCard(); !errors.Is(err, ErrNoTag) {
+		t.Errorf("ScanForCard() on an empty field error = %v, want ErrNoTag", err)
+	}
+
+	classic := NewVirtualClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	reader, _ = newSimulatedReader(t, classic)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	_ = reader.SetCardKey(1, KeyA, testKeyA)
+	_ = reader.SetCardKey(1, KeyB, testKeyB)
+	if _, err := reader.ReadBlock(4); !errors.Is(err, ErrAuthDenied) {
+		t.Errorf("ReadBlock() with wrong keys error = %v, want ErrAuthDenied", err)
+	}
+
+	ultralight := NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	ultralight.memory[10] = 0x10 // Lock page 4
+	reader, _ = newSimulatedReader(t, ultralight)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	err := reader.WritePage(4, []byte("LOCK"))
+	var nak *NAKError
+	if !errors.Is(err, ErrNAK) || !errors.As(err, &nak) {
+		t.Fatalf("WritePage() on a locked page error = %v, want a NAKError", err)
+	}
+	if nak.Code != piccNAK {
+		t.Errorf("NAK code = 0x%X, want 0x%X", nak.Code, piccNAK)
+	}
+}
+
+func TestNAKErrorDescription(t *testing.T) {
+	tests := []struct {
+		nak  NAKError
+		want string
+	}{
+		{NAKError{Code: 0x4}, "invalid operation or access denied"},
+		{NAKError{Code: 0x4, Ultralight: true}, "authentication counter overflow"},
+		{NAKError{Code: 0x5, Ultralight: true}, "parity or CRC error"},
+	}
+	for _, tt := range tests {
+		if got := tt.nak.Error(); !strings.Contains(got, tt.want) {
+			t.Errorf("%+v.Error() = %q, want it to mention %q", tt.nak, got, tt.want)
+		}
+	}
+}
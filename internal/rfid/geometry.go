@@ -0,0 +1,115 @@
+package rfid
+
+// MIFARE Classic memory layout: the first 32 sectors hold 4 blocks each,
+// sectors 32 to 39 (4K cards only) hold 16 blocks each.
+const (
+	smallSectorBlocks = 4
+	largeSectorBlocks = 16
+	smallSectorCount  = 32
+	smallSectorsEnd   = smallSectorCount * smallSectorBlocks // First block of sector 32
+)
+
+// sectorOfBlock returns the sector containing block
+func sectorOfBlock(block int) int {
+	if block < smallSectorsEnd {
+		return block / smallSectorBlocks
+	}
+	return smallSectorCount + (block-smallSectorsEnd)/largeSectorBlocks
+}
+
+// firstBlockOfSector returns the first block of a sector
+func firstBlockOfSector(sector int) int {
+	if sector < smallSectorCount {
+		return sector * smallSectorBlocks
+	}
+	return smallSectorsEnd + (sector-smallSectorCount)*largeSectorBlocks
+}
+
+// blocksInSector returns the number of blocks in a sector, including its trailer
+func blocksInSector(sector int) int {
+	if sector < smallSectorCount {
+		return smallSectorBlocks
+	}
+	return largeSectorBlocks
+}
+
+// trailerOfSector returns the sector trailer block of a sector
+func trailerOfSector(sector int) int {
+	return firstBlockOfSector(sector) + blocksInSector(sector) - 1
+}
+
+// Sectors returns the number of sectors on a MIFARE Classic card
+func (c *Card) Sectors() int {
+	if c.Blocks <= smallSectorsEnd {
+		return c.Blocks / smallSectorBlocks
+	}
+	return smallSectorCount + (c.Blocks-smallSectorsEnd)/largeSectorBlocks
+}
+
+// SectorOfBlock returns the sector containing block, or -1 if the block is not on the card
+func (c *Card) SectorOfBlock(block int) int {
+	if block < 0 || block >= c.Blocks {
+		return -1
+	}
+	return sectorOfBlock(block)
+}
+
+// FirstBlockOfSector returns the first block of a sector, or -1 if the sector is not on the card
+func (c *Card) FirstBlockOfSector(sector int) int {
+	if sector < 0 || sector >= c.Sectors() {
+		return -1
+	}
+	return firstBlockOfSector(sector)
+}
+
+// BlocksInSector returns the number of blocks in a sector including its trailer,
+// or 0 if the sector is not on the card
+func (c *Card) BlocksInSector(sector int) int {
+	if sector < 0 || sector >= c.Sectors() {
+		return 0
+	}
+	return blocksInSector(sector)
+}
+
+// TrailerOfSector returns the sector trailer block of a sector, or -1 if the sector is not on the card
+func (c *Card) TrailerOfSector(sector int) int {
+	if sector < 0 || sector >= c.Sectors() {
+		return -1
+	}
+	return trailerOfSector(sector)
+}
+
+// IsTrailer reports whether block is a sector trailer
+func (c *Card) IsTrailer(block int) bool {
+	sector := c.SectorOfBlock(block)
+	return sector >= 0 && trailerOfSector(sector) == block
+}
+
+// ultralightDataStart is the first user page of Ultralight and NTAG cards. Pages 0-3 hold
+// the UID, the static lock bits and the OTP bits.
+const ultralightDataStart = 4
+
+// IsConfigPage reports whether a page of an Ultralight or NTAG card holds the UID, lock
+// bits, OTP bits or configuration (AUTH0, ACCESS, PWD, PACK or the Ultralight C key).
+// Lock and OTP bits can only be set, so a wrong value there can lock the card for good.
+func (c *Card) IsConfigPage(page int) bool {
+	if page < ultralightDataStart {
+		return true
+	}
+
+	switch c.Type {
+	case CardTypeNTAG213, CardTypeNTAG215, CardTypeNTAG216:
+		// Dynamic lock bits, CFG0, CFG1, PWD and PACK
+		return page >= c.Blocks-5
+	case CardTypeMifareULEV1:
+		// The 20-page MF0UL11 has no dynamic lock bits before its four configuration pages
+		if c.Blocks == 20 {
+			return page >= c.Blocks-4
+		}
+		return page >= c.Blocks-5
+	case CardTypeMifareULC:
+		// Dynamic lock bits, counter, AUTH0, AUTH1 and the 3DES key
+		return page >= c.Blocks-8
+	}
+	return false
+}
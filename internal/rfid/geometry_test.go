@@ -0,0 +1,56 @@
+package rfid
+
+import "testing"
+
+func TestClassicGeometry(t *testing.T) {
+	card4K := &Card{Type: CardTypeMifare4K, Blocks: 256}
+	card1K := &Card{Type: CardTypeMifare1K, Blocks: 64}
+	mini := &Card{Type: CardTypeMifareMini, Blocks: 20}
+
+	if got := card4K.Sectors(); got != 40 {
+		t.Errorf("4K Sectors() = %d, want 40", got)
+	}
+	if got := card1K.Sectors(); got != 16 {
+		t.Errorf("1K Sectors() = %d, want 16", got)
+	}
+	if got := mini.Sectors(); got != 5 {
+		t.Errorf("Mini Sectors() = %d, want 5", got)
+	}
+
+	tests := []struct {
+		block   int
+		sector  int
+		first   int
+		trailer int
+	}{
+		{0, 0, 0, 3},
+		{7, 1, 4, 7},
+		{127, 31, 124, 127},
+		{128, 32, 128, 143},
+		{200, 36, 192, 207},
+		{255, 39, 240, 255},
+	}
+
+	for _, tt := range tests {
+		sector := card4K.SectorOfBlock(tt.block)
+		if sector != tt.sector {
+			t.Errorf("SectorOfBlock(%d) = %d, want %d", tt.block, sector, tt.sector)
+		}
+		if got := card4K.FirstBlockOfSector(sector); got != tt.first {
+			t.Errorf("FirstBlockOfSector(%d) = %d, want %d", sector, got, tt.first)
+		}
+		if got := card4K.TrailerOfSector(sector); got != tt.trailer {
+			t.Errorf("TrailerOfSector(%d) = %d, want %d", sector, got, tt.trailer)
+		}
+	}
+
+	if !card4K.IsTrailer(143) || card4K.IsTrailer(131) {
+		t.Error("IsTrailer() misidentified 4K trailers")
+	}
+	if card1K.SectorOfBlock(64) != -1 {
+		t.Error("SectorOfBlock() accepted a block beyond the end of a 1K card")
+	}
+	if card1K.TrailerOfSector(16) != -1 {
+		t.Error("TrailerOfSector() accepted a sector beyond the end of a 1K card")
+	}
+}
@@ -0,0 +1,230 @@
+package rfid
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyCheckProgress reports how far a dictionary check has come
+type KeyCheckProgress struct {
+	Sector   int // Sector being checked
+	Sectors  int // Number of sectors on the card
+	Attempts int // Authentications tried so far
+	Total    int // Authentications needed if no key is found
+	Found    int // Keys found so far, counting Key A and Key B separately
+}
+
+// FoundKeys holds the keys a dictionary check found for one sector; nil means not found
+type FoundKeys struct {
+	KeyA []byte
+	KeyB []byte
+}
+
+// KeyCheckResult maps each sector of a card to the keys found for it
+type KeyCheckResult struct {
+	Sectors  map[int]FoundKeys
+	Attempts int
+}
+
+// Apply stores the found keys in a keyring; keys that were not found are left unchanged
+func (res *KeyCheckResult) Apply(keyring *Keyring) {
+	for sector, keys := range res.Sectors {
+		if keys.KeyA != nil {
+			_ = keyring.SetKey(sector, KeyA, keys.KeyA)
+		}
+		if keys.KeyB != nil {
+			_ = keyring.SetKey(sector, KeyB, keys.KeyB)
+		}
+	}
+}
+
+// ParseDictionary reads keys in the common .dic format: one 12-digit hex key per line.
+// Blank lines and text after # are ignored and duplicate keys are dropped.
+func ParseDictionary(r io.Reader) ([][]byte, error) {
+	var keys [][]byte
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		key, err := hex.DecodeString(text)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("line %d: invalid key %q", line, text)
+		}
+		if !seen[string(key)] {
+			seen[string(key)] = true
+			keys = append(keys, key)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// LoadDictionary reads a .dic key dictionary file
+func LoadDictionary(filename string) ([][]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return ParseDictionary(file)
+}
+
+// CheckKeys tries every dictionary key as Key A and Key B against every sector of the
+// last card. Keys found for earlier sectors are tried first, since cards often reuse them.
+// The found keys are added to the card's keyring, so a following ReadCard can use them.
+// progress, if not nil, is called on the reader's worker after each authentication attempt.
+func (r *Reader) CheckKeys(dictionary [][]byte, progress func(KeyCheckProgress)) (*KeyCheckResult, error) {
+	return r.CheckKeysContext(context.Background(), dictionary, progress)
+}
+
+// CheckKeysContext is CheckKeys stopped once ctx is done; the keys found until then are
+// returned with the error. A large dictionary takes far longer than a single operation,
+// so the operation timeout does not apply.
+func (r *Reader) CheckKeysContext(ctx context.Context, dictionary [][]byte, progress func(KeyCheckProgress)) (*KeyCheckResult, error) {
+	return callCancelable(ctx, r, func() (*KeyCheckResult, error) {
+		return r.checkKeys(dictionary, progress)
+	})
+}
+
+// checkKeys implements CheckKeys
+func (r *Reader) checkKeys(dictionary [][]byte, progress func(KeyCheckProgress)) (*KeyCheckResult, error) {
+	if r.lastCard == nil {
+		return nil, fmt.Errorf("no card selected")
+	}
+
+	card := r.lastCard
+	if !card.IsClassic() {
+		return nil, fmt.Errorf("%s cards do not use MIFARE Classic keys", card.Type)
+	}
+
+	if len(dictionary) == 0 {
+		return nil, fmt.Errorf("dictionary is empty")
+	}
+	for _, key := range dictionary {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key must be exactly %d bytes", KeySize)
+		}
+	}
+
+	result := &KeyCheckResult{Sectors: make(map[int]FoundKeys)}
+	state := KeyCheckProgress{Sectors: card.Sectors(), Total: card.Sectors() * 2 * len(dictionary)}
+	var found [][]byte
+
+	for sector := 0; sector < card.Sectors(); sector++ {
+		state.Sector = sector
+		var keys FoundKeys
+
+		for _, keyType := range []KeyType{KeyA, KeyB} {
+			for _, key := range candidateKeys(found, dictionary) {
+				ok, err := r.tryKey(sector, keyType, key)
+				result.Attempts++
+				state.Attempts++
+				if err != nil {
+					return result, err
+				}
+				if !ok {
+					reportProgress(progress, state)
+					continue
+				}
+
+				if keyType == KeyA {
+					keys.KeyA = key
+				} else {
+					keys.KeyB = key
+				}
+				if !containsKey(found, key) {
+					found = append(found, key)
+				}
+				state.Found++
+				reportProgress(progress, state)
+				break
+			}
+		}
+
+		if keys.KeyA != nil || keys.KeyB != nil {
+			result.Sectors[sector] = keys
+		}
+
+		// Account for the attempts skipped after a hit
+		state.Attempts = (sector + 1) * 2 * len(dictionary)
+	}
+
+	result.Apply(card.Keys)
+	r.publish()
+	reportProgress(progress, state)
+	return result, nil
+}
+
+// tryKey authenticates one sector with a key. Transient errors are retried before the key
+// is taken as wrong. A failed attempt reactivates the card; an error means the card could
+// not be woken up again.
+func (r *Reader) tryKey(sector int, keyType KeyType, key []byte) (bool, error) {
+	trailer := r.lastCard.TrailerOfSector(sector)
+	err := r.retry("key check", func() error {
+		return r.authenticate(byte(keyType), trailer, key, r.lastCard.UID)
+	}, r.reactivate)
+	if err == nil {
+		r.authSector = sector
+		r.authKey = keyType
+		return true, nil
+	}
+
+	if err := r.reactivate(); err != nil {
+		return false, fmt.Errorf("card lost during key check at sector %d: %w", sector, err)
+	}
+	return false, nil
+}
+
+// reportProgress calls the progress callback if there is one
+func reportProgress(progress func(KeyCheckProgress), state KeyCheckProgress) {
+	if progress != nil {
+		progress(state)
+	}
+}
+
+// candidateKeys returns the found keys followed by the remaining dictionary keys
+func candidateKeys(found, dictionary [][]byte) [][]byte {
+	if len(found) == 0 {
+		return dictionary
+	}
+
+	candidates := make([][]byte, 0, len(dictionary))
+	candidates = append(candidates, found...)
+	for _, key := range dictionary {
+		if !containsKey(found, key) {
+			candidates = append(candidates, key)
+		}
+	}
+	return candidates
+}
+
+// containsKey reports whether key is in keys
+func containsKey(keys [][]byte, key []byte) bool {
+	for _, k := range keys {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package rfid
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseDictionary(t *testing.T) {
+	dic := `# Vendor keys
+FFFFFFFFFFFF
+a0a1a2a3a4a5   # lower case is fine
+
+B0B1B2B3B4B5
+FFFFFFFFFFFF
+`
+	keys, err := ParseDictionary(strings.NewReader(dic))
+	if err != nil {
+		t.Fatalf("ParseDictionary() error = %v", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("ParseDictionary() returned %d keys, want 3", len(keys))
+	}
+	if !bytes.Equal(keys[1], testKeyA) {
+		t.Errorf("ParseDictionary() key 1 = %x, want %x", keys[1], testKeyA)
+	}
+
+	if _, err := ParseDictionary(strings.NewReader("FFFFFFFFFF\n")); err == nil {
+		t.Error("ParseDictionary() accepted a short key")
+	}
+}
+
+func TestCheckKeys(t *testing.T) {
+	unknownKey := []byte{0x11, 0x11, 0x11, 0x11, 0x11, 0x11}
+
+	card := NewVirtualClassic1K([]byte{0x21, 0x22, 0x23, 0x24})
+	card.SetBlock(7, testTrailer())
+	// Sector 2 only has a dictionary Key B
+	trailer := testTrailer()
+	copy(trailer[:6], unknownKey)
+	card.SetBlock(11, trailer)
+	card.SetBlock(9, bytes.Repeat([]byte{0x99}, 16))
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	dictionary := [][]byte{testKeyB, testKeyA, factoryKey()}
+	var last KeyCheckProgress
+	calls := 0
+	result, err := reader.CheckKeys(dictionary, func(p KeyCheckProgress) {
+		last = p
+		calls++
+	})
+	if err != nil {
+		t.Fatalf("CheckKeys() error = %v", err)
+	}
+
+	if len(result.Sectors) != 16 {
+		t.Errorf("CheckKeys() found keys for %d sectors, want 16", len(result.Sectors))
+	}
+	if got := result.Sectors[1]; !bytes.Equal(got.KeyA, testKeyA) || !bytes.Equal(got.KeyB, testKeyB) {
+		t.Errorf("sector 1 keys = %x/%x", got.KeyA, got.KeyB)
+	}
+	if got := result.Sectors[2]; got.KeyA != nil || !bytes.Equal(got.KeyB, testKeyB) {
+		t.Errorf("sector 2 keys = %x/%x, want only Key B", got.KeyA, got.KeyB)
+	}
+	if got := result.Sectors[0]; !bytes.Equal(got.KeyA, factoryKey()) {
+		t.Errorf("sector 0 Key A = %x, want %x", got.KeyA, factoryKey())
+	}
+	if calls == 0 || last.Attempts != last.Total || last.Sectors != 16 {
+		t.Errorf("final progress = %+v after %d calls", last, calls)
+	}
+
+	// The found keys feed the keyring used by ReadCard
+	dump, err := reader.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+	if len(dump) != 64 || !bytes.Equal(dump[9], card.Block(9)) {
+		t.Errorf("ReadCard() after CheckKeys() returned %d blocks, block 9 = %x", len(dump), dump[9])
+	}
+}
+
+func TestCheckKeysTransientError(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0x21, 0x22, 0x23, 0x24})
+	reader, sim := newSimulatedReader(t, card)
+	reader.SetRetryPolicy(RetryPolicy{Retries: 2})
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// A damaged answer to the right key is retried instead of rejecting the key
+	sim.InjectAuthError(errParity)
+	result, err := reader.CheckKeys([][]byte{factoryKey()}, nil)
+	if err != nil {
+		t.Fatalf("CheckKeys() error = %v", err)
+	}
+	if got := result.Sectors[0]; !bytes.Equal(got.KeyA, factoryKey()) {
+		t.Errorf("sector 0 Key A = %x after a parity error, want %x", got.KeyA, factoryKey())
+	}
+	if stats := reader.Stats()["key check"]; stats.Retries != 1 {
+		t.Errorf("key check stats = %+v, want 1 retry", stats)
+	}
+}
@@ -0,0 +1,250 @@
+package rfid
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// KeySize is the size of a MIFARE Classic key in bytes
+const KeySize = 6
+
+// KeyType selects MIFARE Classic Key A or Key B
+type KeyType byte
+
+// Key types, encoded as the matching authentication command
+const (
+	KeyA KeyType = PICCAuthent1A
+	KeyB KeyType = PICCAuthent1B
+)
+
+// String returns a string representation of the key type
+func (k KeyType) String() string {
+	switch k {
+	case KeyA:
+		return "Key A"
+	case KeyB:
+		return "Key B"
+	default:
+		return "unknown key"
+	}
+}
+
+// sectorKeys holds Key A and Key B of one sector; a nil key is unknown
+type sectorKeys struct {
+	keyA []byte
+	keyB []byte
+}
+
+// get returns the key of the given type
+func (s sectorKeys) get(keyType KeyType) []byte {
+	if keyType == KeyB {
+		return s.keyB
+	}
+	return s.keyA
+}
+
+// set replaces the key of the given type
+func (s *sectorKeys) set(keyType KeyType, key []byte) {
+	if keyType == KeyB {
+		s.keyB = key
+	} else {
+		s.keyA = key
+	}
+}
+
+// Keyring maps MIFARE Classic sectors to their Key A and Key B.
+// Sectors without an explicit key fall back to the default keys, which start
+// out as the factory default FF FF FF FF FF FF.
+type Keyring struct {
+	defaults sectorKeys
+	sectors  map[int]sectorKeys
+}
+
+// NewKeyring creates a keyring holding only the factory default keys
+func NewKeyring() *Keyring {
+	return &Keyring{
+		defaults: sectorKeys{keyA: factoryKey(), keyB: factoryKey()},
+		sectors:  make(map[int]sectorKeys),
+	}
+}
+
+// factoryKey returns the transport key of blank MIFARE Classic cards
+func factoryKey() []byte {
+	return []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+}
+
+// checkKey validates a key type and key length; a nil key is allowed and means unknown
+func checkKey(keyType KeyType, key []byte) error {
+	if keyType != KeyA && keyType != KeyB {
+		return fmt.Errorf("invalid key type 0x%02x", byte(keyType))
+	}
+	if key != nil && len(key) != KeySize {
+		return fmt.Errorf("key must be exactly %d bytes", KeySize)
+	}
+	return nil
+}
+
+// SetKey sets the key of one sector. A nil key marks it as unknown.
+func (k *Keyring) SetKey(sector int, keyType KeyType, key []byte) error {
+	if err := checkKey(keyType, key); err != nil {
+		return err
+	}
+	if sector < 0 {
+		return fmt.Errorf("invalid sector %d", sector)
+	}
+
+	keys, ok := k.sectors[sector]
+	if !ok {
+		keys = k.defaults
+	}
+	if key != nil {
+		key = append([]byte(nil), key...)
+	}
+	keys.set(keyType, key)
+	k.sectors[sector] = keys
+	return nil
+}
+
+// SetDefaultKey sets the key used for sectors without an explicit key.
+// A nil key leaves those sectors without a key of that type.
+func (k *Keyring) SetDefaultKey(keyType KeyType, key []byte) error {
+	if err := checkKey(keyType, key); err != nil {
+		return err
+	}
+
+	if key != nil {
+		key = append([]byte(nil), key...)
+	}
+	k.defaults.set(keyType, key)
+	return nil
+}
+
+// Key returns the key of a sector, or nil if it is unknown
+func (k *Keyring) Key(sector int, keyType KeyType) []byte {
+	keys, ok := k.sectors[sector]
+	if !ok {
+		keys = k.defaults
+	}
+	return keys.get(keyType)
+}
+
+// Clone returns an independent copy of the keyring; a nil keyring clones to the defaults
+func (k *Keyring) Clone() *Keyring {
+	if k == nil {
+		return NewKeyring()
+	}
+
+	clone := &Keyring{defaults: k.defaults, sectors: make(map[int]sectorKeys, len(k.sectors))}
+	for sector, keys := range k.sectors {
+		clone.sectors[sector] = keys
+	}
+	return clone
+}
+
+// keyFileEntry is the JSON form of a sector's keys
+type keyFileEntry struct {
+	KeyA string `json:"key_a,omitempty"`
+	KeyB string `json:"key_b,omitempty"`
+}
+
+// keyFile is the JSON form of a keyring:
+//
+//	{
+//	    "default": {"key_a": "FFFFFFFFFFFF", "key_b": "FFFFFFFFFFFF"},
+//	    "sectors": {"1": {"key_a": "A0A1A2A3A4A5", "key_b": "B0B1B2B3B4B5"}}
+//	}
+type keyFile struct {
+	Default *keyFileEntry           `json:"default,omitempty"`
+	Sectors map[string]keyFileEntry `json:"sectors"`
+}
+
+// parseKey decodes a hex key; an empty string is an unknown key
+func parseKey(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %q: %w", s, err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key %q: must be exactly %d bytes", s, KeySize)
+	}
+	return key, nil
+}
+
+// parseEntry decodes both keys of a key file entry
+func parseEntry(entry keyFileEntry) (sectorKeys, error) {
+	keyA, err := parseKey(entry.KeyA)
+	if err != nil {
+		return sectorKeys{}, err
+	}
+	keyB, err := parseKey(entry.KeyB)
+	if err != nil {
+		return sectorKeys{}, err
+	}
+	return sectorKeys{keyA: keyA, keyB: keyB}, nil
+}
+
+// LoadKeyring loads a keyring from a JSON key file.
+// Without a "default" entry the factory keys are used for unlisted sectors;
+// keys missing from a sector entry are unknown.
+func LoadKeyring(filename string) (*Keyring, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	keyring := NewKeyring()
+	if file.Default != nil {
+		if keyring.defaults, err = parseEntry(*file.Default); err != nil {
+			return nil, fmt.Errorf("default keys: %w", err)
+		}
+	}
+
+	for name, entry := range file.Sectors {
+		sector, err := strconv.Atoi(name)
+		if err != nil || sector < 0 {
+			return nil, fmt.Errorf("invalid sector %q in key file", name)
+		}
+		if keyring.sectors[sector], err = parseEntry(entry); err != nil {
+			return nil, fmt.Errorf("sector %d: %w", sector, err)
+		}
+	}
+
+	return keyring, nil
+}
+
+// Save writes the keyring to a JSON key file
+func (k *Keyring) Save(filename string) error {
+	file := keyFile{
+		Default: &keyFileEntry{
+			KeyA: hex.EncodeToString(k.defaults.keyA),
+			KeyB: hex.EncodeToString(k.defaults.keyB),
+		},
+		Sectors: make(map[string]keyFileEntry, len(k.sectors)),
+	}
+
+	for sector, keys := range k.sectors {
+		file.Sectors[strconv.Itoa(sector)] = keyFileEntry{
+			KeyA: hex.EncodeToString(keys.keyA),
+			KeyB: hex.EncodeToString(keys.keyB),
+		}
+	}
+
+	data, err := json.MarshalIndent(file, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0600)
+}
@@ -0,0 +1,176 @@
+package rfid
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+var (
+	testKeyA = []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+	testKeyB = []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5}
+)
+
+// testTrailer builds a trailer whose data blocks are readable with A or B and
+// writable with B only (access bytes 78 77 88)
+func testTrailer() []byte {
+	trailer := append([]byte(nil), testKeyA...)
+	trailer = append(trailer, 0x78, 0x77, 0x88, 0x69)
+	return append(trailer, testKeyB...)
+}
+
+func TestKeyringDefaults(t *testing.T) {
+	keyring := NewKeyring()
+	if !bytes.Equal(keyring.Key(5, KeyA), factoryKey()) || !bytes.Equal(keyring.Key(5, KeyB), factoryKey()) {
+		t.Error("NewKeyring() does not default to the factory keys")
+	}
+
+	if err := keyring.SetKey(5, KeyB, testKeyB); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	if !bytes.Equal(keyring.Key(5, KeyA), factoryKey()) || !bytes.Equal(keyring.Key(5, KeyB), testKeyB) {
+		t.Errorf("Key(5) = %x/%x", keyring.Key(5, KeyA), keyring.Key(5, KeyB))
+	}
+
+	clone := keyring.Clone()
+	if err := clone.SetKey(5, KeyB, nil); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	if clone.Key(5, KeyB) != nil || keyring.Key(5, KeyB) == nil {
+		t.Error("Clone() shares sector keys with the original")
+	}
+
+	if err := keyring.SetKey(1, KeyA, []byte{0x01}); err == nil {
+		t.Error("SetKey() accepted a short key")
+	}
+}
+
+func TestKeyringFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "keys.json")
+	keyFile := `{
+		"default": {"key_a": "FFFFFFFFFFFF"},
+		"sectors": {"1": {"key_a": "A0A1A2A3A4A5", "key_b": "B0B1B2B3B4B5"}}
+	}`
+	if err := os.WriteFile(filename, []byte(keyFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := LoadKeyring(filename)
+	if err != nil {
+		t.Fatalf("LoadKeyring() error = %v", err)
+	}
+	if !bytes.Equal(keyring.Key(1, KeyA), testKeyA) || !bytes.Equal(keyring.Key(1, KeyB), testKeyB) {
+		t.Errorf("sector 1 keys = %x/%x", keyring.Key(1, KeyA), keyring.Key(1, KeyB))
+	}
+	if keyring.Key(2, KeyB) != nil {
+		t.Errorf("sector 2 Key B = %x, want unknown", keyring.Key(2, KeyB))
+	}
+
+	if err := keyring.Save(filename); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	reloaded, err := LoadKeyring(filename)
+	if err != nil {
+		t.Fatalf("LoadKeyring() after Save() error = %v", err)
+	}
+	if !bytes.Equal(reloaded.Key(1, KeyB), testKeyB) || reloaded.Key(2, KeyB) != nil {
+		t.Error("Save() did not round-trip the keyring")
+	}
+}
+
+func TestReaderKeyringFromConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "keys.json")
+	keyFile := `{"sectors": {"1": {"key_a": "A0A1A2A3A4A5", "key_b": "B0B1B2B3B4B5"}}}`
+	if err := os.WriteFile(filename, []byte(keyFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	card := NewVirtualClassic1K([]byte{0x0A, 0x0B, 0x0C, 0x0D})
+	card.SetBlock(7, testTrailer())
+	card.SetBlock(5, bytes.Repeat([]byte{0x33}, 16))
+
+	sim := NewSimulator()
+	sim.AddCard(card)
+	cfg := config.Default().RFID
+	cfg.KeyFile = filename
+	reader, err := NewReaderWithTransport(sim, cfg)
+	if err != nil {
+		t.Fatalf("NewReaderWithTransport() error = %v", err)
+	}
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	got, err := reader.ReadBlock(5)
+	if err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if !bytes.Equal(got, card.Block(5)) {
+		t.Errorf("ReadBlock() = %x, want %x", got, card.Block(5))
+	}
+
+	// The access bits only allow writes with Key B
+	data := bytes.Repeat([]byte{0x44}, 16)
+	if err := reader.WriteBlock(5, data); err != nil {
+		t.Fatalf("WriteBlock() error = %v", err)
+	}
+	if !bytes.Equal(card.Block(5), data) {
+		t.Errorf("card block 5 = %x, want %x", card.Block(5), data)
+	}
+	if reader.authKey != KeyB {
+		t.Errorf("WriteBlock() authenticated with %s, want Key B", reader.authKey)
+	}
+
+	// Without Key B the write must be refused before reaching the card
+	if err := reader.SetCardKey(1, KeyB, nil); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	reader.StopCrypto()
+	if err := reader.WriteBlock(4, data); err == nil {
+		t.Error("WriteBlock() succeeded without Key B")
+	}
+
+	dump, err := reader.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+	if len(dump) != 64 || !bytes.Equal(dump[5], data) {
+		t.Errorf("ReadCard() returned %d blocks, block 5 = %x", len(dump), dump[5])
+	}
+}
+
+func TestNewReaderMissingKeyFile(t *testing.T) {
+	cfg := config.Default().RFID
+	cfg.KeyFile = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := NewReaderWithTransport(NewSimulator(), cfg); err == nil {
+		t.Error("NewReaderWithTransport() accepted a missing key file")
+	}
+}
+
+func TestCardKeysSnapshot(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualClassic1K([]byte{0x01, 0x02, 0x03, 0x04}))
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// Neither the keyring nor the card handed out reach the keys the reader uses
+	_ = reader.Keyring().SetDefaultKey(KeyA, testKeyA)
+	_ = reader.GetLastCard().Keys.SetKey(1, KeyA, testKeyA)
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Fatalf("ReadBlock() after changing copies error = %v", err)
+	}
+	if key := reader.Keyring().Key(1, KeyA); !bytes.Equal(key, factoryKey()) {
+		t.Errorf("Keyring() key = %x, want the factory key", key)
+	}
+
+	if err := reader.SetCardKey(1, KeyA, testKeyA); err != nil {
+		t.Fatalf("SetCardKey() error = %v", err)
+	}
+	if key := reader.GetLastCard().Keys.Key(1, KeyA); !bytes.Equal(key, testKeyA) {
+		t.Errorf("GetLastCard() key = %x after SetCardKey, want %x", key, testKeyA)
+	}
+}
@@ -0,0 +1,329 @@
+package rfid
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// NTAG21x and Ultralight EV1/C commands
+const (
+	PICCGetVersion = 0x60
+	PICCFastRead   = 0x3A
+	PICCReadCnt    = 0x39
+	PICCReadSig    = 0x3C
+	PICCPwdAuth    = 0x1B
+	PICCULCAuth    = 0x1A
+)
+
+// NTAGNFCCounter is the READ_CNT address of the NTAG21x NFC counter
+const NTAGNFCCounter = 2
+
+// maxFastReadPages is the largest FAST_READ answer that fits in the FIFO together with its CRC
+const maxFastReadPages = (MaxLen - 2) / UltralightPageSize
+
+// VersionInfo is the answer of an Ultralight EV1 or NTAG21x card to GET_VERSION
+type VersionInfo struct {
+	VendorID       byte
+	ProductType    byte
+	ProductSubtype byte
+	MajorVersion   byte
+	MinorVersion   byte
+	StorageSize    byte
+	ProtocolType   byte
+}
+
+// String returns a string representation of the version information
+func (v *VersionInfo) String() string {
+	return fmt.Sprintf("vendor 0x%02x, product 0x%02x/0x%02x, version %d.%d, storage 0x%02x",
+		v.VendorID, v.ProductType, v.ProductSubtype, v.MajorVersion, v.MinorVersion, v.StorageSize)
+}
+
+// GetVersion sends GET_VERSION to the last card
+func (r *Reader) GetVersion() (*VersionInfo, error) {
+	return r.GetVersionContext(context.Background())
+}
+
+// GetVersionContext is GetVersion bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) GetVersionContext(ctx context.Context) (*VersionInfo, error) {
+	return callContext(ctx, r, r.cardVersion)
+}
+
+// cardVersion implements GetVersion
+func (r *Reader) cardVersion() (*VersionInfo, error) {
+	if err := r.checkUltralightPage(0); err != nil {
+		return nil, err
+	}
+
+	version, err := r.getVersion()
+	if err != nil {
+		return nil, fmt.Errorf("get version failed: %w", err)
+	}
+
+	return version, nil
+}
+
+// FastRead reads pages start through end (inclusive) with FAST_READ.
+// Ranges that do not fit in the FIFO are split into several commands.
+func (r *Reader) FastRead(start, end int) ([]byte, error) {
+	return r.FastReadContext(context.Background(), start, end)
+}
+
+// FastReadContext is FastRead bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) FastReadContext(ctx context.Context, start, end int) ([]byte, error) {
+	return callContext(ctx, r, func() ([]byte, error) {
+		return r.fastReadPages(start, end)
+	})
+}
+
+// fastReadPages implements FastRead
+func (r *Reader) fastReadPages(start, end int) ([]byte, error) {
+	if err := r.checkNTAGCommand(start); err != nil {
+		return nil, err
+	}
+	if end < start || end >= r.lastCard.Blocks {
+		return nil, fmt.Errorf("invalid page range %d-%d", start, end)
+	}
+
+	data, err := r.fastRead(start, end)
+	if err != nil {
+		return nil, fmt.Errorf("fast read failed: %w", err)
+	}
+
+	return data, nil
+}
+
+// ReadCounter reads a 24-bit one-way counter with READ_CNT.
+// NTAG21x cards only implement the NFC counter at address NTAGNFCCounter.
+func (r *Reader) ReadCounter(counter int) (uint32, error) {
+	return r.ReadCounterContext(context.Background(), counter)
+}
+
+// ReadCounterContext is ReadCounter bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) ReadCounterContext(ctx context.Context, counter int) (uint32, error) {
+	return callContext(ctx, r, func() (uint32, error) {
+		return r.readCounter(counter)
+	})
+}
+
+// readCounter implements ReadCounter
+func (r *Reader) readCounter(counter int) (uint32, error) {
+	if err := r.checkNTAGCommand(0); err != nil {
+		return 0, err
+	}
+
+	backData, err := r.toCard2(PCDTransceive, r.appendCRC([]byte{PICCReadCnt, byte(counter)}))
+	if err != nil {
+		return 0, fmt.Errorf("read counter failed: %w", err)
+	}
+
+	data, err := r.stripCRC(backData, 3)
+	if err != nil {
+		return 0, fmt.Errorf("read counter failed: %w", err)
+	}
+
+	// The counter is transmitted least significant byte first
+	return uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16, nil
+}
+
+// ReadSignature reads the 32-byte ECC originality signature with READ_SIG
+func (r *Reader) ReadSignature() ([]byte, error) {
+	return r.ReadSignatureContext(context.Background())
+}
+
+// ReadSignatureContext is ReadSignature bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) ReadSignatureContext(ctx context.Context) ([]byte, error) {
+	return callContext(ctx, r, r.readSignature)
+}
+
+// readSignature implements ReadSignature
+func (r *Reader) readSignature() ([]byte, error) {
+	if err := r.checkNTAGCommand(0); err != nil {
+		return nil, err
+	}
+
+	backData, err := r.toCard2(PCDTransceive, r.appendCRC([]byte{PICCReadSig, 0x00}))
+	if err != nil {
+		return nil, fmt.Errorf("read signature failed: %w", err)
+	}
+
+	signature, err := r.stripCRC(backData, 32)
+	if err != nil {
+		return nil, fmt.Errorf("read signature failed: %w", err)
+	}
+
+	return signature, nil
+}
+
+// PasswordAuth unlocks password-protected pages with PWD_AUTH and returns the card's
+// 2-byte password acknowledge (PACK). Callers should compare the PACK with the expected
+// value to verify that the tag is genuine.
+//
+// The card stays unlocked only until it is halted, which presence polling may do between
+// two operations. Access protected pages within a Session that starts with
+// Session.PasswordAuth instead.
+func (r *Reader) PasswordAuth(password []byte) ([]byte, error) {
+	return r.PasswordAuthContext(context.Background(), password)
+}
+
+// PasswordAuthContext is PasswordAuth bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) PasswordAuthContext(ctx context.Context, password []byte) ([]byte, error) {
+	return callContext(ctx, r, func() ([]byte, error) {
+		return r.passwordAuth(password)
+	})
+}
+
+// passwordAuth implements PasswordAuth
+func (r *Reader) passwordAuth(password []byte) ([]byte, error) {
+	if err := r.checkNTAGCommand(0); err != nil {
+		return nil, err
+	}
+
+	if len(password) != 4 {
+		return nil, fmt.Errorf("password must be exactly 4 bytes")
+	}
+
+	buff := []byte{PICCPwdAuth}
+	buff = append(buff, password...)
+	backData, err := r.toCard2(PCDTransceive, r.appendCRC(buff))
+	var pack []byte
+	switch {
+	case err != nil:
+	case len(backData) == 1:
+		// A wrong password is answered with a NAK
+		err = r.nakError(backData[0] & 0x0F)
+	default:
+		pack, err = r.stripCRC(backData, 2)
+	}
+	if err != nil {
+		// The card must be woken up again after a failed authentication
+		_ = r.reactivate()
+		return nil, fmt.Errorf("password authentication failed: %w", err)
+	}
+
+	return pack, nil
+}
+
+// identifyUltralight refines an Ultralight baseline card using GET_VERSION
+func (r *Reader) identifyUltralight(card *Card) {
+	version, err := r.getVersion()
+	if err == nil {
+		card.Version = version
+		for _, entry := range ultralightVersionTable {
+			if entry.productType == version.ProductType && entry.storageSize == version.StorageSize {
+				card.setUltralightType(entry.cardType, entry.pages)
+				break
+			}
+		}
+		return
+	}
+
+	// Plain Ultralight and Ultralight C do not implement GET_VERSION and return to IDLE
+	if r.reactivate() != nil {
+		return
+	}
+
+	if r.isUltralightC() {
+		card.setUltralightType(CardTypeMifareULC, ultralightCPages)
+	}
+
+	// Abandon the 3DES authentication started by the probe
+	_ = r.reactivate()
+}
+
+// getVersion sends GET_VERSION and decodes the 8-byte answer
+func (r *Reader) getVersion() (*VersionInfo, error) {
+	backData, err := r.toCard2(PCDTransceive, r.appendCRC([]byte{PICCGetVersion}))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := r.stripCRC(backData, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	// Byte 0 is a fixed header
+	return &VersionInfo{
+		VendorID:       data[1],
+		ProductType:    data[2],
+		ProductSubtype: data[3],
+		MajorVersion:   data[4],
+		MinorVersion:   data[5],
+		StorageSize:    data[6],
+		ProtocolType:   data[7],
+	}, nil
+}
+
+// isUltralightC starts a 3DES authentication, which only Ultralight C answers
+func (r *Reader) isUltralightC() bool {
+	backData, err := r.toCard2(PCDTransceive, r.appendCRC([]byte{PICCULCAuth, 0x00}))
+	if err != nil {
+		return false
+	}
+
+	data, err := r.stripCRC(backData, 9)
+	return err == nil && data[0] == 0xAF
+}
+
+// fastRead reads a page range in FIFO-sized FAST_READ chunks
+func (r *Reader) fastRead(start, end int) ([]byte, error) {
+	data := make([]byte, 0, (end-start+1)*UltralightPageSize)
+
+	for first := start; first <= end; first += maxFastReadPages {
+		last := first + maxFastReadPages - 1
+		if last > end {
+			last = end
+		}
+
+		backData, err := r.toCard2(PCDTransceive, r.appendCRC([]byte{PICCFastRead, byte(first), byte(last)}))
+		if err != nil {
+			return nil, err
+		}
+
+		pages, err := r.stripCRC(backData, (last-first+1)*UltralightPageSize)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, pages...)
+	}
+
+	return data, nil
+}
+
+// readUltralightFast dumps the whole card with FAST_READ
+func (r *Reader) readUltralightFast() (map[int][]byte, bool) {
+	card := r.lastCard
+
+	dump, err := r.fastRead(0, card.Blocks-1)
+	if err != nil {
+		log.Printf("Warning: FAST_READ failed, falling back to READ: %v", err)
+		_ = r.reactivate()
+		return nil, false
+	}
+
+	data := make(map[int][]byte)
+	for page := 0; page < card.Blocks; page++ {
+		data[page] = dump[page*UltralightPageSize : (page+1)*UltralightPageSize]
+	}
+
+	return data, true
+}
+
+// checkNTAGCommand validates that the last card implements the EV1/NTAG command set
+func (r *Reader) checkNTAGCommand(page int) error {
+	if err := r.checkUltralightPage(page); err != nil {
+		return err
+	}
+
+	if r.lastCard.Version == nil {
+		return fmt.Errorf("%s cards do not support NTAG commands", r.lastCard.Type)
+	}
+
+	return nil
+}
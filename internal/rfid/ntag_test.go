@@ -0,0 +1,237 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+)
+
+var testNTAGUID = []byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0xF6}
+
+func TestUltralightFamilyDetection(t *testing.T) {
+	tests := []struct {
+		card   *VirtualCard
+		want   CardType
+		blocks int
+	}{
+		{NewVirtualUltralight(testNTAGUID), CardTypeMifareUL, 16},
+		{NewVirtualUltralightC(testNTAGUID), CardTypeMifareULC, 48},
+		{NewVirtualNTAG213(testNTAGUID), CardTypeNTAG213, 45},
+		{NewVirtualNTAG215(testNTAGUID), CardTypeNTAG215, 135},
+		{NewVirtualNTAG216(testNTAGUID), CardTypeNTAG216, 231},
+	}
+
+	for _, tt := range tests {
+		reader, _ := newSimulatedReader(t, tt.card)
+
+		card, err := reader.ScanForCard()
+		if err != nil {
+			t.Fatalf("ScanForCard() error = %v", err)
+		}
+		if card.Type != tt.want {
+			t.Errorf("ScanForCard() type = %v, want %v", card.Type, tt.want)
+		}
+		if card.Blocks != tt.blocks || card.Size != tt.blocks*UltralightPageSize {
+			t.Errorf("%v: got %d pages/%d bytes, want %d pages", tt.want, card.Blocks, card.Size, tt.blocks)
+		}
+
+		// The probes must leave the card selected
+		if _, err := reader.ReadPages(0); err != nil {
+			t.Errorf("%v: ReadPages() after identification error = %v", tt.want, err)
+		}
+	}
+}
+
+func TestNTAGFastReadDump(t *testing.T) {
+	card := NewVirtualNTAG216(testNTAGUID)
+	card.SetPage(100, []byte("DUMP"))
+	card.SetPage(226, []byte("LAST"))
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	data, err := reader.FastRead(95, 130)
+	if err != nil {
+		t.Fatalf("FastRead() error = %v", err)
+	}
+	if len(data) != 36*UltralightPageSize {
+		t.Fatalf("FastRead() returned %d bytes, want %d", len(data), 36*UltralightPageSize)
+	}
+	if !bytes.Equal(data[5*UltralightPageSize:6*UltralightPageSize], []byte("DUMP")) {
+		t.Errorf("FastRead() page 100 = %x", data[5*UltralightPageSize:6*UltralightPageSize])
+	}
+
+	dump, err := reader.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+	if len(dump) != 231 {
+		t.Errorf("ReadCard() returned %d pages, want 231", len(dump))
+	}
+	if !bytes.Equal(dump[226], []byte("LAST")) {
+		t.Errorf("ReadCard() page 226 = %x, want %x", dump[226], []byte("LAST"))
+	}
+
+	if _, err := reader.FastRead(10, 231); err == nil {
+		t.Error("FastRead() accepted a range past the end of memory")
+	}
+}
+
+func TestNTAGCounterAndSignature(t *testing.T) {
+	card := NewVirtualNTAG213(testNTAGUID)
+	card.Counter = 0x012345
+	signature := bytes.Repeat([]byte{0x5C}, 32)
+	card.SetSignature(signature)
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	version, err := reader.GetVersion()
+	if err != nil {
+		t.Fatalf("GetVersion() error = %v", err)
+	}
+	if version.ProductType != 0x04 || version.StorageSize != 0x0F {
+		t.Errorf("GetVersion() = %s", version)
+	}
+
+	counter, err := reader.ReadCounter(NTAGNFCCounter)
+	if err != nil {
+		t.Fatalf("ReadCounter() error = %v", err)
+	}
+	if counter != 0x012345 {
+		t.Errorf("ReadCounter() = 0x%06x, want 0x012345", counter)
+	}
+
+	got, err := reader.ReadSignature()
+	if err != nil {
+		t.Fatalf("ReadSignature() error = %v", err)
+	}
+	if !bytes.Equal(got, signature) {
+		t.Errorf("ReadSignature() = %x, want %x", got, signature)
+	}
+}
+
+func TestNTAGPasswordAuth(t *testing.T) {
+	password := []byte{0x12, 0x34, 0x56, 0x78}
+	pack := []byte{0xAB, 0xCD}
+
+	card := NewVirtualNTAG213(testNTAGUID)
+	card.SetPasswordProtection(8, password, pack, true)
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	if _, err := reader.ReadPages(8); err == nil {
+		t.Error("ReadPages() succeeded on a read-protected page")
+	}
+	if _, err := reader.PasswordAuth([]byte{0, 0, 0, 0}); err == nil {
+		t.Error("PasswordAuth() succeeded with the wrong password")
+	}
+
+	// Unprotected pages stay readable after a failed authentication
+	if _, err := reader.ReadPages(4); err != nil {
+		t.Errorf("ReadPages(4) after failed PWD_AUTH error = %v", err)
+	}
+
+	gotPack, err := reader.PasswordAuth(password)
+	if err != nil {
+		t.Fatalf("PasswordAuth() error = %v", err)
+	}
+	if !bytes.Equal(gotPack, pack) {
+		t.Errorf("PasswordAuth() PACK = %x, want %x", gotPack, pack)
+	}
+
+	if err := reader.WritePage(10, []byte("SAFE")); err != nil {
+		t.Fatalf("WritePage() on protected page after PWD_AUTH error = %v", err)
+	}
+	data, err := reader.ReadPages(10)
+	if err != nil {
+		t.Fatalf("ReadPages() after PWD_AUTH error = %v", err)
+	}
+	if !bytes.Equal(data[:4], []byte("SAFE")) {
+		t.Errorf("ReadPages(10) = %x", data[:4])
+	}
+}
+
+func TestNTAGCommandsRequireVersion(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualUltralight(testNTAGUID))
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if _, err := reader.ReadSignature(); err == nil {
+		t.Error("ReadSignature() succeeded on a plain Ultralight")
+	}
+}
+
+func TestNTAGPasswordAuthSession(t *testing.T) {
+	password := []byte{0x12, 0x34, 0x56, 0x78}
+	card := NewVirtualNTAG213(testNTAGUID)
+	card.SetPasswordProtection(8, password, []byte{0xAB, 0xCD}, true)
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// Presence polling wakes the card with WUPA, which drops the authentication
+	if _, err := reader.PasswordAuth(password); err != nil {
+		t.Fatalf("PasswordAuth() error = %v", err)
+	}
+	reader.IsCardPresent()
+	if _, err := reader.ReadPages(8); err == nil {
+		t.Error("ReadPages() on a protected page succeeded after presence polling")
+	}
+
+	// The NAK returned the card to IDLE
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// Within a session polling cannot come between PWD_AUTH and the access
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				reader.IsCardPresent()
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		err := reader.Session(context.Background(), func(s *Session) error {
+			if _, err := s.PasswordAuth(password); err != nil {
+				return err
+			}
+			if err := s.WritePage(10, []byte("SAFE")); err != nil {
+				return err
+			}
+			data, err := s.ReadPages(10)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(data[:4], []byte("SAFE")) {
+				t.Errorf("ReadPages(10) = %x", data[:4])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Session() error = %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
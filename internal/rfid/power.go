@@ -0,0 +1,200 @@
+package rfid
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// commandPowerDown is the PowerDown bit of CommandReg. While it is set the oscillator,
+// the antenna drivers and the receiver are off; the registers keep their values.
+const commandPowerDown = 0x10
+
+// IdlePolicy saves power while no card is used. After Timeout without a card or an
+// operation, the antenna is switched off, and with PowerDown the chip is also put into
+// soft power-down. Presence polling then only switches the field on once every
+// PollInterval; other polls report no card without touching the chip.
+type IdlePolicy struct {
+	Timeout      time.Duration // Zero keeps the antenna on
+	PollInterval time.Duration
+	PowerDown    bool
+}
+
+// SetAntenna switches the antenna on or off. Cards lose power with the field, so they
+// must be scanned again once it is back on. The setting survives a re-initialization.
+func (r *Reader) SetAntenna(on bool) error {
+	return r.run(func() error {
+		r.antennaOff = !on
+		r.applyAntenna()
+		return nil
+	})
+}
+
+// AntennaOn reports whether the antenna is switched on, leaving aside the idle policy
+func (r *Reader) AntennaOn() bool {
+	on, _ := call(r, func() (bool, error) {
+		return !r.antennaOff, nil
+	})
+	return on
+}
+
+// PowerDown puts the MFRC522 into soft power-down, which draws a few µA. The next
+// card operation wakes it, as does Wake; presence polling leaves it asleep.
+func (r *Reader) PowerDown() error {
+	return r.run(func() error {
+		r.powerDown()
+		return nil
+	})
+}
+
+// Wake ends a soft power-down and waits for the oscillator to run again
+func (r *Reader) Wake() error {
+	return r.run(r.wake)
+}
+
+// SetIdlePolicy sets when the reader saves power; a zero policy keeps the antenna on
+func (r *Reader) SetIdlePolicy(policy IdlePolicy) {
+	_ = r.run(func() error {
+		r.idlePolicy = policy
+		if policy.Timeout <= 0 && r.idleTimer != nil {
+			r.idleTimer.Stop()
+			r.idleTimer = nil
+		}
+		r.lastActive = time.Now()
+		r.resetIdleTimer()
+		return nil
+	})
+}
+
+// applyAntenna switches the antenna drivers as set by SetAntenna
+func (r *Reader) applyAntenna() {
+	if r.antennaOff {
+		r.clearRegisterBitMask(TxControlReg, 0x03)
+	} else {
+		r.setRegisterBitMask(TxControlReg, 0x03)
+	}
+}
+
+// powerDown sets the PowerDown bit; the card in the field loses power
+func (r *Reader) powerDown() {
+	r.setRegisterBitMask(CommandReg, commandPowerDown)
+	r.poweredDown = true
+	r.authSector = -1
+}
+
+// wake clears the PowerDown bit. The chip reports the end of the wake-up by clearing
+// the bit once its oscillator is stable.
+func (r *Reader) wake() error {
+	if !r.poweredDown {
+		return nil
+	}
+
+	r.clearRegisterBitMask(CommandReg, commandPowerDown)
+	deadline := time.Now().Add(r.commandTimeout)
+	for r.readRegister(CommandReg)&commandPowerDown != 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: MFRC522 did not wake up", ErrTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	r.poweredDown = false
+	return nil
+}
+
+// activate ends the idle state before a card command and restarts the idle timeout
+func (r *Reader) activate() {
+	r.lastActive = time.Now()
+	if r.idle {
+		r.leaveIdle()
+	} else if err := r.wake(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	r.resetIdleTimer()
+}
+
+// resetIdleTimer starts the idle timeout over
+func (r *Reader) resetIdleTimer() {
+	if r.idlePolicy.Timeout <= 0 {
+		return
+	}
+	if r.idleTimer == nil {
+		r.idleTimer = time.AfterFunc(r.idlePolicy.Timeout, r.idleTimeout)
+	} else {
+		r.idleTimer.Reset(r.idlePolicy.Timeout)
+	}
+}
+
+// idleTimeout runs when the idle timeout may have passed. A chip powered down with
+// PowerDown stays as it is, since idle polling would wake it.
+func (r *Reader) idleTimeout() {
+	_ = r.exec(context.Background(), PriorityBackground, func() error {
+		if r.idle || r.poweredDown || r.idlePolicy.Timeout <= 0 {
+			return nil
+		}
+		if time.Since(r.lastActive) >= r.idlePolicy.Timeout {
+			r.enterIdle()
+		}
+		return nil
+	})
+}
+
+// enterIdle starts the idle state once the idle timeout has passed
+func (r *Reader) enterIdle() {
+	r.idle = true
+	r.powerSave()
+	log.Printf("Reader idle, antenna off")
+}
+
+// powerSave switches the antenna off and, if the policy says so, powers the chip down.
+// Idle polling uses it to go back to sleep after each poll without logging.
+func (r *Reader) powerSave() {
+	r.clearRegisterBitMask(TxControlReg, 0x03)
+	r.authSector = -1
+	if r.idlePolicy.PowerDown {
+		r.powerDown()
+	}
+}
+
+// leaveIdle wakes the chip and switches the antenna back on, giving cards time to power up
+func (r *Reader) leaveIdle() {
+	r.idle = false
+	if err := r.wake(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if !r.antennaOff {
+		r.setRegisterBitMask(TxControlReg, 0x03)
+		time.Sleep(fieldResetDelay)
+	}
+}
+
+// pollCard checks for a card under the idle policy. While idle, the field is only
+// switched on once per poll interval; a card found ends the idle state. A reader switched
+// off with SetAntenna or PowerDown is left alone: any card command would wake it.
+func (r *Reader) pollCard() bool {
+	if r.antennaOff || (r.poweredDown && !r.idle) {
+		return false
+	}
+
+	if !r.idle {
+		present := r.isCardPresent()
+		if present {
+			r.activate()
+		}
+		return present
+	}
+
+	if time.Since(r.lastPoll) < r.idlePolicy.PollInterval {
+		return false
+	}
+	r.lastPoll = time.Now()
+
+	r.leaveIdle()
+	if r.isCardPresent() {
+		r.activate()
+		return true
+	}
+	r.idle = true
+	r.powerSave()
+	return false
+}
@@ -0,0 +1,167 @@
+package rfid
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAntennaSwitch(t *testing.T) {
+	reader, sim := newSimulatedReader(t, NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}))
+
+	if err := reader.SetAntenna(false); err != nil {
+		t.Fatalf("SetAntenna(false) error = %v", err)
+	}
+	if tx, _ := sim.ReadRegister(TxControlReg); tx&0x03 != 0 || reader.AntennaOn() {
+		t.Errorf("TxControlReg = 0x%02x after SetAntenna(false), want the drivers off", tx)
+	}
+	if _, err := reader.ScanForCard(); !errors.Is(err, ErrNoTag) {
+		t.Errorf("ScanForCard() with the antenna off error = %v, want ErrNoTag", err)
+	}
+
+	// A re-initialization keeps the antenna off
+	if _, err := reader.Diagnostics(); err != nil {
+		t.Fatalf("Diagnostics() error = %v", err)
+	}
+	if tx, _ := sim.ReadRegister(TxControlReg); tx&0x03 != 0 {
+		t.Errorf("TxControlReg = 0x%02x after a reset, want the drivers off", tx)
+	}
+
+	if err := reader.SetAntenna(true); err != nil {
+		t.Fatalf("SetAntenna(true) error = %v", err)
+	}
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Errorf("ScanForCard() with the antenna on error = %v", err)
+	}
+}
+
+func TestPowerDown(t *testing.T) {
+	reader, sim := newSimulatedReader(t, NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}))
+
+	if err := reader.PowerDown(); err != nil {
+		t.Fatalf("PowerDown() error = %v", err)
+	}
+	if cmd, _ := sim.ReadRegister(CommandReg); cmd&commandPowerDown == 0 {
+		t.Errorf("CommandReg = 0x%02x after PowerDown(), want the PowerDown bit set", cmd)
+	}
+
+	// Getters and presence polling leave the chip asleep
+	_ = reader.Tuning()
+	_ = reader.AntennaOn()
+	if reader.IsCardPresent() {
+		t.Error("IsCardPresent() = true while powered down")
+	}
+	if cmd, _ := sim.ReadRegister(CommandReg); cmd&commandPowerDown == 0 {
+		t.Errorf("CommandReg = 0x%02x after polling, want the PowerDown bit set", cmd)
+	}
+
+	// The next card operation wakes the chip
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Errorf("ScanForCard() after PowerDown() error = %v", err)
+	}
+	if cmd, _ := sim.ReadRegister(CommandReg); cmd&commandPowerDown != 0 {
+		t.Errorf("CommandReg = 0x%02x after an operation, want the PowerDown bit clear", cmd)
+	}
+
+	if err := reader.PowerDown(); err != nil {
+		t.Fatalf("PowerDown() error = %v", err)
+	}
+	if err := reader.Wake(); err != nil {
+		t.Errorf("Wake() error = %v", err)
+	}
+}
+
+func TestIdlePolicy(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44})
+	reader, sim := newSimulatedReader(t, card)
+	defer reader.Close()
+
+	commands := 0
+	hookCommands(reader, func() { commands++ })
+	reader.SetIdlePolicy(IdlePolicy{Timeout: 30 * time.Millisecond, PollInterval: 200 * time.Millisecond, PowerDown: true})
+
+	// A card on the antenna keeps the reader active while it is polled
+	for i := 0; i < 4; i++ {
+		if !reader.IsCardPresent() {
+			t.Fatalf("poll %d: IsCardPresent() = false with a card on the antenna", i)
+		}
+		time.Sleep(15 * time.Millisecond)
+	}
+
+	sim.RemoveCard(card)
+	if reader.IsCardPresent() {
+		t.Fatal("IsCardPresent() = true after the card was removed")
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	tx, _ := sim.ReadRegister(TxControlReg)
+	cmd, _ := sim.ReadRegister(CommandReg)
+	if tx&0x03 != 0 || cmd&commandPowerDown == 0 {
+		t.Fatalf("TxControlReg = 0x%02x, CommandReg = 0x%02x after the idle timeout, want antenna off and powered down", tx, cmd)
+	}
+
+	// The first idle poll switches the field on; later ones within the interval do not
+	before := commands
+	if reader.IsCardPresent() {
+		t.Error("idle poll found a card that is not there")
+	}
+	if commands == before {
+		t.Error("first idle poll sent no command")
+	}
+	before = commands
+	for i := 0; i < 3; i++ {
+		_ = reader.IsCardPresent()
+	}
+	if commands != before {
+		t.Errorf("polls within the interval sent %d commands, want none", commands-before)
+	}
+	if cmd, _ := sim.ReadRegister(CommandReg); cmd&commandPowerDown == 0 {
+		t.Error("reader left power-down between idle polls")
+	}
+	_ = reader.Tuning()
+	if tx, _ := sim.ReadRegister(TxControlReg); tx&0x03 != 0 {
+		t.Error("Tuning() switched the antenna on while idle")
+	}
+
+	// A card found by an idle poll ends the idle state
+	time.Sleep(200 * time.Millisecond)
+	sim.AddCard(card)
+	if !reader.IsCardPresent() {
+		t.Fatal("idle poll after the interval did not find the card")
+	}
+	if tx, _ := sim.ReadRegister(TxControlReg); tx&0x03 == 0 {
+		t.Error("antenna off after a card was found")
+	}
+
+	// User operations wake the reader at once
+	time.Sleep(60 * time.Millisecond)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Errorf("ScanForCard() on an idle reader error = %v", err)
+	}
+}
+
+func TestIdlePollLogsOnce(t *testing.T) {
+	reader, _ := newSimulatedReader(t)
+	defer reader.Close()
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	reader.SetIdlePolicy(IdlePolicy{Timeout: 10 * time.Millisecond, PollInterval: time.Millisecond})
+	time.Sleep(40 * time.Millisecond)
+
+	// Going back to sleep after an empty idle poll is not a new idle state
+	for i := 0; i < 5; i++ {
+		time.Sleep(2 * time.Millisecond)
+		_ = reader.IsCardPresent()
+	}
+	_ = reader.Tuning() // Wait for the worker before reading the log
+	if n := strings.Count(logged.String(), "Reader idle"); n != 1 {
+		t.Errorf("idle state logged %d times, want once", n)
+	}
+}
@@ -0,0 +1,92 @@
+package rfid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// mfRxParityDisable is the ParityDisable bit of MfRxReg. It switches off both the
+// generation of parity bits on transmission and their check on reception.
+const mfRxParityDisable = 0x10
+
+// RawResponse is the answer to a frame sent with Transceive
+type RawResponse struct {
+	Data      []byte // Bytes received; with crc, without a CRC_A that matched
+	Bits      int    // Valid bits in Data, as reported by RxLastBits
+	Collision int    // Position of the first collided bit counting from 1, or 0 without a collision
+}
+
+// Transceive sends a raw frame to the field and returns the answer, for protocol
+// experiments and tags the reader does not support. txBits is the number of bits of the
+// last byte to send, 0 meaning all 8; rxAlign is the bit position at which the first
+// received bit is stored. With crc, CRC_A is appended to the frame and checked and
+// stripped on answers of 3 bytes or more; a mismatch returns the raw answer with ErrCRC.
+// Without parity, the MFRC522 neither sends nor checks parity bits.
+//
+// The card's state is unknown afterwards, so the next Classic operation authenticates again.
+// A collision is reported in the response rather than as an error. No answer at all is
+// ErrNoTag; an answer without a single complete byte gives an empty response.
+func (r *Reader) Transceive(frame []byte, txBits, rxAlign int, crc, parity bool) (*RawResponse, error) {
+	return r.TransceiveContext(context.Background(), frame, txBits, rxAlign, crc, parity)
+}
+
+// TransceiveContext is Transceive bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) TransceiveContext(ctx context.Context, frame []byte, txBits, rxAlign int, crc, parity bool) (*RawResponse, error) {
+	return callContext(ctx, r, func() (*RawResponse, error) {
+		return r.transceive(frame, txBits, rxAlign, crc, parity)
+	})
+}
+
+// transceive implements Transceive
+func (r *Reader) transceive(frame []byte, txBits, rxAlign int, crc, parity bool) (*RawResponse, error) {
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("frame is empty")
+	}
+	if txBits < 0 || txBits > 7 || rxAlign < 0 || rxAlign > 7 {
+		return nil, fmt.Errorf("bit counts must be between 0 and 7")
+	}
+	if crc && txBits != 0 {
+		return nil, fmt.Errorf("CRC_A can only follow whole bytes")
+	}
+
+	r.authSector = -1
+
+	if crc {
+		frame = r.appendCRC(frame)
+	}
+	if !parity {
+		r.setRegisterBitMask(MfRxReg, mfRxParityDisable)
+		defer r.clearRegisterBitMask(MfRxReg, mfRxParityDisable)
+	}
+
+	r.writeRegister(BitFramingReg, byte(rxAlign<<4|txBits))
+	defer r.writeRegister(BitFramingReg, 0x00)
+
+	backData, err := r.exchange(PCDTransceive, frame, false)
+	response := &RawResponse{Data: backData}
+
+	var collision *CollisionError
+	if errors.As(err, &collision) {
+		response.Collision = collision.Position
+	} else if err != nil {
+		return nil, err
+	}
+
+	response.Bits = len(backData) * 8
+	if lastBits := int(r.readRegister(ControlReg) & 0x07); lastBits != 0 && len(backData) > 0 {
+		response.Bits -= 8 - lastBits
+	}
+
+	if crc && response.Collision == 0 && response.Bits%8 == 0 && len(backData) >= 3 {
+		data, err := r.stripCRC(backData, len(backData)-2)
+		if err != nil {
+			return response, err
+		}
+		response.Data = data
+		response.Bits -= 16
+	}
+
+	return response, nil
+}
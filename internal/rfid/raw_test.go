@@ -0,0 +1,127 @@
+package rfid
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTransceive(t *testing.T) {
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	card := NewVirtualUltralight(uid)
+	card.SetPage(4, []byte{0xCA, 0xFE, 0xBA, 0xBE})
+	reader, _ := newSimulatedReader(t, card)
+
+	steps := []struct {
+		name   string
+		frame  []byte
+		txBits int
+		crc    bool
+		want   []byte
+		bits   int
+	}{
+		{"REQA", []byte{PICCReqIDL}, 7, false, []byte{0x44, 0x00}, 16},
+		{"anticollision CL1", []byte{PICCAntiColl, 0x20}, 0, false, []byte{0x88, 0x04, 0x11, 0x22, 0x88 ^ 0x04 ^ 0x11 ^ 0x22}, 40},
+		{"select CL1", []byte{PICCSelectTag, 0x70, 0x88, 0x04, 0x11, 0x22, 0x88 ^ 0x04 ^ 0x11 ^ 0x22}, 0, true, []byte{0x04}, 8},
+		{"select CL2", []byte{PICCSelectCL2, 0x70, 0x33, 0x44, 0x55, 0x66, 0x33 ^ 0x44 ^ 0x55 ^ 0x66}, 0, true, []byte{0x00}, 8},
+		{"READ", []byte{PICCRead, 4}, 0, true, nil, 128},
+		{"NAK", []byte{PICCRead, 0xF0}, 0, true, []byte{piccNAK}, 4},
+	}
+
+	for _, step := range steps {
+		response, err := reader.Transceive(step.frame, step.txBits, 0, step.crc, true)
+		if err != nil {
+			t.Fatalf("%s: Transceive() error = %v", step.name, err)
+		}
+		if response.Bits != step.bits {
+			t.Errorf("%s: Transceive() bits = %d, want %d", step.name, response.Bits, step.bits)
+		}
+		if step.want != nil && !bytes.Equal(response.Data, step.want) {
+			t.Errorf("%s: Transceive() = %x, want %x", step.name, response.Data, step.want)
+		}
+		if step.name == "READ" && !bytes.HasPrefix(response.Data, []byte{0xCA, 0xFE, 0xBA, 0xBE}) {
+			t.Errorf("READ: Transceive() = %x, want page 4 first", response.Data)
+		}
+	}
+}
+
+func TestTransceiveCollision(t *testing.T) {
+	reader, _ := newSimulatedReader(t,
+		NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}),
+		NewVirtualClassic1K([]byte{0x11, 0x2A, 0x33, 0x44}),
+	)
+
+	if _, err := reader.Transceive([]byte{PICCReqIDL}, 7, 0, false, true); err != nil {
+		t.Fatalf("REQA error = %v", err)
+	}
+	response, err := reader.Transceive([]byte{PICCAntiColl, 0x20}, 0, 0, false, true)
+	if err != nil {
+		t.Fatalf("anticollision error = %v", err)
+	}
+	// 0x22 and 0x2A first differ in bit 3 of the second UID byte
+	if response.Collision != 12 {
+		t.Errorf("collision position = %d, want 12", response.Collision)
+	}
+}
+
+func TestTransceiveErrors(t *testing.T) {
+	reader, _ := newSimulatedReader(t)
+
+	if _, err := reader.Transceive([]byte{PICCReqIDL}, 7, 0, false, true); !errors.Is(err, ErrNoTag) {
+		t.Errorf("Transceive() without a card error = %v, want ErrNoTag", err)
+	}
+	for _, tt := range []struct {
+		frame           []byte
+		txBits, rxAlign int
+		crc             bool
+	}{
+		{nil, 0, 0, false},
+		{[]byte{0x26}, 8, 0, false},
+		{[]byte{0x26}, 0, -1, false},
+		{[]byte{0x26}, 7, 0, true},
+	} {
+		if _, err := reader.Transceive(tt.frame, tt.txBits, tt.rxAlign, tt.crc, true); err == nil {
+			t.Errorf("Transceive(%x, %d, %d, %v) succeeded", tt.frame, tt.txBits, tt.rxAlign, tt.crc)
+		}
+	}
+
+	// Framing and parity are restored for the next command
+	_, _ = reader.Transceive([]byte{PICCReqIDL}, 7, 0, false, false)
+	_ = reader.run(func() error {
+		if framing := reader.readRegister(BitFramingReg); framing != 0 {
+			t.Errorf("BitFramingReg = %#x after Transceive(), want 0", framing)
+		}
+		if reader.readRegister(MfRxReg)&mfRxParityDisable != 0 {
+			t.Error("parity still disabled after Transceive()")
+		}
+		return nil
+	})
+}
+
+// emptyFIFO reports an empty FIFO, as after an answer the MFRC522 received no byte of
+type emptyFIFO struct {
+	Transport
+}
+
+func (e *emptyFIFO) ReadRegister(reg byte) (byte, error) {
+	if reg == FIFOLevelReg {
+		return 0, nil
+	}
+	return e.Transport.ReadRegister(reg)
+}
+
+func TestTransceiveEmptyAnswer(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}))
+	_ = reader.run(func() error {
+		reader.transport = &emptyFIFO{Transport: reader.transport}
+		return nil
+	})
+
+	response, err := reader.Transceive([]byte{PICCReqIDL}, 7, 0, false, true)
+	if err != nil {
+		t.Fatalf("Transceive() error = %v", err)
+	}
+	if len(response.Data) != 0 || response.Bits != 0 {
+		t.Errorf("Transceive() = %x (%d bits) with an empty FIFO, want nothing", response.Data, response.Bits)
+	}
+}
@@ -3,15 +3,19 @@
 package rfid
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"rfid-tool-rpi/internal/config"
 
 	"periph.io/x/conn/v3/gpio"
 	"periph.io/x/conn/v3/gpio/gpioreg"
-	"periph.io/x/conn/v3/physic"
 	"periph.io/x/conn/v3/spi"
 	"periph.io/x/conn/v3/spi/spireg"
 	"periph.io/x/host/v3"
@@ -78,12 +82,13 @@ const (
 	TestADCReg      = 0x3B
 )
 
-// MaxLen defines the maximum length for FIFO operations
-const MaxLen = 16
+// MaxLen defines the maximum length for FIFO operations (the MFRC522 FIFO holds 64 bytes)
+const MaxLen = 64
 
 // MFRC522 commands
 const (
 	PCDIdle       = 0x00
+	PCDMem        = 0x01
 	PCDAuthent    = 0x0E
 	PCDReceive    = 0x08
 	PCDTransmit   = 0x04
@@ -98,6 +103,8 @@ const (
 	PICCReqAll    = 0x52
 	PICCAntiColl  = 0x93
 	PICCSelectTag = 0x93
+	PICCSelectCL2 = 0x95
+	PICCSelectCL3 = 0x97
 	PICCAuthent1A = 0x60
 	PICCAuthent1B = 0x61
 	PICCRead      = 0x30
@@ -109,13 +116,18 @@ const (
 	PICCHalt      = 0x50
 )
 
-// Status codes
+// PICC acknowledge codes (4-bit frames)
 const (
-	MIOK       = 0
-	MINoTagErr = 1
-	MIErr      = 2
+	piccACK = 0x0A
+	piccNAK = 0x04
 )
 
+// PICCCascadeTag marks an incomplete UID in an anticollision answer
+const PICCCascadeTag = 0x88
+
+// cascadeLevels holds the SEL codes of cascade levels 1 to 3
+var cascadeLevels = [...]byte{PICCSelectTag, PICCSelectCL2, PICCSelectCL3}
+
 // CardType represents different card types
 type CardType string
 
@@ -132,11 +144,29 @@ const (
 
 // Card represents an RFID card
 type Card struct {
-	Type      CardType
-	UID       []byte
-	SectorKey []byte
-	Size      int
-	Blocks    int
+	Type    CardType
+	UID     []byte
+	Keys    *Keyring
+	Version *VersionInfo
+	Size    int
+	Blocks  int
+	ATQA    uint16
+	SAK     byte
+
+	// access caches the decoded access bits of each sector
+	access map[int]AccessBits
+}
+
+// snapshot returns a copy of the card that shares no keyring or access cache with it
+func (c *Card) snapshot() *Card {
+	clone := *c
+	clone.UID = append([]byte(nil), c.UID...)
+	clone.Keys = c.Keys.Clone()
+	clone.access = make(map[int]AccessBits, len(c.access))
+	for sector, bits := range c.access {
+		clone.access[sector] = bits
+	}
+	return &clone
 }
 
 // String returns a string representation of the card
@@ -146,15 +176,62 @@ func (c *Card) String() string {
 
 // Reader represents an RFID reader
 type Reader struct {
-	spiConn  spi.Conn
-	resetPin gpio.PinIO
-	irqPin   gpio.PinIO
-	lastCard *Card
-	spiPort  spi.Port
-	config   config.RFIDConfig
+	transport  Transport
+	resetPin   gpio.PinIO
+	irqPin     IRQLine
+	sched      *scheduler
+	spiPort    spi.PortCloser
+	config     config.RFIDConfig
+	keyring    *Keyring
+	authSector int
+	authKey    KeyType
+
+	// commandTimeout bounds a single MFRC522 command, opTimeout an operation without a deadline
+	commandTimeout time.Duration
+	opTimeout      time.Duration
+
+	retryPolicy RetryPolicy
+	stats       operationStats
+
+	// opCtx is the context of the operation running on the worker, opPriority its priority
+	opCtx      context.Context
+	opPriority Priority
+
+	// lastCard belongs to the worker; published holds a snapshot of it for GetLastCard
+	lastCard  *Card
+	published atomic.Pointer[Card]
+
+	// tuning is applied by init, so it survives a reset of the chip
+	tuning Tuning
+
+	// spiSpeed is the SPI clock in Hz; spiLink is the last link test, nil if none ran
+	spiSpeed int
+	spiLink  *SPILinkTest
+
+	// Power state: antennaOff is set by SetAntenna, idle by the idle policy
+	antennaOff  bool
+	poweredDown bool
+	idle        bool
+	idlePolicy  IdlePolicy
+	idleTimer   *time.Timer
+	lastActive  time.Time
+	lastPoll    time.Time
+
+	// chipVersion is the VersionReg value found by init; health is a Health
+	chipVersion byte
+	health      atomic.Int32
+
+	// watchdogOnce makes StartWatchdog start a single watchdog
+	watchdogOnce sync.Once
+
+	// softwareCRC is set for the rest of an operation once the CRC coprocessor fails
+	softwareCRC bool
+
+	tracer traceRecorder
 }
 
-// NewReader creates a new RFID reader instance
+// NewReader creates a new RFID reader instance. If the MFRC522 does not answer, the
+// reader is returned anyway, absent, along with an error wrapping ErrReaderAbsent.
 func NewReader(cfg config.RFIDConfig) (*Reader, error) {
 	// Initialize periph.io host
 	if _, err := host.Init(); err != nil {
@@ -167,11 +244,15 @@ func NewReader(cfg config.RFIDConfig) (*Reader, error) {
 		return nil, fmt.Errorf("failed to open SPI: %w", err)
 	}
 
-	// Configure SPI
-	spiConn, err := spiPort.Connect(physic.Frequency(cfg.SPISpeed)*physic.Hertz, spi.Mode0, 8)
+	// Configure SPI; the connection allows any clock and LimitSpeed sets the one in use
+	spiConn, err := spiPort.Connect(spiConnectSpeed, spi.Mode0, 8)
 	if err != nil {
 		return nil, fmt.Errorf("failed to configure SPI: %w", err)
 	}
+	transport := &spiTransport{port: spiPort, conn: spiConn}
+	if err := transport.SetSpeed(cfg.SPISpeed); err != nil {
+		return nil, fmt.Errorf("failed to set SPI speed: %w", err)
+	}
 
 	// Configure GPIO pins
 	resetPin := gpioreg.ByName(fmt.Sprintf("GPIO%d", cfg.ResetPin))
@@ -179,32 +260,193 @@ func NewReader(cfg config.RFIDConfig) (*Reader, error) {
 		return nil, fmt.Errorf("failed to get reset pin GPIO%d", cfg.ResetPin)
 	}
 
-	var irqPin gpio.PinIO
+	// Without an IRQ pin command completion is polled over SPI
+	var irqPin IRQLine
 	if cfg.IRQPin > 0 {
-		irqPin = gpioreg.ByName(fmt.Sprintf("GPIO%d", cfg.IRQPin))
-		if irqPin == nil {
+		pin := gpioreg.ByName(fmt.Sprintf("GPIO%d", cfg.IRQPin))
+		if pin == nil {
 			return nil, fmt.Errorf("failed to get IRQ pin GPIO%d", cfg.IRQPin)
 		}
+		irqPin = pin
+	}
+
+	keyring, err := loadKeyring(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tuning := tuningFromConfig(cfg)
+	if err := tuning.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid antenna tuning: %w", err)
 	}
 
 	reader := &Reader{
-		spiPort:  spiPort,
-		spiConn:  spiConn,
-		resetPin: resetPin,
-		irqPin:   irqPin,
-		config:   cfg,
+		spiPort:    spiPort,
+		transport:  transport,
+		resetPin:   resetPin,
+		irqPin:     irqPin,
+		config:     cfg,
+		keyring:    keyring,
+		authSector: -1,
+		tuning:     tuning,
+		spiSpeed:   cfg.SPISpeed,
+
+		retryPolicy:    newRetryPolicy(cfg),
+		commandTimeout: defaultCommandTimeout(),
+		opTimeout:      defaultOperationTimeout(),
 	}
+	reader.tracer.enabled.Store(cfg.Trace)
 
 	// Initialize the reader
-	if err := reader.init(); err != nil {
-		return nil, fmt.Errorf("failed to initialize reader: %w", err)
+	return reader.initialize()
+}
+
+// NewReaderWithTransport creates a reader on top of an arbitrary register transport.
+// No GPIO pins are used, so the hard reset is skipped and only a soft reset is performed.
+// As with NewReader, a chip that does not answer leaves the reader absent.
+func NewReaderWithTransport(transport Transport, cfg config.RFIDConfig) (*Reader, error) {
+	keyring, err := loadKeyring(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tuning := tuningFromConfig(cfg)
+	if err := tuning.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid antenna tuning: %w", err)
+	}
+
+	reader := &Reader{
+		transport:  transport,
+		config:     cfg,
+		keyring:    keyring,
+		authSector: -1,
+		tuning:     tuning,
+		spiSpeed:   cfg.SPISpeed,
+
+		retryPolicy:    newRetryPolicy(cfg),
+		commandTimeout: defaultCommandTimeout(),
+		opTimeout:      defaultOperationTimeout(),
+	}
+	reader.tracer.enabled.Store(cfg.Trace)
+
+	if clocked, ok := transport.(ClockedTransport); ok && cfg.SPISpeed > 0 {
+		if err := clocked.SetSpeed(cfg.SPISpeed); err != nil {
+			return nil, fmt.Errorf("failed to set SPI speed: %w", err)
+		}
 	}
 
-	return reader, nil
+	return reader.initialize()
 }
 
-// Close closes the reader and releases resources
+// initialize configures the chip and starts the worker. A chip that does not answer
+// does not fail the reader: it is returned as absent, with an error wrapping
+// ErrReaderAbsent, and the watchdog configures the chip once it answers.
+func (r *Reader) initialize() (*Reader, error) {
+	if err := r.init(); err != nil {
+		if !errors.Is(err, ErrReaderAbsent) {
+			return nil, fmt.Errorf("failed to initialize reader: %w", err)
+		}
+		r.health.Store(int32(HealthAbsent))
+		r.start()
+		return r, fmt.Errorf("failed to initialize reader: %w", err)
+	}
+
+	r.start()
+	return r, nil
+}
+
+// start launches the worker that performs all further chip access
+func (r *Reader) start() {
+	r.sched = newScheduler()
+	go r.sched.work()
+}
+
+// defaultOperationTimeout returns the operation timeout of the default configuration
+func defaultOperationTimeout() time.Duration {
+	return time.Duration(config.Default().Performance.OperationTimeoutMs) * time.Millisecond
+}
+
+// defaultCommandTimeout returns the command timeout of the default configuration
+func defaultCommandTimeout() time.Duration {
+	return time.Duration(config.Default().Performance.CommandTimeoutMs) * time.Millisecond
+}
+
+// SetOperationTimeout sets the deadline of operations and sessions whose context has none.
+// It covers a whole operation, such as all the commands of a card dump.
+func (r *Reader) SetOperationTimeout(timeout time.Duration) {
+	_ = r.run(func() error {
+		r.opTimeout = timeout
+		return nil
+	})
+}
+
+// SetCommandTimeout sets how long a single MFRC522 command may take before it is abandoned.
+// It only guards against a stuck chip; a missing card is detected by the chip's own timer.
+func (r *Reader) SetCommandTimeout(timeout time.Duration) {
+	_ = r.run(func() error {
+		r.commandTimeout = timeout
+		return nil
+	})
+}
+
+// loadKeyring loads the key file named in the configuration, or returns the default keys
+func loadKeyring(cfg config.RFIDConfig) (*Keyring, error) {
+	if cfg.KeyFile == "" {
+		return NewKeyring(), nil
+	}
+
+	keyring, err := LoadKeyring(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key file %s: %w", cfg.KeyFile, err)
+	}
+
+	return keyring, nil
+}
+
+// Keyring returns a copy of the keys used for cards scanned from now on
+func (r *Reader) Keyring() *Keyring {
+	keyring, _ := call(r, func() (*Keyring, error) {
+		return r.keyring.Clone(), nil
+	})
+	return keyring
+}
+
+// SetKeyring replaces the keys used for cards scanned from now on. The reader keeps a
+// copy, so later changes to keyring have no effect.
+func (r *Reader) SetKeyring(keyring *Keyring) {
+	keyring = keyring.Clone()
+	_ = r.run(func() error {
+		r.keyring = keyring
+		return nil
+	})
+}
+
+// SetCardKey sets a key of the last card, used from its next authentication on.
+// A nil key marks it as unknown.
+func (r *Reader) SetCardKey(sector int, keyType KeyType, key []byte) error {
+	return r.run(func() error {
+		if r.lastCard == nil {
+			return fmt.Errorf("no card selected")
+		}
+		if err := r.lastCard.Keys.SetKey(sector, keyType, key); err != nil {
+			return err
+		}
+		r.publish()
+		return nil
+	})
+}
+
+// Close stops the worker once the running operation has finished. Operations still
+// waiting, and any started later, fail with ErrReaderClosed.
 func (r *Reader) Close() error {
+	_ = r.run(func() error {
+		if r.idleTimer != nil {
+			r.idleTimer.Stop()
+		}
+		return nil
+	})
+	r.sched.close()
+
 	// SPI connections in periph.io are automatically closed when they go out of scope
 	// No explicit close method is needed for spi.Port
 	return nil
@@ -213,22 +455,24 @@ func (r *Reader) Close() error {
 // init initializes the MFRC522 chip
 func (r *Reader) init() error {
 	// Reset the chip
-	if err := r.resetPin.Out(gpio.Low); err != nil {
-		return err
-	}
-	time.Sleep(10 * time.Millisecond)
+	if r.resetPin != nil {
+		if err := r.resetPin.Out(gpio.Low); err != nil {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
 
-	if err := r.resetPin.Out(gpio.High); err != nil {
-		return err
+		if err := r.resetPin.Out(gpio.High); err != nil {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
 	}
-	time.Sleep(50 * time.Millisecond)
 
 	// Soft reset
 	r.writeRegister(CommandReg, PCDResetPhase)
 	time.Sleep(50 * time.Millisecond)
 
 	// Configure timer
-	r.writeRegister(TModeReg, 0x8D)
+	r.writeRegister(TModeReg, initTMode)
 	r.writeRegister(TPrescalerReg, 0x3E)
 	r.writeRegister(TReloadRegL, 30)
 	r.writeRegister(TReloadRegH, 0)
@@ -237,32 +481,42 @@ func (r *Reader) init() error {
 	r.writeRegister(TxAutoReg, 0x40)
 	r.writeRegister(ModeReg, 0x3D)
 
-	// Enable antenna
-	r.setRegisterBitMask(TxControlReg, 0x03)
+	// Configure the receiver and antenna drivers
+	r.applyTuning()
+
+	// Drive the IRQ pin push-pull; ComIEnReg makes it active low for each command
+	r.writeRegister(DivIEnReg, 0x80)
+	if r.irqPin != nil {
+		if err := r.irqPin.In(gpio.PullUp, gpio.FallingEdge); err != nil {
+			return fmt.Errorf("failed to configure IRQ pin: %w", err)
+		}
+	}
+
+	// Enable antenna, unless it was switched off; a reset also ends power-down and idle
+	r.poweredDown = false
+	r.idle = false
+	r.applyAntenna()
 
 	// Check version
 	version := r.readRegister(VersionReg)
-	log.Printf("MFRC522 version: 0x%02x", version)
-
 	if version == 0x00 || version == 0xFF {
-		return fmt.Errorf("MFRC522 not found or not responding")
+		return fmt.Errorf("%w: VersionReg reads 0x%02x", ErrReaderAbsent, version)
 	}
+	log.Printf("MFRC522 version: 0x%02x (%s)", version, chipName(version))
+	r.chipVersion = version
 
 	return nil
 }
 
 // readRegister reads a single register from the MFRC522
 func (r *Reader) readRegister(reg byte) byte {
-	write := []byte{(reg << 1) | 0x80, 0x00}
-	read := make([]byte, 2)
-	_ = r.spiConn.Tx(write, read)
-	return read[1]
+	value, _ := r.transport.ReadRegister(reg)
+	return value
 }
 
 // writeRegister writes a single register to the MFRC522
 func (r *Reader) writeRegister(reg, value byte) {
-	write := []byte{reg << 1, value}
-	_ = r.spiConn.Tx(write, nil)
+	_ = r.transport.WriteRegister(reg, value)
 }
 
 // setRegisterBitMask sets specific bits in a register
@@ -277,69 +531,153 @@ func (r *Reader) clearRegisterBitMask(reg, mask byte) {
 	r.writeRegister(reg, current&(^mask))
 }
 
-// toCard converts raw data to a Card struct
-func (r *Reader) toCard(uid []byte) *Card {
+// toCard converts the identification data of a selected card to a Card struct
+func (r *Reader) toCard(uid []byte, atqa uint16, sak byte) *Card {
 	card := &Card{
-		UID:       uid,
-		SectorKey: []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, // Default key
+		UID:    uid,
+		ATQA:   atqa,
+		SAK:    sak,
+		Keys:   r.keyring.Clone(),
+		access: make(map[int]AccessBits),
 	}
 
-	// Determine card type based on UID length and ATQA/SAK
-	switch len(uid) {
-	case 4:
-		card.Type = CardTypeMifare1K
-		card.Size = 1024
-		card.Blocks = 64
-	case 7:
-		card.Type = CardTypeMifareUL
-		card.Size = 512
-		card.Blocks = 16
-	default:
-		card.Type = CardTypeUnknown
-		card.Size = 0
-		card.Blocks = 0
+	// Determine card type from ATQA and SAK
+	entry := identifyCardType(atqa, sak)
+	card.Type = entry.cardType
+	card.Size = entry.size
+	card.Blocks = entry.blocks
+
+	return card
+}
+
+// selectedCard builds the Card of a selected card. atqa is nil when several cards answered
+// the request with different ATQAs; the type is then identified from the SAK alone.
+func (r *Reader) selectedCard(uid, atqa []byte, sak byte) *Card {
+	if atqa != nil {
+		// ATQA is transmitted least significant byte first
+		return r.toCard(uid, uint16(atqa[1])<<8|uint16(atqa[0]), sak)
 	}
 
+	card := r.toCard(uid, 0, sak)
+	entry := identifyCardTypeBySAK(sak)
+	card.Type = entry.cardType
+	card.Size = entry.size
+	card.Blocks = entry.blocks
 	return card
 }
 
 // ScanForCard scans for a card and returns it if found
 func (r *Reader) ScanForCard() (*Card, error) {
-	// Request card
-	status, _ := r.request(PICCReqIDL)
-	if status != MIOK {
-		return nil, fmt.Errorf("no card detected")
-	}
+	return r.ScanForCardContext(context.Background())
+}
 
-	// Anti-collision
-	status, uid := r.antiCollision()
-	if status != MIOK {
-		return nil, fmt.Errorf("anti-collision failed")
+// ScanForCardContext is ScanForCard bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) ScanForCardContext(ctx context.Context) (*Card, error) {
+	return callContext(ctx, r, r.scanForCard)
+}
+
+// scanForCard implements ScanForCard
+func (r *Reader) scanForCard() (*Card, error) {
+	// A new REQA ends any authenticated session
+	r.authSector = -1
+
+	var atqa, uid []byte
+	var sak byte
+	mode := byte(PICCReqIDL)
+	err := r.retry("scan", func() error {
+		// Request card
+		var err error
+		if atqa, err = r.request(mode); err != nil {
+			return fmt.Errorf("no card detected: %w", err)
+		}
+
+		// Anti-collision and selection through all cascade levels
+		if uid, sak, err = r.selectCard(); err != nil {
+			return fmt.Errorf("anti-collision failed: %w", err)
+		}
+		return nil
+	}, func() error {
+		// A failed attempt may leave the card READY or ACTIVE, where it ignores REQA
+		r.halt()
+		mode = PICCReqAll
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	card := r.toCard(uid)
+	card := r.selectedCard(uid, atqa, sak)
 	r.lastCard = card
 
+	// Ultralight variants and NTAGs share ATQA/SAK and need GET_VERSION to tell them apart
+	if card.Type == CardTypeMifareUL {
+		r.identifyUltralight(card)
+	}
+
 	log.Printf("Card detected: %s", card.String())
-	return card, nil
+	return r.publish(), nil
+}
+
+// reactivate wakes the last card with WUPA and selects it again.
+// Cards drop back to IDLE after a NAK, a failed authentication or an unsupported command.
+func (r *Reader) reactivate() error {
+	// HALT first so that a card still in ACTIVE state answers the WUPA
+	r.halt()
+	r.stopCrypto()
+
+	if _, err := r.request(PICCReqAll); err != nil {
+		return err
+	}
+
+	// Select by UID, so that no other card in the field can answer
+	_, err := r.selectUID(r.lastCard.UID)
+	return err
 }
 
 // ReadBlock reads a specific block from the card
 func (r *Reader) ReadBlock(block int) ([]byte, error) {
+	return r.ReadBlockContext(context.Background(), block)
+}
+
+// ReadBlockContext is ReadBlock bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) ReadBlockContext(ctx context.Context, block int) ([]byte, error) {
+	return callContext(ctx, r, func() ([]byte, error) {
+		return r.readBlock(block)
+	})
+}
+
+// readBlock implements ReadBlock
+func (r *Reader) readBlock(block int) ([]byte, error) {
 	if r.lastCard == nil {
 		return nil, fmt.Errorf("no card selected")
 	}
 
-	// Authenticate
-	status := r.authenticate(PICCAuthent1A, block, r.lastCard.SectorKey, r.lastCard.UID)
-	if status != MIOK {
-		return nil, fmt.Errorf("authentication failed")
+	if !r.lastCard.IsClassic() {
+		return nil, fmt.Errorf("%s cards do not use MIFARE Classic blocks", r.lastCard.Type)
+	}
+
+	sector := r.lastCard.SectorOfBlock(block)
+	if sector < 0 {
+		return nil, fmt.Errorf("block %d is out of range for %s", block, r.lastCard.Type)
+	}
+
+	// Authenticate with a key that may read the block
+	keyType, err := r.chooseKey(block, accessRead)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authenticateSector(sector, keyType); err != nil {
+		return nil, err
 	}
 
 	// Read block
-	status, data := r.read(block)
-	if status != MIOK {
-		return nil, fmt.Errorf("read failed")
+	data, err := r.readRetry(block, sector, keyType)
+	if err != nil {
+		// The card drops to IDLE after a NAK
+		_ = r.reactivate()
+		return nil, fmt.Errorf("read failed: %w", err)
 	}
 
 	return data, nil
@@ -347,6 +685,20 @@ func (r *Reader) ReadBlock(block int) ([]byte, error) {
 
 // WriteBlock writes data to a specific block on the card
 func (r *Reader) WriteBlock(block int, data []byte) error {
+	return r.WriteBlockContext(context.Background(), block, data)
+}
+
+// WriteBlockContext is WriteBlock bounded by ctx. Without a deadline on ctx the
+// operation timeout applies. A write already sent to the card is not interrupted.
+func (r *Reader) WriteBlockContext(ctx context.Context, block int, data []byte) error {
+	_, err := callContext(ctx, r, func() (struct{}, error) {
+		return struct{}{}, r.writeBlock(block, data)
+	})
+	return err
+}
+
+// writeBlock implements WriteBlock
+func (r *Reader) writeBlock(block int, data []byte) error {
 	if r.lastCard == nil {
 		return fmt.Errorf("no card selected")
 	}
@@ -355,16 +707,41 @@ func (r *Reader) WriteBlock(block int, data []byte) error {
 		return fmt.Errorf("data must be exactly 16 bytes")
 	}
 
-	// Authenticate
-	status := r.authenticate(PICCAuthent1A, block, r.lastCard.SectorKey, r.lastCard.UID)
-	if status != MIOK {
-		return fmt.Errorf("authentication failed")
+	if !r.lastCard.IsClassic() {
+		return fmt.Errorf("%s cards do not use MIFARE Classic blocks", r.lastCard.Type)
+	}
+
+	sector := r.lastCard.SectorOfBlock(block)
+	if sector < 0 {
+		return fmt.Errorf("block %d is out of range for %s", block, r.lastCard.Type)
+	}
+
+	// Access bits that fail the integrity check would permanently block the sector
+	if r.lastCard.IsTrailer(block) {
+		if _, err := DecodeAccessBits(data); err != nil {
+			return fmt.Errorf("refusing to write sector trailer %d: %w", block, err)
+		}
+	}
+
+	// Authenticate with a key that may write the block, usually Key B
+	keyType, err := r.chooseKey(block, accessWrite)
+	if err != nil {
+		return err
+	}
+	if err := r.authenticateSector(sector, keyType); err != nil {
+		return err
 	}
 
 	// Write block
-	status = r.write(block, data)
-	if status != MIOK {
-		return fmt.Errorf("write failed")
+	if err := r.writeRetry(block, sector, keyType, data); err != nil {
+		_ = r.reactivate()
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	// A new trailer may change the access bits
+	if r.lastCard.IsTrailer(block) {
+		delete(r.lastCard.access, sector)
+		r.publish()
 	}
 
 	return nil
@@ -372,122 +749,473 @@ func (r *Reader) WriteBlock(block int, data []byte) error {
 
 // ReadCard reads all accessible blocks from the card
 func (r *Reader) ReadCard() (map[int][]byte, error) {
+	return r.ReadCardContext(context.Background())
+}
+
+// ReadCardContext is ReadCard bounded by ctx. Without a deadline on ctx the operation
+// timeout applies; once ctx is done the dump stops before the next card command.
+func (r *Reader) ReadCardContext(ctx context.Context) (map[int][]byte, error) {
+	return callContext(ctx, r, r.readCard)
+}
+
+// readCard implements ReadCard
+func (r *Reader) readCard() (map[int][]byte, error) {
 	if r.lastCard == nil {
 		return nil, fmt.Errorf("no card selected")
 	}
 
+	card := r.lastCard
+	if card.IsUltralight() {
+		return r.readUltralight()
+	}
+	if !card.IsClassic() {
+		return nil, fmt.Errorf("reading %s cards is not supported", card.Type)
+	}
+
 	data := make(map[int][]byte)
 
-	// Read all blocks except sector trailers
-	for block := 0; block < r.lastCard.Blocks; block++ {
-		// Skip sector trailer blocks (every 4th block starting from 3)
-		if (block+1)%4 == 0 {
+	// Read all blocks including the sector trailer, authenticating only when the
+	// current key may not read the next block. Key A always reads as zeros.
+	for sector := 0; sector < card.Sectors(); sector++ {
+		for block := card.FirstBlockOfSector(sector); block <= card.TrailerOfSector(sector); block++ {
+			if err := r.canceled(); err != nil {
+				return nil, err
+			}
+
+			keyType, err := r.chooseKey(block, accessRead)
+			if err == nil {
+				err = r.authenticateSector(sector, keyType)
+			}
+			if err != nil {
+				log.Printf("Warning: Failed to read block %d: %v", block, err)
+				continue
+			}
+
+			blockData, err := r.readRetry(block, sector, keyType)
+			if err != nil {
+				log.Printf("Warning: Failed to read block %d: %v", block, err)
+				_ = r.reactivate()
+				continue
+			}
+
+			data[block] = blockData
+		}
+	}
+
+	return data, nil
+}
+
+// authenticateSector authenticates a sector of the last card with one of its keys,
+// unless the sector is already open with that key
+func (r *Reader) authenticateSector(sector int, keyType KeyType) error {
+	if r.authSector == sector && r.authKey == keyType {
+		return nil
+	}
+
+	key := r.lastCard.Keys.Key(sector, keyType)
+	if key == nil {
+		return fmt.Errorf("%s of sector %d is unknown", keyType, sector)
+	}
+
+	// A failed authentication sends the card back to IDLE
+	trailer := r.lastCard.TrailerOfSector(sector)
+	err := r.retry("authenticate", func() error {
+		return r.authenticate(byte(keyType), trailer, key, r.lastCard.UID)
+	}, r.reactivate)
+	if err != nil {
+		_ = r.reactivate()
+		return fmt.Errorf("authentication with %s failed: %w", keyType, err)
+	}
+
+	r.authSector = sector
+	r.authKey = keyType
+	return nil
+}
+
+// sectorAccess returns the access bits of a sector, reading its trailer on first use.
+// It reports false when no known key can read them.
+func (r *Reader) sectorAccess(sector int) (AccessBits, bool) {
+	card := r.lastCard
+	if bits, ok := card.access[sector]; ok {
+		return bits, true
+	}
+
+	for _, keyType := range []KeyType{KeyA, KeyB} {
+		if card.Keys.Key(sector, keyType) == nil || r.authenticateSector(sector, keyType) != nil {
 			continue
 		}
 
-		blockData, err := r.ReadBlock(block)
+		trailer, err := r.read(card.TrailerOfSector(sector))
 		if err != nil {
-			log.Printf("Warning: Failed to read block %d: %v", block, err)
+			_ = r.reactivate()
 			continue
 		}
 
-		data[block] = blockData
+		bits, err := DecodeAccessBits(trailer)
+		if err != nil {
+			log.Printf("Warning: Sector %d: %v", sector, err)
+			return bits, false
+		}
+		card.access[sector] = bits
+		r.publish()
+		return bits, true
 	}
 
-	return data, nil
+	return AccessBits{}, false
+}
+
+// chooseKey picks the key type to authenticate with for an operation on block.
+// The sector's access bits decide which keys are allowed; the open key is kept when
+// possible, otherwise Key A is preferred for reads and Key B for everything else.
+func (r *Reader) chooseKey(block int, op accessOp) (KeyType, error) {
+	card := r.lastCard
+	sector := card.SectorOfBlock(block)
+
+	allowed := PermissionKeyAB
+	if bits, ok := r.sectorAccess(sector); ok {
+		allowed = bits.permittedKeys(block, op)
+	}
+	if allowed == PermissionNever {
+		return 0, fmt.Errorf("access conditions do not allow %s of block %d", op, block)
+	}
+
+	if r.authSector == sector && allowed.Allows(r.authKey) {
+		return r.authKey, nil
+	}
+
+	order := []KeyType{KeyB, KeyA}
+	if op == accessRead {
+		order = []KeyType{KeyA, KeyB}
+	}
+	for _, keyType := range order {
+		if allowed.Allows(keyType) && card.Keys.Key(sector, keyType) != nil {
+			return keyType, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no known key allows %s of block %d", op, block)
 }
 
 // Low-level MFRC522 operations
 
-func (r *Reader) request(mode byte) (int, []byte) {
+func (r *Reader) request(mode byte) ([]byte, error) {
 	r.writeRegister(BitFramingReg, 0x07)
 
 	tagType := []byte{mode}
-	status, backData := r.toCard2(PCDTransceive, tagType)
+	backData, err := r.toCard2(PCDTransceive, tagType)
+	if errors.Is(err, ErrCollision) {
+		// Cards with different ATQAs answered; anticollision tells them apart
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(backData) != 2 {
+		return nil, fmt.Errorf("%w: ATQA of %d bytes", ErrProtocol, len(backData))
+	}
+
+	return backData, nil
+}
+
+// selectCard runs anticollision and SELECT through every cascade level.
+// It returns the complete 4, 7 or 10 byte UID and the final SAK.
+func (r *Reader) selectCard() ([]byte, byte, error) {
+	var uid []byte
+
+	for _, sel := range cascadeLevels {
+		uidPart, err := r.antiCollision(sel)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		sak, err := r.selectTag(sel, uidPart)
+		if err != nil {
+			return nil, 0, err
+		}
 
-	if status != MIOK || len(backData) != 2 {
-		return MIErr, nil
+		// Bit 3 of the SAK signals that the UID continues at the next cascade level
+		if sak&0x04 == 0 {
+			return append(uid, uidPart...), sak, nil
+		}
+
+		if uidPart[0] != PICCCascadeTag {
+			return nil, 0, fmt.Errorf("%w: cascade tag missing", ErrProtocol)
+		}
+		uid = append(uid, uidPart[1:]...)
 	}
 
-	return MIOK, backData
+	return nil, 0, fmt.Errorf("%w: UID longer than three cascade levels", ErrProtocol)
 }
 
-func (r *Reader) antiCollision() (int, []byte) {
+// antiCollision returns the four UID bytes (or cascade tag and three UID bytes) of one cascade level.
+// When several cards answer, it walks the collision tree bit by bit, always following the
+// cards that sent a 1, until a single card is left.
+func (r *Reader) antiCollision(sel byte) ([]byte, error) {
+	// Bits received after a collision are cleared
+	r.clearRegisterBitMask(CollReg, 0x80)
+
+	// Four UID bytes and the BCC
+	uid := make([]byte, 5)
+	known := 0
+	for {
+		whole, bits := known/8, known%8
+
+		// NVB counts the bytes (SEL and NVB included) and bits sent; TxLastBits sends only
+		// the known bits of a partial byte and RxAlign stores the answer right after them
+		serNum := []byte{sel, byte((2+whole)<<4 | bits)}
+		serNum = append(serNum, uid[:(known+7)/8]...)
+		r.writeRegister(BitFramingReg, byte(bits<<4|bits))
+
+		backData, err := r.toCard2(PCDTransceive, serNum)
+		var collision *CollisionError
+		if err != nil && !errors.As(err, &collision) {
+			return nil, err
+		}
+		if collision == nil && len(backData) != len(uid)-whole {
+			return nil, fmt.Errorf("%w: anticollision answer of %d bytes", ErrProtocol, len(backData))
+		}
+
+		for i, b := range backData {
+			if whole+i >= len(uid) {
+				break
+			}
+			if i == 0 {
+				// The first byte only holds the bits after the known ones
+				b = uid[whole]&(1<<bits-1) | b&^(1<<bits-1)
+			}
+			uid[whole+i] = b
+		}
+
+		if collision == nil {
+			break
+		}
+		if collision.Position <= known || collision.Position > 32 {
+			return nil, err
+		}
+
+		// Follow the cards that sent a 1 at the collided bit
+		known = collision.Position
+		uid[(known-1)/8] |= 1 << ((known - 1) % 8)
+		if known == 32 {
+			uid[4] = uid[0] ^ uid[1] ^ uid[2] ^ uid[3]
+			break
+		}
+	}
 	r.writeRegister(BitFramingReg, 0x00)
 
-	serNum := []byte{PICCAntiColl, 0x20}
-	status, backData := r.toCard2(PCDTransceive, serNum)
+	serNumCheck := byte(0)
+	for i := 0; i < 4; i++ {
+		serNumCheck ^= uid[i]
+	}
+	if serNumCheck != uid[4] {
+		return nil, fmt.Errorf("%w: UID BCC mismatch", ErrProtocol)
+	}
+
+	// Strip the BCC byte, it is not part of the UID
+	return uid[:4], nil
+}
+
+// selectUID selects a card whose UID is already known, without anticollision. Other cards
+// in the field stay silent. It returns the final SAK.
+func (r *Reader) selectUID(uid []byte) (byte, error) {
+	var parts [][]byte
+	switch len(uid) {
+	case 4:
+		parts = [][]byte{uid}
+	case 7:
+		parts = [][]byte{{PICCCascadeTag, uid[0], uid[1], uid[2]}, uid[3:7]}
+	case 10:
+		parts = [][]byte{{PICCCascadeTag, uid[0], uid[1], uid[2]}, {PICCCascadeTag, uid[3], uid[4], uid[5]}, uid[6:10]}
+	default:
+		return 0, fmt.Errorf("UID must be 4, 7 or 10 bytes")
+	}
+
+	for i, part := range parts {
+		sak, err := r.selectTag(cascadeLevels[i], part)
+		if err != nil {
+			return 0, err
+		}
 
-	if status == MIOK && len(backData) == 5 {
-		serNumCheck := byte(0)
-		for i := 0; i < 4; i++ {
-			serNumCheck ^= backData[i]
+		// Bit 3 of the SAK must signal a continuation exactly where the UID continues
+		if (sak&0x04 != 0) != (i < len(parts)-1) {
+			return 0, fmt.Errorf("%w: UID length does not match the card", ErrProtocol)
 		}
-		if serNumCheck != backData[4] {
-			return MIErr, nil
+		if i == len(parts)-1 {
+			return sak, nil
 		}
 	}
+	return 0, nil
+}
+
+// selectTag selects the card answering with uidPart at one cascade level and returns its SAK
+func (r *Reader) selectTag(sel byte, uidPart []byte) (byte, error) {
+	r.writeRegister(BitFramingReg, 0x00)
 
-	return status, backData
+	buff := []byte{sel, 0x70}
+	buff = append(buff, uidPart...)
+	buff = append(buff, uidPart[0]^uidPart[1]^uidPart[2]^uidPart[3])
+	buff = r.appendCRC(buff)
+
+	backData, err := r.toCard2(PCDTransceive, buff)
+	if err != nil {
+		return 0, err
+	}
+
+	sak, err := r.stripCRC(backData, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	return sak[0], nil
+}
+
+// halt sends HLTA; the card does not answer it
+func (r *Reader) halt() {
+	r.writeRegister(BitFramingReg, 0x00)
+	_, _ = r.toCard2(PCDTransceive, r.appendCRC([]byte{PICCHalt, 0x00}))
 }
 
-func (r *Reader) authenticate(authMode byte, blockAddr int, sectorKey, serNum []byte) int {
+// authenticate runs the MIFARE Classic three-pass authentication.
+// A card holding a different key stays silent, which is reported as ErrAuthDenied.
+func (r *Reader) authenticate(authMode byte, blockAddr int, sectorKey, serNum []byte) error {
 	buff := []byte{authMode, byte(blockAddr)}
 	buff = append(buff, sectorKey...)
-	buff = append(buff, serNum...)
+	// Crypto1 is keyed with the last four bytes of double and triple size UIDs
+	buff = append(buff, serNum[len(serNum)-4:]...)
 
-	status, _ := r.toCard2(PCDAuthent, buff)
+	_, err := r.toCard2(PCDAuthent, buff)
+	if errors.Is(err, ErrNoTag) {
+		return ErrAuthDenied
+	}
+	if err != nil {
+		return err
+	}
 
-	if status != MIOK || (r.readRegister(Status2Reg)&0x08) == 0 {
-		return MIErr
+	if (r.readRegister(Status2Reg) & 0x08) == 0 {
+		return ErrAuthDenied
 	}
 
-	return MIOK
+	return nil
 }
 
-func (r *Reader) read(blockAddr int) (int, []byte) {
-	recvData := []byte{PICCRead, byte(blockAddr)}
-	status, backData := r.toCard2(PCDTransceive, recvData)
+func (r *Reader) read(blockAddr int) ([]byte, error) {
+	recvData := r.appendCRC([]byte{PICCRead, byte(blockAddr)})
+	backData, err := r.toCard2(PCDTransceive, recvData)
+	if err != nil {
+		return nil, err
+	}
 
-	if status != MIOK || len(backData) != 16 {
-		return MIErr, nil
+	// A refused READ is answered with a 4-bit NAK instead of data
+	if len(backData) == 1 {
+		return nil, r.nakError(backData[0] & 0x0F)
 	}
 
-	return MIOK, backData
+	// The card appends CRC_A to the 16 data bytes
+	return r.stripCRC(backData, 16)
 }
 
-func (r *Reader) write(blockAddr int, writeData []byte) int {
+// write sends WRITE and the block data. It reports whether the data frame was sent:
+// once it has, a failure does not prove that the card left the block unchanged.
+func (r *Reader) write(blockAddr int, writeData []byte) (bool, error) {
 	buff := []byte{PICCWrite, byte(blockAddr)}
-	status, _ := r.toCard2(PCDTransceive, buff)
 
-	if status != MIOK || (r.readRegister(Status2Reg)&0x08) == 0 {
-		return MIErr
+	// Both steps are answered with a 4-bit ACK; access conditions are enforced with a NAK
+	for i, frame := range [][]byte{buff, writeData} {
+		if err := r.ackCommand(frame); err != nil {
+			return i > 0, err
+		}
+		if (r.readRegister(Status2Reg) & 0x08) == 0 {
+			return i > 0, ErrAuthDenied
+		}
 	}
 
-	status, _ = r.toCard2(PCDTransceive, writeData)
+	return true, nil
+}
 
-	if status != MIOK || (r.readRegister(Status2Reg)&0x08) == 0 {
-		return MIErr
-	}
+// readRetry reads a block of the authenticated sector, opening it again after transient errors
+func (r *Reader) readRetry(block, sector int, keyType KeyType) ([]byte, error) {
+	var data []byte
+	err := r.retry("read", func() error {
+		var err error
+		data, err = r.read(block)
+		return err
+	}, r.reauthenticate(sector, keyType))
+	return data, err
+}
+
+// writeRetry writes a block of the authenticated sector. A write whose data frame may have
+// reached the card is never repeated blindly: the block is read back first and only written
+// again if it still differs. Trailers cannot be read back, so their writes are not retried.
+func (r *Reader) writeRetry(block, sector int, keyType KeyType, data []byte) error {
+	dataSent := false
+	return r.retry("write", func() error {
+		if dataSent {
+			if r.lastCard.IsTrailer(block) {
+				return fmt.Errorf("sector trailer %d may have been written, not retrying", block)
+			}
+			current, err := r.read(block)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(current, data) {
+				return nil
+			}
+		}
+
+		sent, err := r.write(block, data)
+		dataSent = dataSent || sent
+		return err
+	}, r.reauthenticate(sector, keyType))
+}
 
-	return MIOK
+// toCard2 runs a command on the MFRC522 and returns the card's answer. An answer that
+// leaves the FIFO empty is read as a single byte, which the card commands take as a NAK.
+func (r *Reader) toCard2(command byte, sendData []byte) ([]byte, error) {
+	return r.exchange(command, sendData, true)
 }
 
-func (r *Reader) toCard2(command byte, sendData []byte) (int, []byte) {
-	var backData []byte
-	irqEn := byte(0x00)
+// exchange implements toCard2. Without padEmpty an empty FIFO gives an empty answer, so
+// that Transceive reports exactly what came back.
+func (r *Reader) exchange(command byte, sendData []byte, padEmpty bool) (backData []byte, err error) {
 	waitIRq := byte(0x00)
 
 	switch command {
 	case PCDAuthent:
-		irqEn = 0x12
-		waitIRq = 0x10
+		waitIRq = 0x10 // IdleIRq
 	case PCDTransceive:
-		irqEn = 0x77
-		waitIRq = 0x30
+		waitIRq = 0x30 // RxIRq, IdleIRq
+	}
+
+	if len(sendData) > MaxLen {
+		return nil, fmt.Errorf("%w: %d byte frame", ErrBufferOverflow, len(sendData))
 	}
 
-	r.writeRegister(ComIEnReg, irqEn|0x80)
+	// Without a chip every command would run into the timeout
+	if r.Health() == HealthAbsent {
+		return nil, ErrReaderAbsent
+	}
+
+	// A cancelled operation stops between card commands
+	if err := r.canceled(); err != nil {
+		return nil, err
+	}
+
+	// Card commands of user operations wake an idle or powered-down reader
+	if r.opPriority == PriorityUser {
+		r.activate()
+	}
+
+	if r.tracer.enabled.Load() {
+		txBits := int(r.readRegister(BitFramingReg) & 0x07)
+		start := time.Now()
+		defer func() {
+			r.traceExchange(command, sendData, txBits, start, backData, err)
+		}()
+	}
+
+	// Only the completion and timer interrupts reach the IRQ pin, inverted to active low
+	r.writeRegister(ComIEnReg, 0x80|waitIRq|0x01)
 	r.clearRegisterBitMask(ComIrqReg, 0x80)
 	r.setRegisterBitMask(FIFOLevelReg, 0x80)
 
@@ -504,35 +1232,29 @@ func (r *Reader) toCard2(command byte, sendData []byte) (int, []byte) {
 	}
 
 	// Wait for completion
-	i := 2000
-	for i > 0 {
-		n := r.readRegister(ComIrqReg)
-		i--
-		if n&0x01 != 0 {
-			return MIErr, nil
-		}
-		if n&waitIRq != 0 {
-			break
-		}
+	n, ok := r.waitForIRQ(waitIRq | 0x01)
+	if n&0x01 != 0 {
+		// The timer expired without an answer from the card
+		return nil, ErrNoTag
 	}
 
 	r.clearRegisterBitMask(BitFramingReg, 0x80)
 
-	if i == 0 {
-		return MIErr, nil
+	if !ok {
+		return nil, ErrTimeout
 	}
 
-	errorReg := r.readRegister(ErrorReg)
-	if errorReg&0x1B != 0 {
-		return MIErr, nil
+	// After a collision the bits received before it are returned along with the error
+	err = errorFromRegisters(r.readRegister(ErrorReg), r.readRegister(CollReg))
+	var collision *CollisionError
+	if err != nil && !errors.As(err, &collision) {
+		return nil, err
 	}
 
-	status := MIOK
-
 	if command == PCDTransceive {
 		n := r.readRegister(FIFOLevelReg)
 
-		if n == 0 {
+		if n == 0 && padEmpty {
 			n = 1
 		}
 
@@ -545,21 +1267,85 @@ func (r *Reader) toCard2(command byte, sendData []byte) (int, []byte) {
 		}
 	}
 
-	return status, backData
+	return backData, err
 }
 
-// GetLastCard returns the last scanned card
+// irqWaitSlice bounds each wait on the IRQ pin. An edge raised between the register check
+// and the wait is missed, so ComIrqReg is checked again at least this often.
+const irqWaitSlice = 5 * time.Millisecond
+
+// waitForIRQ waits until one of the interrupts in mask is raised or the command timeout
+// expires, and returns the last ComIrqReg value. With an IRQ pin the reader sleeps until
+// the MFRC522 pulls it low; otherwise ComIrqReg is polled over SPI.
+func (r *Reader) waitForIRQ(mask byte) (byte, bool) {
+	deadline := time.Now().Add(r.commandTimeout)
+	for {
+		n := r.readRegister(ComIrqReg)
+		if n&mask != 0 {
+			return n, true
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return n, false
+		}
+
+		// An edge may belong to an earlier command, so the register is checked again
+		if r.irqPin != nil {
+			r.irqPin.WaitForEdge(min(remaining, irqWaitSlice))
+		}
+	}
+}
+
+// GetLastCard returns a snapshot of the last scanned card, taken whenever the reader
+// changes it. Changes made to the snapshot do not reach the reader; use SetCardKey to
+// change the keys of the card.
 func (r *Reader) GetLastCard() *Card {
-	return r.lastCard
+	return r.published.Load()
+}
+
+// publish hands a snapshot of the last card to GetLastCard and returns it
+func (r *Reader) publish() *Card {
+	if r.lastCard == nil {
+		r.published.Store(nil)
+		return nil
+	}
+	card := r.lastCard.snapshot()
+	r.published.Store(card)
+	return card
 }
 
-// IsCardPresent checks if a card is currently present
+// IsCardPresent checks if a card is currently present. It is meant for presence polling
+// and runs at background priority, after any user operation that is waiting.
 func (r *Reader) IsCardPresent() bool {
-	_, err := r.ScanForCard()
+	present := false
+	_ = r.exec(context.Background(), PriorityBackground, func() error {
+		present = r.pollCard()
+		return nil
+	})
+	return present
+}
+
+// isCardPresent implements IsCardPresent. Waking the last card keeps it selected and
+// keeps its keys; only when it is gone is the field scanned for another one.
+func (r *Reader) isCardPresent() bool {
+	if r.lastCard != nil && r.reactivate() == nil {
+		return true
+	}
+	_, err := r.scanForCard()
 	return err == nil
 }
 
 // StopCrypto stops the crypto operations
 func (r *Reader) StopCrypto() {
+	_ = r.run(func() error {
+		r.stopCrypto()
+		return nil
+	})
+}
+
+// stopCrypto implements StopCrypto
+func (r *Reader) stopCrypto() {
 	r.clearRegisterBitMask(Status2Reg, 0x08)
+	r.authSector = -1
 }
@@ -23,34 +23,38 @@ func TestCardString(t *testing.T) {
 func TestCardTypeDetection(t *testing.T) {
 	reader := &Reader{}
 
-	// Test MIFARE 1K (4 byte UID)
-	card1K := reader.toCard([]byte{0x12, 0x34, 0x56, 0x78})
-	if card1K.Type != CardTypeMifare1K {
-		t.Errorf("Expected MIFARE 1K, got %v", card1K.Type)
-	}
-	if card1K.Size != 1024 {
-		t.Errorf("Expected size 1024, got %d", card1K.Size)
-	}
-	if card1K.Blocks != 64 {
-		t.Errorf("Expected 64 blocks, got %d", card1K.Blocks)
-	}
-
-	// Test MIFARE Ultralight (7 byte UID)
-	cardUL := reader.toCard([]byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE})
-	if cardUL.Type != CardTypeMifareUL {
-		t.Errorf("Expected MIFARE Ultralight, got %v", cardUL.Type)
-	}
-	if cardUL.Size != 512 {
-		t.Errorf("Expected size 512, got %d", cardUL.Size)
-	}
-	if cardUL.Blocks != 16 {
-		t.Errorf("Expected 16 blocks, got %d", cardUL.Blocks)
+	tests := []struct {
+		name     string
+		uid      []byte
+		atqa     uint16
+		sak      byte
+		wantType CardType
+		wantSize int
+		blocks   int
+	}{
+		{"Classic 1K", []byte{0x12, 0x34, 0x56, 0x78}, 0x0004, 0x08, CardTypeMifare1K, 1024, 64},
+		{"Classic 1K 7-byte UID", []byte{0x04, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE}, 0x0044, 0x08, CardTypeMifare1K, 1024, 64},
+		{"Classic 4K", []byte{0x12, 0x34, 0x56, 0x78}, 0x0002, 0x18, CardTypeMifare4K, 4096, 256},
+		{"Classic Mini", []byte{0x12, 0x34, 0x56, 0x78}, 0x0004, 0x09, CardTypeMifareMini, 320, 20},
+		{"Ultralight", []byte{0x04, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE}, 0x0044, 0x00, CardTypeMifareUL, 64, 16},
+		{"Plus 2K SL2", []byte{0x12, 0x34, 0x56, 0x78}, 0x0004, 0x10, CardTypeMifarePlus, 2048, 128},
+		{"Plus 4K SL3", []byte{0x04, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE}, 0x0042, 0x20, CardTypeMifarePlus, 4096, 256},
+		{"DESFire", []byte{0x04, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE}, 0x0344, 0x20, CardTypeDESFire, 0, 0},
+		{"SmartMX", []byte{0x12, 0x34, 0x56, 0x78}, 0x0004, 0x28, CardTypeSmartMX, 1024, 64},
+		{"Unknown", []byte{0x12, 0x34, 0x56, 0x78}, 0x0004, 0x7F, CardTypeUnknown, 0, 0},
 	}
 
-	// Test unknown card type
-	cardUnknown := reader.toCard([]byte{0x12, 0x34})
-	if cardUnknown.Type != CardTypeUnknown {
-		t.Errorf("Expected Unknown, got %v", cardUnknown.Type)
+	for _, tt := range tests {
+		card := reader.toCard(tt.uid, tt.atqa, tt.sak)
+		if card.Type != tt.wantType {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.wantType, card.Type)
+		}
+		if card.Size != tt.wantSize {
+			t.Errorf("%s: expected size %d, got %d", tt.name, tt.wantSize, card.Size)
+		}
+		if card.Blocks != tt.blocks {
+			t.Errorf("%s: expected %d blocks, got %d", tt.name, tt.blocks, card.Blocks)
+		}
 	}
 }
 
@@ -97,15 +101,3 @@ func TestRegisterOperations(t *testing.T) {
 		t.Errorf("Expected PICCAuthent1A 0x60, got 0x%02x", PICCAuthent1A)
 	}
 }
-
-func TestStatusCodes(t *testing.T) {
-	if MIOK != 0 {
-		t.Errorf("Expected MIOK 0, got %d", MIOK)
-	}
-	if MINoTagErr != 1 {
-		t.Errorf("Expected MINoTagErr 1, got %d", MINoTagErr)
-	}
-	if MIErr != 2 {
-		t.Errorf("Expected MIErr 2, got %d", MIErr)
-	}
-}
@@ -0,0 +1,123 @@
+package rfid
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+// RetryPolicy decides how card operations are repeated after transient errors
+type RetryPolicy struct {
+	Retries int           // Attempts after the first one
+	Backoff time.Duration // Delay before the first retry, doubled for each further retry
+}
+
+// newRetryPolicy builds the retry policy of a reader configuration
+func newRetryPolicy(cfg config.RFIDConfig) RetryPolicy {
+	return RetryPolicy{
+		Retries: cfg.RetryCount,
+		Backoff: time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
+	}
+}
+
+// delay returns the pause before retry number n, counting from 1
+func (p RetryPolicy) delay(n int) time.Duration {
+	return p.Backoff << (n - 1)
+}
+
+// isTransient reports whether an error is worth retrying. Timeouts, CRC and parity errors
+// come from marginal coupling; a missing card, a refused key or a NAK will not go away.
+func isTransient(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrCRC) || errors.Is(err, ErrParity)
+}
+
+// OperationStats counts the outcomes of one kind of card operation
+type OperationStats struct {
+	Operations int `json:"operations"` // Operations started
+	Failures   int `json:"failures"`   // Operations that failed after all retries
+	Retries    int `json:"retries"`    // Attempts repeated after a transient error
+}
+
+// operationStats collects OperationStats by operation name
+type operationStats struct {
+	mu  sync.Mutex
+	ops map[string]OperationStats
+}
+
+// record adds the outcome of one operation
+func (s *operationStats) record(name string, retries int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ops == nil {
+		s.ops = make(map[string]OperationStats)
+	}
+	stats := s.ops[name]
+	stats.Operations++
+	stats.Retries += retries
+	if err != nil {
+		stats.Failures++
+	}
+	s.ops[name] = stats
+}
+
+// snapshot returns a copy of the statistics
+func (s *operationStats) snapshot() map[string]OperationStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ops := make(map[string]OperationStats, len(s.ops))
+	for name, stats := range s.ops {
+		ops[name] = stats
+	}
+	return ops
+}
+
+// SetRetryPolicy replaces the retry policy taken from the configuration
+func (r *Reader) SetRetryPolicy(policy RetryPolicy) {
+	_ = r.run(func() error {
+		r.retryPolicy = policy
+		return nil
+	})
+}
+
+// Stats returns the operation statistics by operation name: scan, authenticate, read,
+// write and key check
+func (r *Reader) Stats() map[string]OperationStats {
+	return r.stats.snapshot()
+}
+
+// retry runs op until it succeeds, fails with an error that is not transient, the retry
+// policy is exhausted, or the operation is cancelled. recoverCard, if not nil, runs before
+// each retry to bring the card back into a state where op can be repeated; if it fails,
+// its error counts as the attempt's.
+func (r *Reader) retry(name string, op func() error, recoverCard func() error) error {
+	err := op()
+	retries := 0
+	for retries < r.retryPolicy.Retries && isTransient(err) && r.canceled() == nil {
+		retries++
+		time.Sleep(r.retryPolicy.delay(retries))
+
+		if recoverCard != nil {
+			if err = recoverCard(); err != nil {
+				continue
+			}
+		}
+		err = op()
+	}
+
+	r.stats.record(name, retries, err)
+	return err
+}
+
+// reauthenticate wakes the last card after a failed command and opens the sector again
+func (r *Reader) reauthenticate(sector int, keyType KeyType) func() error {
+	return func() error {
+		if err := r.reactivate(); err != nil {
+			return err
+		}
+		return r.authenticateSector(sector, keyType)
+	}
+}
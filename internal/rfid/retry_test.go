@@ -0,0 +1,116 @@
+package rfid
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Retries: 3, Backoff: 10 * time.Millisecond}
+	for n, want := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond} {
+		if got := policy.delay(n + 1); got != want {
+			t.Errorf("delay(%d) = %v, want %v", n+1, got, want)
+		}
+	}
+}
+
+func TestRetryTransientRead(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	card.SetBlock(4, []byte("retried read ok!"))
+	reader, sim := newSimulatedReader(t, card)
+	reader.SetRetryPolicy(RetryPolicy{Retries: 2})
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// The first answer is the sector trailer read for the access bits
+	sim.InjectError(1, errCRC)
+	data, err := reader.ReadBlock(4)
+	if err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if !bytes.Equal(data, []byte("retried read ok!")) {
+		t.Errorf("ReadBlock() = %q, want %q", data, "retried read ok!")
+	}
+
+	stats := reader.Stats()["read"]
+	if stats.Operations != 1 || stats.Retries != 1 || stats.Failures != 0 {
+		t.Errorf("read stats = %+v, want 1 operation and 1 retry", stats)
+	}
+}
+
+func TestRetryDisabled(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	reader, sim := newSimulatedReader(t, card)
+	reader.SetRetryPolicy(RetryPolicy{})
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	sim.InjectError(1, errCRC)
+	if _, err := reader.ReadBlock(4); !errors.Is(err, ErrCRC) {
+		t.Errorf("ReadBlock() error = %v, want ErrCRC", err)
+	}
+	if stats := reader.Stats()["read"]; stats.Retries != 0 || stats.Failures != 1 {
+		t.Errorf("read stats = %+v, want 1 failure without retries", stats)
+	}
+}
+
+func TestRetryPermanentError(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	reader, _ := newSimulatedReader(t, card)
+	reader.SetRetryPolicy(RetryPolicy{Retries: 3})
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	_ = reader.SetCardKey(1, KeyA, testKeyA)
+	_ = reader.SetCardKey(1, KeyB, testKeyB)
+
+	if _, err := reader.ReadBlock(4); !errors.Is(err, ErrAuthDenied) {
+		t.Fatalf("ReadBlock() error = %v, want ErrAuthDenied", err)
+	}
+	if stats := reader.Stats()["authenticate"]; stats.Retries != 0 {
+		t.Errorf("authenticate stats = %+v, want no retries", stats)
+	}
+}
+
+func TestRetryWriteReadsBack(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	reader, sim := newSimulatedReader(t, card)
+	reader.SetRetryPolicy(RetryPolicy{Retries: 2})
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// Damage the ACK of the data frame, after the trailer read and the WRITE command:
+	// the card stored the block, the reader cannot tell
+	sim.InjectError(2, errParity)
+	data := []byte("written only one")
+	if err := reader.WriteBlock(4, data); err != nil {
+		t.Fatalf("WriteBlock() error = %v", err)
+	}
+	if !bytes.Equal(card.Block(4), data) {
+		t.Errorf("block 4 = %q, want %q", card.Block(4), data)
+	}
+	if card.writes != 1 {
+		t.Errorf("card saw %d writes, want 1", card.writes)
+	}
+	if stats := reader.Stats()["write"]; stats.Retries != 1 || stats.Failures != 0 {
+		t.Errorf("write stats = %+v, want 1 retry", stats)
+	}
+
+	// A trailer is never written twice, even when it could be read back
+	sim.InjectError(1, errParity)
+	if err := reader.WriteBlock(7, card.Block(7)); err == nil {
+		t.Error("WriteBlock() on a trailer with a damaged ACK succeeded, want an error")
+	}
+	if card.writes != 2 {
+		t.Errorf("card saw %d writes, want 2", card.writes)
+	}
+}
@@ -0,0 +1,172 @@
+package rfid
+
+import (
+	"context"
+	"sync"
+)
+
+// Priority orders the operations waiting for the reader
+type Priority int
+
+const (
+	// PriorityBackground is used for presence polling, which yields to any waiting user operation
+	PriorityBackground Priority = iota
+	// PriorityUser is used for operations requested by a user
+	PriorityUser
+)
+
+// job is an operation queued for the worker
+type job struct {
+	ctx    context.Context
+	run    func() error
+	result chan error
+}
+
+// scheduler owns the worker goroutine through which all chip access is serialized.
+// Exported card operations of Reader queue a closure calling their unexported
+// counterpart, so the unexported methods may only be called on the worker.
+type scheduler struct {
+	user       chan *job
+	background chan *job
+	closing    chan struct{}
+	stopped    chan struct{}
+	closeOnce  sync.Once
+}
+
+// newScheduler creates a scheduler; work must be started to process its queue
+func newScheduler() *scheduler {
+	return &scheduler{
+		user:       make(chan *job),
+		background: make(chan *job),
+		closing:    make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// work runs queued jobs one at a time until the scheduler is closed.
+// A waiting user job is always taken before a background job.
+func (s *scheduler) work() {
+	defer close(s.stopped)
+
+	for {
+		var j *job
+		select {
+		case j = <-s.user:
+		default:
+			select {
+			case j = <-s.user:
+			case j = <-s.background:
+			case <-s.closing:
+				return
+			}
+		}
+
+		// The caller may have given up while the job was being handed over
+		if err := j.ctx.Err(); err != nil {
+			j.result <- err
+			continue
+		}
+		j.result <- j.run()
+	}
+}
+
+// close stops the worker after the running job and waits for it to exit
+func (s *scheduler) close() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	<-s.stopped
+}
+
+// exec queues op with the given priority and waits for its result. If ctx is done before
+// the worker picks op up, op is dropped and the context error returned. While op runs,
+// ctx is the operation context, which is checked before every card command.
+func (r *Reader) exec(ctx context.Context, priority Priority, op func() error) error {
+	queue := r.sched.user
+	if priority == PriorityBackground {
+		queue = r.sched.background
+	}
+
+	run := func() error {
+		r.opCtx, r.opPriority = ctx, priority
+		// Each operation gives the CRC coprocessor another chance, e.g. after a reset
+		r.softwareCRC = false
+		defer func() {
+			r.opCtx = context.Background()
+		}()
+		return op()
+	}
+
+	j := &job{ctx: ctx, run: run, result: make(chan error, 1)}
+	select {
+	case queue <- j:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.sched.closing:
+		return ErrReaderClosed
+	}
+
+	return <-j.result
+}
+
+// run executes op on the worker as a user operation
+func (r *Reader) run(op func() error) error {
+	return r.exec(context.Background(), PriorityUser, op)
+}
+
+// call executes op on the worker as a user operation and returns its result
+func call[T any](r *Reader, op func() (T, error)) (T, error) {
+	var result T
+	err := r.run(func() error {
+		var err error
+		result, err = op()
+		return err
+	})
+	return result, err
+}
+
+// callContext executes op on the worker as a user operation bounded by ctx. Unless ctx
+// carries a deadline, op is given the operation timeout to complete.
+func callContext[T any](ctx context.Context, r *Reader, op func() (T, error)) (T, error) {
+	var result T
+	err := r.exec(ctx, PriorityUser, func() error {
+		var cancel context.CancelFunc
+		r.opCtx, cancel = r.withOperationTimeout(ctx)
+		defer cancel()
+
+		var err error
+		result, err = op()
+		return err
+	})
+	return result, err
+}
+
+// callCancelable executes op on the worker as a user operation that stops once ctx is
+// done. Unlike callContext it applies no operation timeout, for sweeps that run far longer
+// than a single operation.
+func callCancelable[T any](ctx context.Context, r *Reader, op func() (T, error)) (T, error) {
+	var result T
+	err := r.exec(ctx, PriorityUser, func() error {
+		var err error
+		result, err = op()
+		return err
+	})
+	return result, err
+}
+
+// withOperationTimeout returns ctx limited to the operation timeout, unless it already
+// carries a deadline. The time spent waiting for the worker does not count.
+func (r *Reader) withOperationTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.opTimeout)
+}
+
+// canceled returns the error of the operation context once it is cancelled or expired
+func (r *Reader) canceled() error {
+	if r.opCtx == nil {
+		return nil
+	}
+	return r.opCtx.Err()
+}
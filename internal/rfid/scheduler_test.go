@@ -0,0 +1,312 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockWorker occupies the worker until the returned function is called
+func blockWorker(t *testing.T, reader *Reader) func() {
+	t.Helper()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = reader.run(func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	return func() { close(release) }
+}
+
+func TestSchedulerConcurrentAccess(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	card.SetBlock(4, []byte("shared block 4.."))
+	reader, _ := newSimulatedReader(t, card)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2; j++ {
+				reader.IsCardPresent()
+				_ = reader.GetLastCard()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2; j++ {
+				data, err := reader.ReadBlock(4)
+				if err == nil && !bytes.Equal(data, []byte("shared block 4..")) {
+					err = errors.New("wrong block contents")
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("ReadBlock() during polling error = %v", err)
+	}
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	reader, _ := newSimulatedReader(t)
+	release := blockWorker(t, reader)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = reader.exec(context.Background(), PriorityBackground, record("background"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		defer wg.Done()
+		_ = reader.exec(context.Background(), PriorityUser, record("user"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	release()
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != "user" {
+		t.Errorf("execution order = %v, want the user operation first", order)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	reader, _ := newSimulatedReader(t)
+	release := blockWorker(t, reader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+	done := make(chan error)
+	go func() {
+		done <- reader.exec(ctx, PriorityUser, func() error {
+			ran = true
+			return nil
+		})
+	}()
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("exec() with a cancelled context error = %v, want context.Canceled", err)
+	}
+	release()
+
+	// A later operation still runs, and the cancelled one never did
+	if err := reader.run(func() error { return nil }); err != nil {
+		t.Errorf("run() error = %v", err)
+	}
+	if ran {
+		t.Error("cancelled operation ran")
+	}
+}
+
+func TestSession(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	reader, _ := newSimulatedReader(t, card)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if err := reader.WriteValue(4, 100, 4); err != nil {
+		t.Fatalf("WriteValue() error = %v", err)
+	}
+
+	// Polling in the background cannot come between the decrement and its transfer
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				reader.IsCardPresent()
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		err := reader.Session(context.Background(), func(s *Session) error {
+			if err := s.Decrement(4, 10); err != nil {
+				return err
+			}
+			return s.Transfer(4)
+		})
+		if err != nil {
+			t.Fatalf("Session() error = %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if value, _, err := reader.ReadValue(4); err != nil || value != 50 {
+		t.Errorf("ReadValue() = %d, %v, want 50", value, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := reader.Session(ctx, func(s *Session) error {
+		cancel()
+		_, err := s.ReadBlock(4)
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Session() after cancel error = %v, want context.Canceled", err)
+	}
+}
+
+func TestReaderClose(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualClassic1K([]byte{0x01, 0x02, 0x03, 0x04}))
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := reader.ScanForCard(); !errors.Is(err, ErrReaderClosed) {
+		t.Errorf("ScanForCard() after Close() error = %v, want ErrReaderClosed", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+// commandHook is a transport that calls fn whenever the MFRC522 starts a card command
+type commandHook struct {
+	Transport
+	fn func()
+}
+
+func (h *commandHook) WriteRegister(reg, value byte) error {
+	if reg == CommandReg && (value == PCDTransceive || value == PCDAuthent) {
+		h.fn()
+	}
+	return h.Transport.WriteRegister(reg, value)
+}
+
+// hookCommands makes the reader call fn before every command sent to a card
+func hookCommands(reader *Reader, fn func()) {
+	_ = reader.run(func() error {
+		reader.transport = &commandHook{Transport: reader.transport, fn: fn}
+		return nil
+	})
+}
+
+func TestReadCardContextCancel(t *testing.T) {
+	card := NewVirtualClassic4K([]byte{0x12, 0x34, 0x56, 0x78})
+	card.SetBlock(4, []byte("still readable.."))
+	reader, _ := newSimulatedReader(t, card)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// The client goes away a few commands into the dump
+	ctx, cancel := context.WithCancel(context.Background())
+	commands := 0
+	hookCommands(reader, func() {
+		commands++
+		if commands == 20 {
+			cancel()
+		}
+	})
+
+	if _, err := reader.ReadCardContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadCardContext() after cancel error = %v, want context.Canceled", err)
+	}
+	if commands != 20 {
+		t.Errorf("dump sent %d commands after being cancelled", commands-20)
+	}
+
+	// The card is left in a usable state
+	data, err := reader.ReadBlock(4)
+	if err != nil || string(data) != "still readable.." {
+		t.Errorf("ReadBlock() after a cancelled dump = %q, %v", data, err)
+	}
+}
+
+func TestOperationTimeoutDeadline(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualClassic4K([]byte{0x12, 0x34, 0x56, 0x78}))
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// Every command takes a millisecond, so a whole 4K dump cannot finish in time
+	reader.SetOperationTimeout(50 * time.Millisecond)
+	hookCommands(reader, func() { time.Sleep(time.Millisecond) })
+
+	if _, err := reader.ReadCard(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ReadCard() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// A deadline on the context replaces the operation timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	data, err := reader.ReadCardContext(ctx)
+	if err != nil || len(data) != 256 {
+		t.Errorf("ReadCardContext() = %d blocks, %v, want 256", len(data), err)
+	}
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Errorf("ReadBlock() error = %v", err)
+	}
+
+	// The command timeout bounds each command, not the dump as a whole
+	reader.SetOperationTimeout(10 * time.Second)
+	reader.SetCommandTimeout(20 * time.Millisecond)
+	if data, err := reader.ReadCard(); err != nil || len(data) != 256 {
+		t.Errorf("ReadCard() with a short command timeout = %d blocks, %v, want 256", len(data), err)
+	}
+}
+
+func TestCalibrateContextCancel(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualClassic1K([]byte{0x12, 0x34, 0x56, 0x78}))
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	original := reader.Tuning()
+
+	// Cancelling stops the sweep between settings and restores the tuning
+	ctx, cancel := context.WithCancel(context.Background())
+	settings := 0
+	_, err := reader.CalibrateContext(ctx, 1, func(CalibrationResult) {
+		if settings++; settings == 2 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("CalibrateContext() error = %v, want context.Canceled", err)
+	}
+	if settings != 2 {
+		t.Errorf("CalibrateContext() ran %d settings after cancel, want 2", settings)
+	}
+	if got := reader.Tuning(); got != original {
+		t.Errorf("Tuning() = %+v after a cancelled calibration, want %+v", got, original)
+	}
+}
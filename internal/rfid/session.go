@@ -0,0 +1,211 @@
+package rfid
+
+import "context"
+
+// Session is exclusive use of the reader for a sequence of operations on one card.
+// No other operation, including presence polling, can reselect the card, halt it or
+// drop its authentication between the steps of a session. A Session is only valid
+// inside the function passed to Reader.Session.
+type Session struct {
+	r   *Reader
+	ctx context.Context
+}
+
+// Session waits for the reader, then runs fn with exclusive access to it. ctx bounds the
+// wait and is checked before each card command of the session; without a deadline on ctx
+// the session as a whole is given the operation timeout. fn must use the Session for card
+// operations: calling the Reader's own operations from fn deadlocks.
+func (r *Reader) Session(ctx context.Context, fn func(s *Session) error) error {
+	return r.exec(ctx, PriorityUser, func() error {
+		var cancel context.CancelFunc
+		r.opCtx, cancel = r.withOperationTimeout(ctx)
+		defer cancel()
+
+		return fn(&Session{r: r, ctx: r.opCtx})
+	})
+}
+
+// Card returns the card the session operates on, or nil if none has been scanned
+func (s *Session) Card() *Card {
+	return s.r.lastCard
+}
+
+// ScanForCard scans for a card and makes it the card of the session
+func (s *Session) ScanForCard() (*Card, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.scanForCard()
+}
+
+// ScanForCards returns every card in the field, leaving none selected
+func (s *Session) ScanForCards() ([]*Card, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.scanForCards()
+}
+
+// SelectCard selects the card with the given UID and makes it the card of the session
+func (s *Session) SelectCard(uid []byte) (*Card, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.selectCardByUID(uid)
+}
+
+// ReadBlock reads a block of a MIFARE Classic card
+func (s *Session) ReadBlock(block int) ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.readBlock(block)
+}
+
+// WriteBlock writes a block of a MIFARE Classic card
+func (s *Session) WriteBlock(block int, data []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.writeBlock(block, data)
+}
+
+// ReadCard reads all accessible blocks or pages of the card
+func (s *Session) ReadCard() (map[int][]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.readCard()
+}
+
+// ReadPages reads four pages of an Ultralight or NTAG card
+func (s *Session) ReadPages(page int) ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.readPages(page)
+}
+
+// WritePage writes one page of an Ultralight or NTAG card, refusing configuration pages
+func (s *Session) WritePage(page int, data []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.writePage(page, data, false)
+}
+
+// WriteConfigPage writes one page including the UID, lock, OTP and configuration pages
+func (s *Session) WriteConfigPage(page int, data []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.writePage(page, data, true)
+}
+
+// CompatibilityWritePage writes one page with the 16-byte MIFARE Classic WRITE frame,
+// refusing configuration pages
+func (s *Session) CompatibilityWritePage(page int, data []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.compatibilityWritePage(page, data)
+}
+
+// ReadValue reads a value block and returns its value and address byte
+func (s *Session) ReadValue(block int) (int32, byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	return s.r.readValue(block)
+}
+
+// WriteValue formats a block as a value block holding value
+func (s *Session) WriteValue(block int, value int32, addr byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.writeValue(block, value, addr)
+}
+
+// Increment adds delta to a value block and keeps the result in the transfer buffer
+func (s *Session) Increment(block int, delta uint32) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.increment(block, delta)
+}
+
+// Decrement subtracts delta from a value block and keeps the result in the transfer buffer
+func (s *Session) Decrement(block int, delta uint32) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.decrement(block, delta)
+}
+
+// Restore loads a value block into the transfer buffer unchanged
+func (s *Session) Restore(block int) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.restore(block)
+}
+
+// Transfer writes the transfer buffer to a value block. Within a session nothing can come
+// between the preceding Increment, Decrement or Restore and the Transfer.
+func (s *Session) Transfer(block int) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.r.transfer(block)
+}
+
+// Transceive sends a raw frame to the field and returns the answer. A sequence of frames
+// sent in one session reaches the card without presence polling in between.
+func (s *Session) Transceive(frame []byte, txBits, rxAlign int, crc, parity bool) (*RawResponse, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.transceive(frame, txBits, rxAlign, crc, parity)
+}
+
+// GetVersion sends GET_VERSION to the card of the session
+func (s *Session) GetVersion() (*VersionInfo, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.cardVersion()
+}
+
+// FastRead reads pages start through end (inclusive) with FAST_READ
+func (s *Session) FastRead(start, end int) ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.fastReadPages(start, end)
+}
+
+// ReadCounter reads a 24-bit one-way counter with READ_CNT
+func (s *Session) ReadCounter(counter int) (uint32, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.r.readCounter(counter)
+}
+
+// ReadSignature reads the 32-byte ECC originality signature with READ_SIG
+func (s *Session) ReadSignature() ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.readSignature()
+}
+
+// PasswordAuth unlocks password-protected pages with PWD_AUTH and returns the PACK. The
+// pages stay unlocked for the rest of the session.
+func (s *Session) PasswordAuth(password []byte) ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.r.passwordAuth(password)
+}
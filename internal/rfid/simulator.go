@@ -0,0 +1,975 @@
+package rfid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// Simulator register bits
+const (
+	simIrqSet   = 0x80 // Set1/Set2 bit of ComIrqReg/DivIrqReg
+	simTxIRq    = 0x40
+	simRxIRq    = 0x20
+	simIdleIRq  = 0x10
+	simTimerIRq = 0x01
+	simCRCIRq   = 0x04 // DivIrqReg
+	simIRqInv   = 0x80 // ComIEnReg
+
+	simStartSend = 0x80 // BitFramingReg
+	simFlush     = 0x80 // FIFOLevelReg
+	simPowerDown = 0x10 // CommandReg
+	simCrypto1On = 0x08 // Status2Reg
+	simBufferOvf = 0x10 // ErrorReg
+	simCollErr   = 0x08 // ErrorReg
+	simCollPos   = 0x1F // CollReg
+
+	simFIFOSize = 64
+)
+
+// piccState is the ISO/IEC 14443-3 state of a virtual card
+type piccState int
+
+const (
+	piccIdle piccState = iota
+	piccReady
+	piccActive
+	piccHalt
+)
+
+// VirtualCard is a MIFARE card held in the field of a Simulator
+type VirtualCard struct {
+	UID    []byte
+	ATQA   [2]byte
+	SAK    byte
+	memory []byte
+
+	// Counter is the value returned by READ_CNT on EV1/NTAG cards
+	Counter   uint32
+	version   []byte
+	signature []byte
+	cfgPage   int
+
+	ultralight   bool
+	ultralightC  bool
+	pwdAuth      bool
+	state        piccState
+	cascadeLevel int
+	authTrailer  int
+	authKey      KeyType
+	pendingWrite int
+	writes       int // Completed block writes
+
+	// Value block operations: the command waiting for its operand and the transfer buffer
+	pendingValue  byte
+	valueBlock    int
+	transferValue int32
+	transferAddr  byte
+	transferValid bool
+}
+
+// NewVirtualClassic1K creates a blank MIFARE Classic 1K card in transport configuration
+func NewVirtualClassic1K(uid []byte) *VirtualCard {
+	return newVirtualClassic(uid, [2]byte{0x04, 0x00}, 0x08, 64)
+}
+
+// NewVirtualClassic4K creates a blank MIFARE Classic 4K card in transport configuration
+func NewVirtualClassic4K(uid []byte) *VirtualCard {
+	return newVirtualClassic(uid, [2]byte{0x02, 0x00}, 0x18, 256)
+}
+
+// newVirtualClassic builds a Classic card with default keys and access bits in every trailer
+func newVirtualClassic(uid []byte, atqa [2]byte, sak byte, blocks int) *VirtualCard {
+	switch len(uid) {
+	case 7:
+		atqa[0] |= 0x40 // Double-size UID
+	case 10:
+		atqa[0] |= 0x80 // Triple-size UID
+	}
+
+	card := &VirtualCard{
+		UID:    append([]byte(nil), uid...),
+		ATQA:   atqa,
+		SAK:    sak,
+		memory: make([]byte, blocks*16),
+	}
+	card.reset()
+
+	// Manufacturer block
+	copy(card.memory, uid)
+	if len(uid) == 4 {
+		card.memory[4] = uid[0] ^ uid[1] ^ uid[2] ^ uid[3]
+		card.memory[5] = sak
+		card.memory[6] = atqa[0]
+		card.memory[7] = atqa[1]
+	}
+
+	// Sector trailers: Key A, access bits FF 07 80, GPB 69, Key B
+	trailer := []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xFF, 0x07, 0x80, 0x69,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+	}
+	for block := 0; block < blocks; block++ {
+		if simClassicTrailer(block) == block {
+			copy(card.memory[block*16:], trailer)
+		}
+	}
+
+	return card
+}
+
+// Block returns a copy of a 16-byte block of card memory
+func (c *VirtualCard) Block(block int) []byte {
+	return append([]byte(nil), c.memory[block*16:(block+1)*16]...)
+}
+
+// SetBlock overwrites a 16-byte block of card memory
+func (c *VirtualCard) SetBlock(block int, data []byte) {
+	copy(c.memory[block*16:(block+1)*16], data)
+}
+
+// reset returns the card to the IDLE state, as after a field reset
+func (c *VirtualCard) reset() {
+	c.state = piccIdle
+	c.cascadeLevel = 0
+	c.authTrailer = -1
+	c.pendingWrite = -1
+	c.pendingValue = 0
+	c.transferValid = false
+	c.pwdAuth = false
+}
+
+// blocks returns the number of 16-byte blocks on the card
+func (c *VirtualCard) blocks() int {
+	return len(c.memory) / 16
+}
+
+// cascadeUID returns the four UID bytes transmitted at a cascade level, including the cascade tag
+func (c *VirtualCard) cascadeUID(level int) []byte {
+	switch {
+	case len(c.UID) == 4 && level == 0:
+		return c.UID
+	case len(c.UID) == 7 && level == 0:
+		return []byte{0x88, c.UID[0], c.UID[1], c.UID[2]}
+	case len(c.UID) == 7 && level == 1:
+		return c.UID[3:7]
+	case len(c.UID) == 10 && level == 0:
+		return []byte{0x88, c.UID[0], c.UID[1], c.UID[2]}
+	case len(c.UID) == 10 && level == 1:
+		return []byte{0x88, c.UID[3], c.UID[4], c.UID[5]}
+	case len(c.UID) == 10 && level == 2:
+		return c.UID[6:10]
+	}
+	return nil
+}
+
+// lastCascadeLevel returns the index of the cascade level that completes the UID
+func (c *VirtualCard) lastCascadeLevel() int {
+	switch len(c.UID) {
+	case 7:
+		return 1
+	case 10:
+		return 2
+	}
+	return 0
+}
+
+// respond processes a frame sent by the PCD and returns the card's answer.
+// A nil answer means the card stays silent; rxBits is the number of valid bits in the
+// last byte, 0 meaning the whole byte.
+func (c *VirtualCard) respond(frame []byte, txBits int) (answer []byte, rxBits int) {
+	if txBits == 7 && len(frame) == 1 {
+		return c.respondShortFrame(frame[0])
+	}
+
+	switch c.state {
+	case piccReady:
+		answer, ready := c.respondAnticollision(frame)
+		if !ready {
+			c.reset()
+		}
+		return answer, 0
+	case piccActive:
+		return c.respondMemory(frame)
+	case piccIdle, piccHalt:
+	}
+	return nil, 0
+}
+
+// respondShortFrame answers REQA and WUPA.
+// A card that is already READY or ACTIVE treats them as an unexpected frame and returns to IDLE.
+func (c *VirtualCard) respondShortFrame(cmd byte) ([]byte, int) {
+	switch {
+	case cmd == PICCReqIDL && c.state == piccIdle,
+		cmd == PICCReqAll && (c.state == piccIdle || c.state == piccHalt):
+		c.reset()
+		c.state = piccReady
+		return []byte{c.ATQA[0], c.ATQA[1]}, 0
+	case c.state == piccReady || c.state == piccActive:
+		c.reset()
+	}
+	return nil, 0
+}
+
+// respondAnticollision answers ANTICOLLISION and SELECT for the current cascade level.
+// An ANTICOLLISION frame carries the UID bits the PCD already knows: a card whose UID
+// starts with them answers the remaining bits, aligned as they arrive in the FIFO, and
+// one that does not stays silent but READY. ready is false when the card leaves READY.
+func (c *VirtualCard) respondAnticollision(frame []byte) (answer []byte, ready bool) {
+	level := c.cascadeLevel
+	if len(frame) < 2 || frame[0] != cascadeLevels[level] {
+		return nil, false
+	}
+	uid := c.cascadeUID(level)
+	bcc := uid[0] ^ uid[1] ^ uid[2] ^ uid[3]
+
+	if frame[1] == 0x70 {
+		sel := framePayload(frame, 7)
+		if sel == nil || !bytes.Equal(sel[2:6], uid) || sel[6] != bcc {
+			return nil, false
+		}
+		if level < c.lastCascadeLevel() {
+			c.cascadeLevel++
+			return appendCRCA([]byte{0x04}), true
+		}
+		c.state = piccActive
+		return appendCRCA([]byte{c.SAK}), true
+	}
+
+	known, ok := anticollisionBits(frame)
+	if !ok {
+		return nil, false
+	}
+	full := append(append([]byte(nil), uid...), bcc)
+	if !prefixMatches(frame[2:], full, known) {
+		return nil, true
+	}
+	answer = full[known/8:]
+	answer[0] &= 0xFF << (known % 8)
+	return answer, true
+}
+
+// anticollisionBits returns the number of UID bits sent in an ANTICOLLISION frame, as
+// encoded by its NVB byte: the number of whole bytes, including SEL and NVB, and bits.
+func anticollisionBits(frame []byte) (int, bool) {
+	if len(frame) < 2 || frame[1]>>4 < 2 {
+		return 0, false
+	}
+	known := int(frame[1]>>4-2)*8 + int(frame[1]&0x0F)
+	if known >= 32 || len(frame) != 2+(known+7)/8 {
+		return 0, false
+	}
+	return known, true
+}
+
+// prefixMatches reports whether the first n bits of a and b, least significant bit first, are equal
+func prefixMatches(a, b []byte, n int) bool {
+	for i := 0; i < n; i++ {
+		if (a[i/8]>>(i%8))&1 != (b[i/8]>>(i%8))&1 {
+			return false
+		}
+	}
+	return true
+}
+
+// respondMemory answers HALT, READ and WRITE
+func (c *VirtualCard) respondMemory(frame []byte) ([]byte, int) {
+	if c.ultralight {
+		return c.respondUltralight(frame)
+	}
+
+	if c.pendingWrite >= 0 {
+		block := c.pendingWrite
+		c.pendingWrite = -1
+		data := framePayload(frame, 16)
+		if data == nil {
+			return c.nak()
+		}
+		c.writeBlock(block, data)
+		c.writes++
+		return []byte{piccACK}, 4
+	}
+
+	if c.pendingValue != 0 {
+		return c.valueOperand(frame)
+	}
+
+	cmd := framePayload(frame, 2)
+	if cmd == nil {
+		c.reset()
+		return nil, 0
+	}
+
+	switch cmd[0] {
+	case PICCHalt:
+		c.reset()
+		c.state = piccHalt
+		return nil, 0
+	case PICCRead:
+		block := int(cmd[1])
+		if !c.permitted(block, accessRead) {
+			return c.nak()
+		}
+		return appendCRCA(c.blockAsRead(block)), 0
+	case PICCWrite:
+		block := int(cmd[1])
+		if block == 0 || !c.permitted(block, accessWrite) {
+			return c.nak()
+		}
+		c.pendingWrite = block
+		return []byte{piccACK}, 4
+	case PICCIncrement, PICCDecrement, PICCRestore:
+		block := int(cmd[1])
+		op := accessDecrement
+		if cmd[0] == PICCIncrement {
+			op = accessIncrement
+		}
+		if _, _, err := DecodeValueBlock(c.Block(block)); err != nil || !c.permitted(block, op) {
+			return c.nak()
+		}
+		c.pendingValue = cmd[0]
+		c.valueBlock = block
+		return []byte{piccACK}, 4
+	case PICCTransfer:
+		block := int(cmd[1])
+		if !c.transferValid || block == 0 || !c.permitted(block, accessDecrement) {
+			return c.nak()
+		}
+		c.SetBlock(block, EncodeValueBlock(c.transferValue, c.transferAddr))
+		c.transferValid = false
+		return []byte{piccACK}, 4
+	}
+	return c.nak()
+}
+
+// valueOperand applies the operand of INCREMENT, DECREMENT or RESTORE to the transfer buffer.
+// The card stays silent unless it rejects the operand.
+func (c *VirtualCard) valueOperand(frame []byte) ([]byte, int) {
+	command := c.pendingValue
+	c.pendingValue = 0
+
+	operand := framePayload(frame, 4)
+	if operand == nil {
+		return c.nak()
+	}
+
+	value, addr, _ := DecodeValueBlock(c.Block(c.valueBlock))
+	delta := int32(binary.LittleEndian.Uint32(operand))
+	switch command {
+	case PICCIncrement:
+		value += delta
+	case PICCDecrement:
+		value -= delta
+	}
+
+	c.transferValue = value
+	c.transferAddr = addr
+	c.transferValid = true
+	return nil, 0
+}
+
+// sectorAccess decodes the access bits of the sector containing block
+func (c *VirtualCard) sectorAccess(block int) (AccessBits, bool) {
+	trailer := simClassicTrailer(block)
+	bits, err := DecodeAccessBits(c.memory[trailer*16 : (trailer+1)*16])
+	return bits, err == nil
+}
+
+// permitted reports whether the open sector and key allow op on block.
+// Sectors with corrupted access bits are permanently blocked.
+func (c *VirtualCard) permitted(block int, op accessOp) bool {
+	if !c.authenticated(block) {
+		return false
+	}
+	bits, ok := c.sectorAccess(block)
+	return ok && bits.permittedKeys(block, op).Allows(c.authKey)
+}
+
+// blockAsRead returns a block as the card transmits it.
+// Trailer parts the open key may not read are returned as zeros; Key A never reads back.
+func (c *VirtualCard) blockAsRead(block int) []byte {
+	data := c.Block(block)
+	if simClassicTrailer(block) != block {
+		return data
+	}
+
+	bits, _ := c.sectorAccess(block)
+	copy(data[:6], make([]byte, 6))
+	if !bits[3].Trailer().ReadKeyB.Allows(c.authKey) {
+		copy(data[10:], make([]byte, 6))
+	}
+	return data
+}
+
+// writeBlock stores a written block. A trailer write only changes the parts the
+// open key may write; the GPB byte follows the access bits.
+func (c *VirtualCard) writeBlock(block int, data []byte) {
+	if simClassicTrailer(block) != block {
+		c.SetBlock(block, data)
+		return
+	}
+
+	bits, _ := c.sectorAccess(block)
+	permissions := bits[3].Trailer()
+	offset := block * 16
+	if permissions.WriteKeyA.Allows(c.authKey) {
+		copy(c.memory[offset:offset+6], data[:6])
+	}
+	if permissions.WriteAccessBits.Allows(c.authKey) {
+		copy(c.memory[offset+6:offset+10], data[6:10])
+	}
+	if permissions.WriteKeyB.Allows(c.authKey) {
+		copy(c.memory[offset+10:offset+16], data[10:])
+	}
+}
+
+// nak answers with a NAK, after which the card falls back to IDLE
+func (c *VirtualCard) nak() ([]byte, int) {
+	c.reset()
+	return []byte{piccNAK}, 4
+}
+
+// authenticate checks a key against the sector trailer of block and opens the sector on success
+func (c *VirtualCard) authenticate(mode byte, block int, key []byte) bool {
+	if block >= c.blocks() {
+		return false
+	}
+	trailer := simClassicTrailer(block)
+	keyBytes := c.memory[trailer*16 : trailer*16+6]
+	if mode == PICCAuthent1B {
+		keyBytes = c.memory[trailer*16+10 : trailer*16+16]
+	}
+	if !bytes.Equal(keyBytes, key) {
+		c.reset()
+		return false
+	}
+	c.authTrailer = trailer
+	c.authKey = KeyType(mode)
+	return true
+}
+
+// authenticated reports whether block lies in the currently authenticated sector
+func (c *VirtualCard) authenticated(block int) bool {
+	return block < c.blocks() && c.authTrailer >= 0 && simClassicTrailer(block) == c.authTrailer
+}
+
+// simClassicTrailer returns the trailer block of the sector containing block
+func simClassicTrailer(block int) int {
+	return trailerOfSector(sectorOfBlock(block))
+}
+
+// framePayload strips the CRC_A from a frame of n payload bytes.
+// It returns nil when the frame has the wrong length or a bad CRC, which cards ignore.
+func framePayload(frame []byte, n int) []byte {
+	if len(frame) != n+2 || !checkCRCA(frame) {
+		return nil
+	}
+	return frame[:n]
+}
+
+// Simulator is an in-memory model of an MFRC522 and the cards in its field.
+// It implements Transport, so a Reader built with NewReaderWithTransport can be
+// exercised without hardware.
+type Simulator struct {
+	cards           []*VirtualCard
+	fifo            []byte
+	mu              sync.Mutex
+	authentications int
+	regs            [0x40]byte
+	version         byte
+	irq             *simIRQLine
+	speed           int // Clock rate set through SetSpeed
+
+	// Fault injection
+	crcStuck    bool // The CRC coprocessor never completes
+	corruptCRC  bool // Answers carrying a CRC_A arrive with a bad CRC
+	injectError byte // ErrorReg bits raised with an upcoming answer
+	injectSkip  int  // Answers to let through before injectError applies
+	authError   byte // ErrorReg bits raised with the next successful authentication
+
+	spiLimit int  // Clock rate above which register reads are corrupted, 0 for none
+	detached bool // The chip is not connected: reads return 0x00 and writes are lost
+
+	weakGain     int // Receiver gain below which card answers are lost, 0 to hear every answer
+	weakMinLevel int // Receiver threshold above which card answers are lost
+}
+
+// NewSimulator creates a simulated MFRC522 with an empty field
+func NewSimulator() *Simulator {
+	s := &Simulator{version: 0x92, irq: newSimIRQLine()}
+	s.softReset()
+	return s
+}
+
+// AddCard places a card in the field
+func (s *Simulator) AddCard(card *VirtualCard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	card.reset()
+	s.cards = append(s.cards, card)
+}
+
+// RemoveCard takes a card out of the field
+func (s *Simulator) RemoveCard(card *VirtualCard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, c := range s.cards {
+		if c == card {
+			s.cards = append(s.cards[:i], s.cards[i+1:]...)
+			break
+		}
+	}
+	if card.authTrailer >= 0 {
+		s.regs[Status2Reg] &^= simCrypto1On
+	}
+	card.reset()
+}
+
+// SetCRCCoprocessorStuck makes CalcCRC hang so that the reader has to fall back to software
+func (s *Simulator) SetCRCCoprocessorStuck(stuck bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crcStuck = stuck
+}
+
+// SetVersion sets the value of VersionReg, to model other chip revisions and clones
+func (s *Simulator) SetVersion(version byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// SetCorruptCRC flips a bit in the CRC_A of every card answer that carries one
+func (s *Simulator) SetCorruptCRC(corrupt bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.corruptCRC = corrupt
+}
+
+// InjectError raises errorReg bits in ErrorReg with a single card answer, the one after the
+// next skip answers. It models a frame damaged once in the field.
+func (s *Simulator) InjectError(skip int, errorReg byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injectSkip = skip
+	s.injectError = errorReg
+}
+
+// InjectAuthError raises errorReg bits in ErrorReg instead of completing the next
+// authentication with a correct key, as if the card's answer was damaged in the field
+func (s *Simulator) InjectAuthError(errorReg byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authError = errorReg
+}
+
+// SetWeakField models cards far from the antenna: they still receive every command,
+// but their answers are only heard with a receiver gain of at least gainDB and a
+// RxThresholdReg MinLevel of at most minLevel. A zero gainDB hears every answer again.
+func (s *Simulator) SetWeakField(gainDB, minLevel int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weakGain = gainDB
+	s.weakMinLevel = minLevel
+}
+
+// SetSPILimit models a link that is only reliable up to hz, as with long wires: at a
+// faster clock every byte read has its lowest bit flipped. Zero makes every clock reliable.
+func (s *Simulator) SetSPILimit(hz int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spiLimit = hz
+}
+
+// SetDetached disconnects the chip from the bus, as a loose jumper or a missing module
+// would: reads return 0x00 and writes are lost until it is attached again
+func (s *Simulator) SetDetached(detached bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detached = detached
+}
+
+// SetSpeed implements ClockedTransport
+func (s *Simulator) SetSpeed(hz int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.speed = hz
+	return nil
+}
+
+// ReadRegister implements Transport
+func (s *Simulator) ReadRegister(reg byte) (byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.detached {
+		return 0x00, nil
+	}
+	value := s.readRegister(reg & 0x3F)
+	if s.spiLimit != 0 && s.speed > s.spiLimit {
+		value ^= 0x01
+	}
+	return value, nil
+}
+
+// readRegister returns the value of a register, taking FIFO bytes out of the FIFO
+func (s *Simulator) readRegister(reg byte) byte {
+	switch reg {
+	case FIFODataReg:
+		if len(s.fifo) == 0 {
+			return 0
+		}
+		value := s.fifo[0]
+		s.fifo = s.fifo[1:]
+		return value
+	case FIFOLevelReg:
+		return byte(len(s.fifo))
+	case VersionReg:
+		return s.version
+	}
+	return s.regs[reg]
+}
+
+// WriteRegister implements Transport
+func (s *Simulator) WriteRegister(reg, value byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.detached {
+		return nil
+	}
+
+	reg &= 0x3F
+	switch reg {
+	case CommandReg:
+		s.regs[CommandReg] = value
+		if value&simPowerDown != 0 {
+			// Soft power-down stops the oscillator and the antenna drivers
+			s.fieldOff()
+			break
+		}
+		s.execute(value & 0x0F)
+	case ComIrqReg, DivIrqReg:
+		if value&simIrqSet != 0 {
+			s.regs[reg] |= value &^ simIrqSet
+		} else {
+			s.regs[reg] &^= value
+		}
+	case FIFODataReg:
+		if len(s.fifo) >= simFIFOSize {
+			s.regs[ErrorReg] |= simBufferOvf
+			return nil
+		}
+		s.fifo = append(s.fifo, value)
+	case FIFOLevelReg:
+		if value&simFlush != 0 {
+			s.fifo = nil
+			s.regs[ErrorReg] &^= simBufferOvf
+		}
+	case BitFramingReg:
+		s.regs[BitFramingReg] = value
+		if value&simStartSend != 0 && s.regs[CommandReg]&0x0F == PCDTransceive {
+			s.transceive()
+		}
+	case Status2Reg:
+		s.regs[Status2Reg] = value
+		if value&simCrypto1On == 0 {
+			for _, card := range s.cards {
+				card.authTrailer = -1
+			}
+		}
+	case TxControlReg:
+		s.regs[TxControlReg] = value
+		if !s.antennaOn() {
+			s.fieldOff()
+		}
+	case VersionReg:
+		// Read-only
+	default:
+		s.regs[reg] = value
+	}
+	s.updateIRQ()
+	return nil
+}
+
+// irqLine returns the simulated IRQ output of the chip
+func (s *Simulator) irqLine() *simIRQLine {
+	return s.irq
+}
+
+// updateIRQ drives the IRQ line from the enabled, pending interrupts
+func (s *Simulator) updateIRQ() {
+	pending := s.regs[ComIrqReg]&s.regs[ComIEnReg]&0x7F != 0 ||
+		s.regs[DivIrqReg]&s.regs[DivIEnReg]&0x14 != 0
+	level := gpio.Level(pending)
+	if s.regs[ComIEnReg]&simIRqInv != 0 {
+		level = !level
+	}
+	s.irq.set(level)
+}
+
+// simIRQLine models the MFRC522 IRQ pin as seen through a GPIO with falling edge detection
+type simIRQLine struct {
+	mu    sync.Mutex
+	level gpio.Level
+	edges chan struct{}
+	waits int
+}
+
+// newSimIRQLine creates an IRQ line resting high
+func newSimIRQLine() *simIRQLine {
+	return &simIRQLine{level: gpio.High, edges: make(chan struct{}, 1)}
+}
+
+// set changes the level of the line, recording falling edges
+func (l *simIRQLine) set(level gpio.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.level == gpio.High && level == gpio.Low {
+		select {
+		case l.edges <- struct{}{}:
+		default:
+		}
+	}
+	l.level = level
+}
+
+// In implements IRQLine; pending edges are discarded
+func (l *simIRQLine) In(gpio.Pull, gpio.Edge) error {
+	select {
+	case <-l.edges:
+	default:
+	}
+	return nil
+}
+
+// Read implements IRQLine
+func (l *simIRQLine) Read() gpio.Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level
+}
+
+// WaitForEdge implements IRQLine
+func (l *simIRQLine) WaitForEdge(timeout time.Duration) bool {
+	l.mu.Lock()
+	l.waits++
+	l.mu.Unlock()
+
+	select {
+	case <-l.edges:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// softReset loads the register reset values from the MFRC522 datasheet
+func (s *Simulator) softReset() {
+	s.regs = [0x40]byte{}
+	s.fifo = nil
+	s.regs[CommandReg] = 0x20
+	s.regs[ComIEnReg] = 0x80
+	s.regs[ComIrqReg] = 0x14
+	s.regs[WaterLevelReg] = 0x08
+	s.regs[ControlReg] = 0x10
+	s.regs[CollReg] = 0x80
+	s.regs[ModeReg] = 0x3F
+	s.regs[TxControlReg] = 0x80
+	s.regs[TxSelReg] = 0x10
+	s.regs[RxSelReg] = 0x84
+	s.regs[RxThresholdReg] = 0x84
+	s.regs[DemodReg] = 0x4D
+	s.regs[MfTxReg] = 0x62
+	s.regs[SerialSpeedReg] = 0xEB
+	s.regs[CRCResultRegH] = 0xFF
+	s.regs[CRCResultRegL] = 0xFF
+	s.regs[ModWidthReg] = 0x26
+	s.regs[RFCfgReg] = 0x48
+	s.regs[GsNReg] = 0x88
+	s.regs[CWGsPReg] = 0x20
+	s.regs[ModGsPReg] = 0x20
+	s.regs[AutoTestReg] = 0x40
+	for _, card := range s.cards {
+		card.reset()
+	}
+}
+
+// execute runs a command written to CommandReg
+func (s *Simulator) execute(command byte) {
+	switch command {
+	case PCDResetPhase:
+		s.softReset()
+	case PCDAuthent:
+		s.authenticate()
+	case PCDMem:
+		// The internal buffer is only used by the self-test, which ignores its content
+		s.fifo = s.fifo[min(len(s.fifo), 25):]
+	case PCDCalcCRC:
+		if s.crcStuck {
+			return
+		}
+		if s.regs[AutoTestReg]&0x0F == 0x09 {
+			s.selfTest()
+			return
+		}
+		crc := crcA(s.fifo)
+		s.fifo = nil
+		s.regs[CRCResultRegL] = crc[0]
+		s.regs[CRCResultRegH] = crc[1]
+		s.regs[DivIrqReg] |= simCRCIRq
+	}
+}
+
+// selfTest fills the FIFO with the digital self-test result of the simulated chip version.
+// Versions without a reference produce zeros.
+func (s *Simulator) selfTest() {
+	result := make([]byte, selfTestLen)
+	copy(result, selfTestReference[s.version])
+	s.fifo = result
+}
+
+// antennaOn reports whether either antenna driver is enabled and the chip is powered up
+func (s *Simulator) antennaOn() bool {
+	return s.regs[TxControlReg]&0x03 != 0 && s.regs[CommandReg]&simPowerDown == 0
+}
+
+// fieldOff resets the cards, which lose power without the field
+func (s *Simulator) fieldOff() {
+	for _, card := range s.cards {
+		card.reset()
+	}
+	s.regs[Status2Reg] &^= simCrypto1On
+}
+
+// transceive sends the FIFO contents to the field and loads the answer into the FIFO
+func (s *Simulator) transceive() {
+	frame := s.fifo
+	txBits := int(s.regs[BitFramingReg] & 0x07)
+	s.fifo = nil
+	s.regs[ErrorReg] = 0
+
+	var answers [][]byte
+	rxBits := 0
+	if s.antennaOn() {
+		for _, card := range s.cards {
+			if a, bits := card.respond(frame, txBits); a != nil {
+				answers = append(answers, a)
+				rxBits = bits
+			}
+		}
+	}
+
+	if len(answers) == 0 || !s.heard() {
+		s.regs[ComIrqReg] |= simTxIRq | simTimerIRq
+		return
+	}
+
+	answer := s.superpose(frame, answers)
+
+	if s.corruptCRC && len(answer) > 2 && checkCRCA(answer) {
+		answer = append([]byte(nil), answer...)
+		answer[len(answer)-1] ^= 0x01
+	}
+
+	if s.injectError != 0 {
+		if s.injectSkip == 0 {
+			s.regs[ErrorReg] |= s.injectError
+			s.injectError = 0
+		} else {
+			s.injectSkip--
+		}
+	}
+
+	if len(answer) > simFIFOSize {
+		answer = answer[:simFIFOSize]
+		s.regs[ErrorReg] |= simBufferOvf
+	}
+
+	s.fifo = answer
+	s.regs[ControlReg] = (s.regs[ControlReg] &^ 0x07) | byte(rxBits)
+	s.regs[ComIrqReg] |= simTxIRq | simRxIRq | simIdleIRq
+}
+
+// heard reports whether card answers reach the receiver with its current gain and threshold
+func (s *Simulator) heard() bool {
+	if s.weakGain == 0 {
+		return true
+	}
+	return rxGainDB(s.regs[RFCfgReg]) >= s.weakGain && int(s.regs[RxThresholdReg]>>4) <= s.weakMinLevel
+}
+
+// superpose combines the answers of several cards as the MFRC522 receives them. At the
+// first bit where they differ it flags a collision; that bit and all later ones are
+// received as zeros. CollPos counts from the start of the UID of an anticollision frame.
+func (s *Simulator) superpose(frame []byte, answers [][]byte) []byte {
+	answer := answers[0]
+	first := len(answer) * 8
+	for _, other := range answers[1:] {
+		for i := 0; i < first && i < len(other)*8; i++ {
+			if (answer[i/8]>>(i%8))&1 != (other[i/8]>>(i%8))&1 {
+				first = i
+				break
+			}
+		}
+	}
+	if first == len(answer)*8 {
+		return answer
+	}
+
+	collided := make([]byte, len(answer))
+	copy(collided, answer[:first/8])
+	collided[first/8] = answer[first/8] & (1<<(first%8) - 1)
+
+	position := first + 1
+	if known, ok := anticollisionBits(frame); ok {
+		position += known / 8 * 8
+	}
+	s.regs[ErrorReg] |= simCollErr
+	s.regs[CollReg] = s.regs[CollReg]&0x80 | byte(position)&simCollPos
+	return collided
+}
+
+// authenticate runs MFAuthent with the key and UID loaded into the FIFO
+func (s *Simulator) authenticate() {
+	buf := s.fifo
+	s.fifo = nil
+	s.regs[ErrorReg] = 0
+	s.authentications++
+
+	const authLen = 12 // command, block, 6 key bytes, 4 UID bytes
+	if len(buf) < authLen || !s.antennaOn() {
+		s.regs[ComIrqReg] |= simTimerIRq
+		return
+	}
+
+	mode, block, key, uid := buf[0], int(buf[1]), buf[2:8], buf[8:12]
+	for _, card := range s.cards {
+		if card.state != piccActive {
+			continue
+		}
+		if !bytes.Equal(card.UID[len(card.UID)-4:], uid) {
+			continue
+		}
+		if card.authenticate(mode, block, key) {
+			// A damaged answer of the card aborts the handshake
+			if s.authError != 0 {
+				s.regs[ErrorReg] |= s.authError
+				s.authError = 0
+				s.regs[Status2Reg] &^= simCrypto1On
+				s.regs[ComIrqReg] |= simIdleIRq
+				return
+			}
+			s.regs[Status2Reg] |= simCrypto1On
+			s.regs[ComIrqReg] |= simIdleIRq
+			return
+		}
+	}
+
+	s.regs[Status2Reg] &^= simCrypto1On
+	s.regs[ComIrqReg] |= simTimerIRq
+}
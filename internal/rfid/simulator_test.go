@@ -0,0 +1,350 @@
+package rfid
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+func newSimulatedReader(t *testing.T, cards ...*VirtualCard) (*Reader, *Simulator) {
+	t.Helper()
+
+	sim := NewSimulator()
+	for _, card := range cards {
+		sim.AddCard(card)
+	}
+
+	reader, err := NewReaderWithTransport(sim, config.Default().RFID)
+	if err != nil {
+		t.Fatalf("NewReaderWithTransport() error = %v", err)
+	}
+	return reader, sim
+}
+
+func TestCRCA(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want [2]byte
+	}{
+		{[]byte{0x00, 0x00}, [2]byte{0xA0, 0x1E}},
+		{[]byte{0x30, 0x00}, [2]byte{0x02, 0xA8}},
+		{[]byte{0x50, 0x00}, [2]byte{0x57, 0xCD}},
+	}
+
+	for _, tt := range tests {
+		if got := crcA(tt.data); got != tt.want {
+			t.Errorf("crcA(%x) = %x, want %x", tt.data, got, tt.want)
+		}
+	}
+
+	if !checkCRCA(appendCRCA([]byte{0x93, 0x70})) {
+		t.Error("checkCRCA rejected a frame built by appendCRCA")
+	}
+}
+
+func TestHardwareCRC(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	reader, sim := newSimulatedReader(t, card)
+
+	data := []byte{0x93, 0x70, 0xDE, 0xAD, 0xBE, 0xEF, 0x22}
+	crc, ok := reader.calculateCRC(data)
+	if !ok {
+		t.Fatal("calculateCRC() did not complete")
+	}
+	if crc != crcA(data) {
+		t.Errorf("calculateCRC() = %x, want %x", crc, crcA(data))
+	}
+
+	// A stuck coprocessor switches the reader to the software CRC
+	sim.SetCRCCoprocessorStuck(true)
+	if _, ok := reader.calculateCRC(data); ok {
+		t.Error("calculateCRC() completed with a stuck coprocessor")
+	}
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if !reader.softwareCRC {
+		t.Error("reader did not fall back to the software CRC")
+	}
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Errorf("ReadBlock() with software CRC error = %v", err)
+	}
+
+	// Once the coprocessor responds again the next operation uses it
+	sim.SetCRCCoprocessorStuck(false)
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if reader.softwareCRC {
+		t.Error("reader kept the software CRC after the coprocessor recovered")
+	}
+}
+
+func TestCRCError(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	reader, sim := newSimulatedReader(t, card)
+
+	sim.SetCorruptCRC(true)
+	if _, err := reader.ScanForCard(); !errors.Is(err, ErrCRC) {
+		t.Errorf("ScanForCard() error = %v, want ErrCRC", err)
+	}
+
+	// Put the card back in the field, since the corrupted SELECT left it active
+	sim.SetCorruptCRC(false)
+	sim.RemoveCard(card)
+	sim.AddCard(card)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	sim.SetCorruptCRC(true)
+	if _, err := reader.ReadBlock(4); !errors.Is(err, ErrCRC) {
+		t.Errorf("ReadBlock() error = %v, want ErrCRC", err)
+	}
+}
+
+func TestIRQCompletion(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	reader, sim := newSimulatedReader(t, card)
+
+	reader.irqPin = sim.irqLine()
+	if err := reader.init(); err != nil {
+		t.Fatalf("init() error = %v", err)
+	}
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if sim.irqLine().Read() != gpio.Low {
+		t.Error("IRQ line not asserted after a completed command")
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+
+	for _, useIRQ := range []bool{false, true} {
+		reader, sim := newSimulatedReader(t)
+		if useIRQ {
+			reader.irqPin = sim.irqLine()
+		}
+		reader.SetCommandTimeout(timeout)
+
+		// Nothing is running, so IdleIRq is never raised
+		reader.writeRegister(ComIrqReg, 0x7F)
+		start := time.Now()
+		if _, ok := reader.waitForIRQ(simIdleIRq); ok {
+			t.Errorf("waitForIRQ() completed without an interrupt (IRQ pin %v)", useIRQ)
+		}
+		if elapsed := time.Since(start); elapsed < timeout {
+			t.Errorf("waitForIRQ() gave up after %v, want at least %v (IRQ pin %v)", elapsed, timeout, useIRQ)
+		}
+		if useIRQ && sim.irqLine().waits == 0 {
+			t.Error("waitForIRQ() did not wait on the IRQ pin")
+		}
+	}
+}
+
+func TestIRQMissedEdge(t *testing.T) {
+	reader, sim := newSimulatedReader(t)
+	reader.irqPin = sim.irqLine()
+	reader.SetCommandTimeout(2 * time.Second)
+
+	// With no interrupt enabled on the pin, IdleIRq is raised without an edge
+	reader.writeRegister(ComIEnReg, 0x80)
+	reader.writeRegister(ComIrqReg, 0x7F)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = sim.WriteRegister(ComIrqReg, simIrqSet|simIdleIRq)
+	}()
+
+	start := time.Now()
+	if _, ok := reader.waitForIRQ(simIdleIRq); !ok {
+		t.Fatal("waitForIRQ() missed IdleIRq")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("waitForIRQ() took %v to notice IdleIRq without an edge", elapsed)
+	}
+}
+
+func TestSimulatorNoCard(t *testing.T) {
+	reader, _ := newSimulatedReader(t)
+
+	if _, err := reader.ScanForCard(); err == nil {
+		t.Error("ScanForCard() succeeded with an empty field")
+	}
+}
+
+func TestSimulatorVersion(t *testing.T) {
+	sim := NewSimulator()
+	sim.version = 0x00
+
+	if _, err := NewReaderWithTransport(sim, config.Default().RFID); err == nil {
+		t.Error("NewReaderWithTransport() accepted a chip reporting version 0x00")
+	}
+}
+
+func TestSimulatorReadWriteBlock(t *testing.T) {
+	uid := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+	card := NewVirtualClassic1K(uid)
+	reader, _ := newSimulatedReader(t, card)
+
+	scanned, err := reader.ScanForCard()
+	if err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if !bytes.Equal(scanned.UID, uid) {
+		t.Errorf("ScanForCard() UID = %x, want %x", scanned.UID, uid)
+	}
+
+	data := []byte("Hello RFID world")
+	if err := reader.WriteBlock(4, data); err != nil {
+		t.Fatalf("WriteBlock() error = %v", err)
+	}
+	if !bytes.Equal(card.Block(4), data) {
+		t.Errorf("card block 4 = %x, want %x", card.Block(4), data)
+	}
+
+	got, err := reader.ReadBlock(4)
+	if err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ReadBlock() = %x, want %x", got, data)
+	}
+}
+
+func TestSimulatorWrongKey(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	wrongKey := []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+	if err := reader.SetCardKey(0, KeyA, wrongKey); err != nil {
+		t.Fatalf("SetCardKey() error = %v", err)
+	}
+	if err := reader.SetCardKey(0, KeyB, wrongKey); err != nil {
+		t.Fatalf("SetCardKey() error = %v", err)
+	}
+	if _, err := reader.ReadBlock(1); err == nil {
+		t.Error("ReadBlock() succeeded with the wrong key")
+	}
+}
+
+func TestSimulatorReadCard(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44})
+	card.SetBlock(1, bytes.Repeat([]byte{0x5A}, 16))
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	data, err := reader.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+	if len(data) != 64 {
+		t.Errorf("ReadCard() returned %d blocks, want 64", len(data))
+	}
+	if !bytes.Equal(data[1], card.Block(1)) {
+		t.Errorf("ReadCard() block 1 = %x, want %x", data[1], card.Block(1))
+	}
+
+	// Trailers are returned as the card transmits them, with Key A masked
+	want := append(make([]byte, 6), card.Block(3)[6:]...)
+	if !bytes.Equal(data[3], want) {
+		t.Errorf("ReadCard() trailer block 3 = %x, want %x", data[3], want)
+	}
+}
+
+func TestSimulatorCascadeLevels(t *testing.T) {
+	uids := [][]byte{
+		{0x01, 0x02, 0x03, 0x04},
+		{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66},
+		{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99},
+	}
+
+	for _, uid := range uids {
+		card := NewVirtualClassic1K(uid)
+		reader, _ := newSimulatedReader(t, card)
+
+		scanned, err := reader.ScanForCard()
+		if err != nil {
+			t.Fatalf("ScanForCard() with %d byte UID error = %v", len(uid), err)
+		}
+		if !bytes.Equal(scanned.UID, uid) {
+			t.Errorf("ScanForCard() UID = %x, want %x", scanned.UID, uid)
+		}
+		if scanned.SAK != 0x08 {
+			t.Errorf("ScanForCard() SAK = 0x%02x, want 0x08", scanned.SAK)
+		}
+
+		// Memory access only works once the card has been selected
+		if _, err := reader.ReadBlock(1); err != nil {
+			t.Errorf("ReadBlock() with %d byte UID error = %v", len(uid), err)
+		}
+	}
+}
+
+func TestSimulatorCardType(t *testing.T) {
+	tests := []struct {
+		card *VirtualCard
+		want CardType
+	}{
+		{NewVirtualClassic1K([]byte{0x01, 0x02, 0x03, 0x04}), CardTypeMifare1K},
+		{NewVirtualClassic4K([]byte{0x01, 0x02, 0x03, 0x04}), CardTypeMifare4K},
+		{NewVirtualClassic4K([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}), CardTypeMifare4K},
+	}
+
+	for _, tt := range tests {
+		reader, _ := newSimulatedReader(t, tt.card)
+
+		card, err := reader.ScanForCard()
+		if err != nil {
+			t.Fatalf("ScanForCard() error = %v", err)
+		}
+		if card.Type != tt.want {
+			t.Errorf("ScanForCard() type = %v, want %v (ATQA %04x, SAK %02x)", card.Type, tt.want, card.ATQA, card.SAK)
+		}
+	}
+}
+
+func TestSimulatorReadCard4K(t *testing.T) {
+	card := NewVirtualClassic4K([]byte{0x11, 0x22, 0x33, 0x44})
+	card.SetBlock(130, bytes.Repeat([]byte{0xA5}, 16))
+	reader, sim := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	data, err := reader.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+
+	if len(data) != 256 {
+		t.Errorf("ReadCard() returned %d blocks, want 256", len(data))
+	}
+	if _, ok := data[143]; !ok {
+		t.Error("ReadCard() skipped sector trailer block 143")
+	}
+	if !bytes.Equal(data[130], card.Block(130)) {
+		t.Errorf("ReadCard() block 130 = %x, want %x", data[130], card.Block(130))
+	}
+	if sim.authentications != 40 {
+		t.Errorf("ReadCard() authenticated %d times, want once per sector (40)", sim.authentications)
+	}
+}
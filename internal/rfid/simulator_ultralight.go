@@ -0,0 +1,260 @@
+package rfid
+
+import "bytes"
+
+// NewVirtualUltralight creates a blank MIFARE Ultralight card with a 7-byte UID
+func NewVirtualUltralight(uid []byte) *VirtualCard {
+	return newVirtualUltralight(uid, 16)
+}
+
+// NewVirtualUltralightC creates a blank MIFARE Ultralight C card with a 7-byte UID
+func NewVirtualUltralightC(uid []byte) *VirtualCard {
+	card := newVirtualUltralight(uid, ultralightCPages)
+	card.ultralightC = true
+	return card
+}
+
+// NewVirtualNTAG213 creates a blank NTAG213 with a 7-byte UID
+func NewVirtualNTAG213(uid []byte) *VirtualCard {
+	return newVirtualNTAG(uid, 45, 0x0F, 0x12)
+}
+
+// NewVirtualNTAG215 creates a blank NTAG215 with a 7-byte UID
+func NewVirtualNTAG215(uid []byte) *VirtualCard {
+	return newVirtualNTAG(uid, 135, 0x11, 0x3E)
+}
+
+// NewVirtualNTAG216 creates a blank NTAG216 with a 7-byte UID
+func NewVirtualNTAG216(uid []byte) *VirtualCard {
+	return newVirtualNTAG(uid, 231, 0x13, 0x6D)
+}
+
+// newVirtualUltralight builds an Ultralight-family card with the UID, BCC and lock pages filled in
+func newVirtualUltralight(uid []byte, pages int) *VirtualCard {
+	card := &VirtualCard{
+		UID:        append([]byte(nil), uid...),
+		ATQA:       [2]byte{0x44, 0x00},
+		SAK:        0x00,
+		memory:     make([]byte, pages*UltralightPageSize),
+		ultralight: true,
+	}
+	card.reset()
+
+	// Pages 0-2: UID0-2, BCC0, UID3-6, BCC1, internal, lock bytes
+	copy(card.memory[0:3], uid[0:3])
+	card.memory[3] = PICCCascadeTag ^ uid[0] ^ uid[1] ^ uid[2]
+	copy(card.memory[4:8], uid[3:7])
+	card.memory[8] = uid[3] ^ uid[4] ^ uid[5] ^ uid[6]
+	card.memory[9] = 0x48
+
+	return card
+}
+
+// newVirtualNTAG builds an NTAG21x with its capability container and factory configuration pages
+func newVirtualNTAG(uid []byte, pages int, storageSize, ccSize byte) *VirtualCard {
+	card := newVirtualUltralight(uid, pages)
+	card.version = []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, storageSize, 0x03}
+	card.signature = make([]byte, 32)
+	card.cfgPage = pages - 4
+
+	card.SetPage(3, []byte{0xE1, 0x10, ccSize, 0x00})
+	card.SetPage(card.cfgPage, []byte{0x04, 0x00, 0x00, 0xFF})   // CFG0: AUTH0 = 0xFF, no protection
+	card.SetPage(card.cfgPage+1, []byte{0x00, 0x05, 0x00, 0x00}) // CFG1: ACCESS
+	card.SetPage(card.cfgPage+2, []byte{0xFF, 0xFF, 0xFF, 0xFF}) // PWD
+	card.SetPage(card.cfgPage+3, []byte{0x00, 0x00, 0x00, 0x00}) // PACK
+
+	return card
+}
+
+// Page returns a copy of a 4-byte page of card memory
+func (c *VirtualCard) Page(page int) []byte {
+	return append([]byte(nil), c.memory[page*UltralightPageSize:(page+1)*UltralightPageSize]...)
+}
+
+// SetPage overwrites a 4-byte page of card memory, bypassing lock bits
+func (c *VirtualCard) SetPage(page int, data []byte) {
+	copy(c.memory[page*UltralightPageSize:(page+1)*UltralightPageSize], data)
+}
+
+// SetPasswordProtection configures NTAG password protection from page auth0 onwards.
+// With protectReads unset only writes require PWD_AUTH.
+func (c *VirtualCard) SetPasswordProtection(auth0 int, password, pack []byte, protectReads bool) {
+	c.memory[c.cfgPage*UltralightPageSize+3] = byte(auth0)
+	access := byte(0x00)
+	if protectReads {
+		access = 0x80
+	}
+	c.memory[(c.cfgPage+1)*UltralightPageSize] = access
+	c.SetPage(c.cfgPage+2, password)
+	c.SetPage(c.cfgPage+3, []byte{pack[0], pack[1], 0x00, 0x00})
+}
+
+// SetSignature sets the originality signature returned by READ_SIG
+func (c *VirtualCard) SetSignature(signature []byte) {
+	c.signature = append([]byte(nil), signature...)
+}
+
+// pages returns the number of 4-byte pages on the card
+func (c *VirtualCard) pages() int {
+	return len(c.memory) / UltralightPageSize
+}
+
+// respondUltralight answers the Ultralight, Ultralight C and NTAG21x command set
+func (c *VirtualCard) respondUltralight(frame []byte) ([]byte, int) {
+	if c.pendingWrite >= 0 {
+		page := c.pendingWrite
+		c.pendingWrite = -1
+		data := framePayload(frame, 16)
+		if data == nil || !c.writePage(page, data[:UltralightPageSize]) {
+			return c.nak()
+		}
+		return []byte{piccACK}, 4
+	}
+
+	if len(frame) == 0 {
+		return nil, 0
+	}
+
+	switch frame[0] {
+	case PICCHalt:
+		if framePayload(frame, 2) != nil {
+			c.reset()
+			c.state = piccHalt
+			return nil, 0
+		}
+	case PICCRead:
+		if cmd := framePayload(frame, 2); cmd != nil {
+			return c.readPages(int(cmd[1]))
+		}
+	case PICCULWrite:
+		if cmd := framePayload(frame, 2+UltralightPageSize); cmd != nil && c.writePage(int(cmd[1]), cmd[2:]) {
+			return []byte{piccACK}, 4
+		}
+	case PICCWrite:
+		if cmd := framePayload(frame, 2); cmd != nil && c.writable(int(cmd[1])) {
+			c.pendingWrite = int(cmd[1])
+			return []byte{piccACK}, 4
+		}
+	case PICCULCAuth:
+		if cmd := framePayload(frame, 2); cmd != nil && c.ultralightC {
+			// Answer with a fixed encrypted RndB; the 3DES exchange itself is not modelled
+			return appendCRCA([]byte{0xAF, 0x5A, 0x5A, 0x5A, 0x5A, 0x5A, 0x5A, 0x5A, 0x5A}), 0
+		}
+	default:
+		if c.version != nil {
+			if answer := c.respondNTAG(frame); answer != nil {
+				return answer, 0
+			}
+		}
+	}
+	return c.nak()
+}
+
+// respondNTAG answers the EV1/NTAG21x extended commands; nil means NAK
+func (c *VirtualCard) respondNTAG(frame []byte) []byte {
+	switch frame[0] {
+	case PICCGetVersion:
+		if framePayload(frame, 1) != nil {
+			return appendCRCA(c.version)
+		}
+	case PICCFastRead:
+		cmd := framePayload(frame, 3)
+		if cmd == nil {
+			return nil
+		}
+		start, end := int(cmd[1]), int(cmd[2])
+		if start > end || end >= c.pages() {
+			return nil
+		}
+		data := make([]byte, 0, (end-start+1)*UltralightPageSize)
+		for page := start; page <= end; page++ {
+			if !c.readable(page) {
+				return nil
+			}
+			data = append(data, c.pageAsRead(page)...)
+		}
+		return appendCRCA(data)
+	case PICCReadCnt:
+		if cmd := framePayload(frame, 2); cmd != nil && cmd[1] == NTAGNFCCounter {
+			return appendCRCA([]byte{byte(c.Counter), byte(c.Counter >> 8), byte(c.Counter >> 16)})
+		}
+	case PICCReadSig:
+		if framePayload(frame, 2) != nil {
+			return appendCRCA(c.signature)
+		}
+	case PICCPwdAuth:
+		cmd := framePayload(frame, 5)
+		if cmd != nil && bytes.Equal(cmd[1:5], c.Page(c.cfgPage+2)) {
+			c.pwdAuth = true
+			return appendCRCA(c.Page(c.cfgPage + 3)[:2])
+		}
+	}
+	return nil
+}
+
+// readPages returns four pages starting at page, rolling over at the end of memory
+func (c *VirtualCard) readPages(page int) ([]byte, int) {
+	if page >= c.pages() {
+		return c.nak()
+	}
+	data := make([]byte, 0, 16)
+	for i := 0; i < 4; i++ {
+		p := (page + i) % c.pages()
+		if !c.readable(p) {
+			return c.nak()
+		}
+		data = append(data, c.pageAsRead(p)...)
+	}
+	return appendCRCA(data), 0
+}
+
+// pageAsRead returns a page as the card transmits it; PWD and PACK always read as zeros
+func (c *VirtualCard) pageAsRead(page int) []byte {
+	if c.cfgPage > 0 && page >= c.cfgPage+2 {
+		return make([]byte, UltralightPageSize)
+	}
+	return c.Page(page)
+}
+
+// protected reports whether page lies in the NTAG password protected area and no PWD_AUTH happened
+func (c *VirtualCard) protected(page int) bool {
+	if c.cfgPage == 0 || c.pwdAuth {
+		return false
+	}
+	return page >= int(c.memory[c.cfgPage*UltralightPageSize+3])
+}
+
+// readable reports whether page may be read
+func (c *VirtualCard) readable(page int) bool {
+	readProtected := c.cfgPage > 0 && c.memory[(c.cfgPage+1)*UltralightPageSize]&0x80 != 0
+	return !readProtected || !c.protected(page)
+}
+
+// writable reports whether a page may be written, honouring static lock bits and NTAG passwords
+func (c *VirtualCard) writable(page int) bool {
+	if page < 2 || page >= c.pages() || c.protected(page) {
+		return false
+	}
+	lock := uint16(c.memory[10]) | uint16(c.memory[11])<<8
+	return page > 15 || page == 2 || lock&(1<<page) == 0
+}
+
+// writePage programs a page; lock bytes and OTP bits can only be set, never cleared
+func (c *VirtualCard) writePage(page int, data []byte) bool {
+	if !c.writable(page) {
+		return false
+	}
+	offset := page * UltralightPageSize
+	switch page {
+	case 2:
+		c.memory[offset+2] |= data[2]
+		c.memory[offset+3] |= data[3]
+	case 3:
+		for i := 0; i < UltralightPageSize; i++ {
+			c.memory[offset+i] |= data[i]
+		}
+	default:
+		copy(c.memory[offset:offset+UltralightPageSize], data)
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package rfid
+
+import (
+	"testing"
+)
+
+func TestTuneSPISpeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		maxHz  int
+		tested []int
+		speed  int
+	}{
+		{"limited link", 4000000, 32000000, []int{500000, 1000000, 2000000, 4000000, 8000000}, 2000000},
+		{"reliable link", 0, 32000000, []int{500000, 1000000, 2000000, 4000000, 8000000, 10000000}, 8000000},
+		{"uneven maximum", 0, 10000000, []int{500000, 1000000, 2000000, 4000000, 8000000, 10000000}, 8000000},
+		{"maximum is minimum", 0, 500000, []int{500000}, 500000},
+	}
+
+	for _, tt := range tests {
+		reader, sim := newSimulatedReader(t, NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}))
+		sim.SetSPILimit(tt.limit)
+
+		test, err := reader.TuneSPISpeed(500000, tt.maxHz)
+		if err != nil {
+			t.Fatalf("%s: TuneSPISpeed() error = %v", tt.name, err)
+		}
+		if len(test.Results) != len(tt.tested) {
+			t.Fatalf("%s: tested %v, want %v", tt.name, test.Results, tt.tested)
+		}
+		for i, res := range test.Results {
+			failed := tt.limit != 0 && res.Hz > tt.limit
+			if res.Hz != tt.tested[i] || (res.Errors > 0) != failed {
+				t.Errorf("%s: result %d = %+v, want %d Hz failing %v", tt.name, i, res, tt.tested[i], failed)
+			}
+		}
+		if test.Speed != tt.speed || sim.speed != tt.speed {
+			t.Errorf("%s: speed = %d, simulator at %d, want %d", tt.name, test.Speed, sim.speed, tt.speed)
+		}
+
+		// The chosen clock is reported by the diagnostics and works for card operations
+		if _, err := reader.ScanForCard(); err != nil {
+			t.Errorf("%s: ScanForCard() error = %v", tt.name, err)
+		}
+		d, _ := reader.Diagnostics()
+		if d == nil || d.SPISpeed != tt.speed || d.SPILink != test {
+			t.Errorf("%s: diagnostics do not report the link test", tt.name)
+		}
+		_ = reader.Close()
+	}
+}
+
+func TestTuneSPISpeedFailure(t *testing.T) {
+	reader, sim := newSimulatedReader(t)
+	sim.SetSPILimit(100000)
+
+	test, err := reader.TuneSPISpeed(500000, 32000000)
+	if err == nil {
+		t.Fatal("TuneSPISpeed() on a broken link succeeded, want error")
+	}
+	if test == nil || len(test.Results) != 1 || test.Speed != 500000 {
+		t.Errorf("TuneSPISpeed() = %+v, want one failed result at the minimum speed", test)
+	}
+
+	if _, err := reader.TuneSPISpeed(0, 1000000); err == nil {
+		t.Error("TuneSPISpeed() with a zero minimum succeeded, want error")
+	}
+	if _, err := reader.TuneSPISpeed(2000000, 1000000); err == nil {
+		t.Error("TuneSPISpeed() with an inverted range succeeded, want error")
+	}
+	if _, err := reader.TuneSPISpeed(16000000, 32000000); err == nil {
+		t.Error("TuneSPISpeed() above the MFRC522 maximum succeeded, want error")
+	}
+}
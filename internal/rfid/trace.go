@@ -0,0 +1,403 @@
+package rfid
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxTraceEntries bounds the trace; the oldest frames are dropped beyond it
+const maxTraceEntries = 10000
+
+// TraceSource tells which side of the air interface sent a traced frame
+type TraceSource int
+
+const (
+	// TraceReader marks a frame sent by the MFRC522
+	TraceReader TraceSource = iota
+	// TraceTag marks an answer from a card
+	TraceTag
+)
+
+// String returns the name of the source
+func (s TraceSource) String() string {
+	if s == TraceTag {
+		return "Tag"
+	}
+	return "Rdr"
+}
+
+// CRCStatus is the result of checking the CRC_A of a traced frame
+type CRCStatus int
+
+const (
+	// CRCNone marks frames that do not carry a CRC_A, such as REQA, ATQA and anticollision
+	CRCNone CRCStatus = iota
+	// CRCOK marks a frame ending in a valid CRC_A
+	CRCOK
+	// CRCBad marks a frame whose CRC_A does not match
+	CRCBad
+)
+
+// String returns the status as shown in Proxmark traces
+func (s CRCStatus) String() string {
+	switch s {
+	case CRCOK:
+		return "ok"
+	case CRCBad:
+		return "!crc"
+	default:
+		return ""
+	}
+}
+
+// TraceEntry is one frame exchanged with a card. Frames sent while Crypto1 is active are
+// recorded in plain text, as the MFRC522 encrypts them on the way to the antenna.
+type TraceEntry struct {
+	Time    time.Time
+	Source  TraceSource
+	Data    []byte
+	Bits    int       // Valid bits in Data
+	CRC     CRCStatus // Result of checking a CRC_A at the end of Data
+	Command string    // Decoded command or answer, empty if unknown
+	Err     string    // Why the exchange failed, on the last frame of a failed exchange
+}
+
+// Trace is a sequence of recorded frames
+type Trace []TraceEntry
+
+// traceRecorder collects frames while tracing is enabled. Frames are recorded on the
+// worker and may be read from any goroutine.
+type traceRecorder struct {
+	enabled atomic.Bool
+
+	// previous is the last frame sent, used only on the worker
+	previous []byte
+
+	mu      sync.Mutex
+	entries []TraceEntry
+}
+
+// add appends entries, dropping the oldest ones beyond maxTraceEntries
+func (t *traceRecorder) add(entries ...TraceEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries = append(t.entries, entries...)
+	if excess := len(t.entries) - maxTraceEntries; excess > 0 {
+		t.entries = append(t.entries[:0:0], t.entries[excess:]...)
+	}
+}
+
+// snapshot returns a copy of the recorded frames
+func (t *traceRecorder) snapshot() Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append(Trace(nil), t.entries...)
+}
+
+// clear drops all recorded frames
+func (t *traceRecorder) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = nil
+}
+
+// SetTracing starts or stops recording the frames exchanged with cards.
+// The trace can also be enabled from startup with the trace configuration setting.
+func (r *Reader) SetTracing(enabled bool) {
+	r.tracer.enabled.Store(enabled)
+}
+
+// Tracing reports whether frames are being recorded
+func (r *Reader) Tracing() bool {
+	return r.tracer.enabled.Load()
+}
+
+// Trace returns the recorded frames, oldest first
+func (r *Reader) Trace() Trace {
+	return r.tracer.snapshot()
+}
+
+// ClearTrace drops the recorded frames
+func (r *Reader) ClearTrace() {
+	r.tracer.clear()
+}
+
+// traceExchange records a frame sent with toCard2 and the card's answer.
+// For MFAuthent only the command and block are recorded, never the key.
+func (r *Reader) traceExchange(command byte, sent []byte, txBits int, start time.Time, answer []byte, err error) {
+	request := TraceEntry{Time: start, Source: TraceReader}
+	if command == PCDAuthent {
+		request.Data = appendCRCA(sent[:2])
+		request.Command = fmt.Sprintf("AUTH-%c(%d)", 'A'+sent[0]-PICCAuthent1A, sent[1])
+	} else {
+		request.Data = append([]byte(nil), sent...)
+		request.Command = describeCommand(sent, txBits, r.tracer.previous)
+	}
+	r.tracer.previous = request.Data
+	request.Bits = frameBits(len(request.Data), txBits)
+	request.CRC = crcStatus(request.Data, request.Bits, !isAnticollision(request.Data))
+
+	if len(answer) == 0 {
+		// The data frame of a value operation is not answered when it succeeds
+		if err != nil && !(request.Command == "VALUE" && errors.Is(err, ErrNoTag)) {
+			request.Err = err.Error()
+		}
+		r.tracer.add(request)
+		return
+	}
+
+	reply := TraceEntry{Time: time.Now(), Source: TraceTag, Data: append([]byte(nil), answer...)}
+	reply.Bits = frameBits(len(answer), int(r.readRegister(ControlReg)&0x07))
+	reply.CRC = crcStatus(reply.Data, reply.Bits, !isAnticollision(request.Data))
+	reply.Command = describeAnswer(request.Data, reply.Data, reply.Bits)
+	if err != nil {
+		reply.Err = err.Error()
+	}
+	r.tracer.add(request, reply)
+}
+
+// frameBits returns the number of bits of an n byte frame whose last byte holds lastBits
+// valid bits, 0 meaning all 8
+func frameBits(n, lastBits int) int {
+	if n == 0 || lastBits == 0 {
+		return n * 8
+	}
+	return (n-1)*8 + lastBits
+}
+
+// crcStatus checks the CRC_A at the end of a frame. Only whole-byte frames of at least
+// three bytes can carry one, and only if the protocol step uses it.
+func crcStatus(data []byte, bits int, expected bool) CRCStatus {
+	if !expected || bits%8 != 0 || len(data) < 3 {
+		return CRCNone
+	}
+	if checkCRCA(data) {
+		return CRCOK
+	}
+	return CRCBad
+}
+
+// isAnticollision reports whether a reader frame is an anticollision frame, which like
+// its answer carries no CRC_A
+func isAnticollision(frame []byte) bool {
+	if len(frame) < 2 {
+		return false
+	}
+	switch frame[0] {
+	case PICCAntiColl, PICCSelectCL2, PICCSelectCL3:
+		return frame[1] != 0x70
+	}
+	return false
+}
+
+// cascadeLevel returns the cascade level named by an anticollision or SELECT command
+func cascadeLevel(command byte) int {
+	return int(command-PICCAntiColl)/2 + 1
+}
+
+// describeCommand names a frame sent by the reader after the frame previous
+func describeCommand(frame []byte, txBits int, previous []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+
+	// WRITE and the value operations send their data in a second frame
+	if len(previous) > 0 {
+		switch previous[0] {
+		case PICCWrite:
+			if len(frame) == 18 {
+				return "DATA"
+			}
+		case PICCDecrement, PICCIncrement, PICCRestore:
+			if len(frame) == 6 {
+				return "VALUE"
+			}
+		}
+	}
+
+	if txBits == 7 && len(frame) == 1 {
+		switch frame[0] {
+		case PICCReqIDL:
+			return "REQA"
+		case PICCReqAll:
+			return "WUPA"
+		case 0x40:
+			return "MAGIC WUPC1"
+		}
+		return ""
+	}
+
+	arg := -1
+	if len(frame) > 1 {
+		arg = int(frame[1])
+	}
+
+	switch frame[0] {
+	case PICCAntiColl, PICCSelectCL2, PICCSelectCL3:
+		if isAnticollision(frame) {
+			return fmt.Sprintf("ANTICOLL-%d", cascadeLevel(frame[0]))
+		}
+		return fmt.Sprintf("SELECT_UID-%d", cascadeLevel(frame[0]))
+	case PICCHalt:
+		return "HALT"
+	case PICCAuthent1A, PICCAuthent1B:
+		// GET_VERSION shares its code with AUTH-A but has no argument
+		if frame[0] == PICCGetVersion && len(frame) == 3 {
+			return "GET_VERSION"
+		}
+		return fmt.Sprintf("AUTH-%c(%d)", 'A'+frame[0]-PICCAuthent1A, arg)
+	case PICCRead:
+		return fmt.Sprintf("READBLOCK(%d)", arg)
+	case PICCWrite:
+		return fmt.Sprintf("WRITEBLOCK(%d)", arg)
+	case PICCULWrite:
+		return fmt.Sprintf("WRITE(%d)", arg)
+	case PICCDecrement:
+		return fmt.Sprintf("DECREMENT(%d)", arg)
+	case PICCIncrement:
+		return fmt.Sprintf("INCREMENT(%d)", arg)
+	case PICCRestore:
+		return fmt.Sprintf("RESTORE(%d)", arg)
+	case PICCTransfer:
+		return fmt.Sprintf("TRANSFER(%d)", arg)
+	case PICCFastRead:
+		if len(frame) > 2 {
+			return fmt.Sprintf("FAST_READ(%d-%d)", frame[1], frame[2])
+		}
+		return "FAST_READ"
+	case PICCReadCnt:
+		return fmt.Sprintf("READ_CNT(%d)", arg)
+	case PICCReadSig:
+		return "READ_SIG"
+	case PICCPwdAuth:
+		return "PWD_AUTH"
+	case PICCULCAuth:
+		return "AUTH-UL-C"
+	case 0x43:
+		return "MAGIC WUPC2"
+	}
+	return ""
+}
+
+// describeAnswer names a card's answer to request
+func describeAnswer(request, answer []byte, bits int) string {
+	if bits == 4 {
+		if answer[0]&0x0F == piccACK {
+			return "ACK"
+		}
+		return fmt.Sprintf("NAK(%X)", answer[0]&0x0F)
+	}
+
+	if len(request) == 1 && (request[0] == PICCReqIDL || request[0] == PICCReqAll) {
+		return "ATQA"
+	}
+
+	switch request[0] {
+	case PICCAntiColl, PICCSelectCL2, PICCSelectCL3:
+		if isAnticollision(request) {
+			return "UID"
+		}
+		return "SAK"
+	case PICCRead, PICCFastRead:
+		return "DATA"
+	case PICCGetVersion:
+		if len(request) == 3 {
+			return "VERSION"
+		}
+	case PICCReadCnt:
+		return "COUNTER"
+	case PICCReadSig:
+		return "SIGNATURE"
+	case PICCPwdAuth:
+		return "PACK"
+	}
+	return ""
+}
+
+// formatFrame returns the bytes of a frame in hex, with the bit count of a partial last byte
+func formatFrame(data []byte, bits int) string {
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	if bits%8 != 0 && len(data) > 0 {
+		parts[len(parts)-1] += fmt.Sprintf("(%d)", bits%8)
+	}
+	return strings.Join(parts, " ")
+}
+
+// WriteText writes the trace as an annotated table in the layout of the Proxmark
+// "trace list" command, with start times in microseconds from the first frame
+func (t Trace) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%12s | %-3s | %-53s | %-4s | %s\n", "Start (us)", "Src", "Data", "CRC", "Annotation")
+	fmt.Fprintln(bw, strings.Repeat("-", 12)+"-|-----|-"+strings.Repeat("-", 53)+"-|------|-"+strings.Repeat("-", 20))
+
+	for _, e := range t {
+		annotation := e.Command
+		if e.Err != "" {
+			annotation = strings.TrimSpace(annotation + " (" + e.Err + ")")
+		}
+		fmt.Fprintf(bw, "%12d | %-3s | %-53s | %-4s | %s\n",
+			e.Time.Sub(t[0].Time).Microseconds(), e.Source, formatFrame(e.Data, e.Bits), e.CRC, annotation)
+	}
+
+	return bw.Flush()
+}
+
+// WriteProxmark writes the trace in the binary format of Proxmark3 trace files, which
+// "trace load" reads and "trace list -1 -t 14a" decodes. Each frame is a header of a
+// 32-bit start time and a 16-bit duration, both in carrier cycles of 13.56 MHz, and the
+// frame length with the answer flag in its top bit, followed by the frame and its parity
+// bits. The MFRC522 does not report parity, so the odd parity of each byte is written.
+func (t Trace) WriteProxmark(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, e := range t {
+		if len(e.Data) == 0 {
+			continue
+		}
+
+		// 13.56 carrier cycles per microsecond; the time wraps after about 5 minutes
+		start := uint32(e.Time.Sub(t[0].Time).Nanoseconds() * 339 / 25000)
+
+		// Each bit on air lasts 128 cycles; a byte is followed by its parity bit
+		onAir := e.Bits + (e.Bits+7)/8 + 2
+		duration := uint16(min(onAir*128, 0xFFFF))
+
+		length := uint16(len(e.Data))
+		if e.Source == TraceTag {
+			length |= 0x8000
+		}
+
+		header := make([]byte, 8)
+		binary.LittleEndian.PutUint32(header[0:], start)
+		binary.LittleEndian.PutUint16(header[4:], duration)
+		binary.LittleEndian.PutUint16(header[6:], length)
+		_, _ = bw.Write(header)
+		_, _ = bw.Write(e.Data)
+		_, _ = bw.Write(oddParity(e.Data))
+	}
+
+	return bw.Flush()
+}
+
+// oddParity returns the parity bits of data as Proxmark stores them, most significant bit first
+func oddParity(data []byte) []byte {
+	parity := make([]byte, (len(data)-1)/8+1)
+	for i, b := range data {
+		if bits.OnesCount8(b)%2 == 0 {
+			parity[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return parity
+}
@@ -0,0 +1,181 @@
+package rfid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// traceCommands returns the annotations of a trace
+func traceCommands(trace Trace) []string {
+	commands := make([]string, len(trace))
+	for i, e := range trace {
+		commands[i] = e.Command
+	}
+	return commands
+}
+
+// containsInOrder reports whether want appears in got as a subsequence
+func containsInOrder(got, want []string) bool {
+	i := 0
+	for _, s := range got {
+		if i < len(want) && s == want[i] {
+			i++
+		}
+	}
+	return i == len(want)
+}
+
+func TestTrace(t *testing.T) {
+	card := NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	reader, _ := newSimulatedReader(t, card)
+
+	reader.SetTracing(true)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if err := reader.WriteBlock(5, []byte("0123456789abcdef")); err != nil {
+		t.Fatalf("WriteBlock() error = %v", err)
+	}
+	reader.SetTracing(false)
+
+	trace := reader.Trace()
+	if _, err := reader.ReadBlock(4); err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if len(reader.Trace()) != len(trace) {
+		t.Error("frames recorded with tracing disabled")
+	}
+
+	want := []string{
+		"REQA", "ATQA", "ANTICOLL-1", "UID", "SELECT_UID-1", "SAK",
+		"AUTH-A(7)", "READBLOCK(4)", "DATA",
+		"WRITEBLOCK(5)", "ACK", "DATA", "ACK",
+	}
+	if got := traceCommands(trace); !containsInOrder(got, want) {
+		t.Errorf("trace commands = %v, want %v in order", got, want)
+	}
+
+	for _, e := range trace {
+		switch e.Command {
+		case "REQA":
+			if e.Bits != 7 || e.Source != TraceReader || e.CRC != CRCNone {
+				t.Errorf("REQA = %d bits from %v, CRC %v", e.Bits, e.Source, e.CRC)
+			}
+		case "UID":
+			if e.Source != TraceTag || e.CRC != CRCNone {
+				t.Errorf("UID from %v, CRC %v", e.Source, e.CRC)
+			}
+		case "READBLOCK(4)", "SAK":
+			if e.CRC != CRCOK {
+				t.Errorf("%s CRC = %v, want ok", e.Command, e.CRC)
+			}
+		case "ACK":
+			if e.Bits != 4 {
+				t.Errorf("ACK = %d bits, want 4", e.Bits)
+			}
+		}
+
+		// Authentication is traced as sent on air, without the key
+		if strings.HasPrefix(e.Command, "AUTH") && (len(e.Data) != 4 || e.CRC != CRCOK) {
+			t.Errorf("%s frame = %x, CRC %v", e.Command, e.Data, e.CRC)
+		}
+	}
+
+	reader.ClearTrace()
+	if len(reader.Trace()) != 0 {
+		t.Error("ClearTrace() kept frames")
+	}
+}
+
+func TestTraceFailedExchange(t *testing.T) {
+	reader, _ := newSimulatedReader(t)
+	reader.SetTracing(true)
+
+	if _, err := reader.ScanForCard(); err == nil {
+		t.Fatal("ScanForCard() without a card succeeded")
+	}
+	trace := reader.Trace()
+	if len(trace) == 0 || trace[0].Command != "REQA" || trace[0].Err == "" {
+		t.Errorf("trace = %+v, want an unanswered REQA", trace)
+	}
+}
+
+func TestTraceWriteText(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF}))
+	reader.SetTracing(true)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := reader.Trace().WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	for _, want := range []string{"| Rdr | 26(7)", "| Tag | DE AD BE EF 22", "| ok   | SAK"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteText() output lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestTraceWriteProxmark(t *testing.T) {
+	reader, _ := newSimulatedReader(t, NewVirtualClassic1K([]byte{0xDE, 0xAD, 0xBE, 0xEF}))
+	reader.SetTracing(true)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	trace := reader.Trace()
+	var buf bytes.Buffer
+	if err := trace.WriteProxmark(&buf); err != nil {
+		t.Fatalf("WriteProxmark() error = %v", err)
+	}
+
+	// Walk the records back and compare them with the trace
+	data := buf.Bytes()
+	var last uint32
+	for i, e := range trace {
+		if len(data) < 8 {
+			t.Fatalf("record %d: file ends early", i)
+		}
+		timestamp := binary.LittleEndian.Uint32(data[0:])
+		length := binary.LittleEndian.Uint16(data[6:])
+		if timestamp < last {
+			t.Errorf("record %d: timestamp %d before %d", i, timestamp, last)
+		}
+		last = timestamp
+
+		if isResponse := length&0x8000 != 0; isResponse != (e.Source == TraceTag) {
+			t.Errorf("record %d: response flag = %v, want %v", i, isResponse, e.Source == TraceTag)
+		}
+		n := int(length & 0x7FFF)
+		if !bytes.Equal(data[8:8+n], e.Data) {
+			t.Errorf("record %d: frame = %x, want %x", i, data[8:8+n], e.Data)
+		}
+		data = data[8+n+(n-1)/8+1:]
+	}
+	if len(data) != 0 {
+		t.Errorf("%d bytes after the last record", len(data))
+	}
+}
+
+func TestOddParity(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want []byte
+	}{
+		{[]byte{0x26}, []byte{0x00}},
+		{[]byte{0x04, 0x00}, []byte{0x40}},
+		{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, []byte{0xFF, 0x00}},
+	}
+	for _, tt := range tests {
+		if got := oddParity(tt.data); !bytes.Equal(got, tt.want) {
+			t.Errorf("oddParity(%x) = %x, want %x", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package rfid
+
+import (
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/spi"
+)
+
+// Transport provides register-level access to an MFRC522.
+// The hardware implementation talks SPI; Simulator provides an in-memory model.
+type Transport interface {
+	// ReadRegister returns the current value of an MFRC522 register
+	ReadRegister(reg byte) (byte, error)
+	// WriteRegister stores a value into an MFRC522 register
+	WriteRegister(reg, value byte) error
+}
+
+// ClockedTransport is a Transport whose clock rate can be changed, so that the link
+// can be tested and run at the fastest reliable speed
+type ClockedTransport interface {
+	Transport
+	// SetSpeed sets the clock rate in Hz
+	SetSpeed(hz int) error
+}
+
+// IRQLine is the MFRC522 IRQ output as seen by the reader.
+// Every gpio.PinIO implements it; Simulator provides a model driven by its interrupt registers.
+type IRQLine interface {
+	// In configures the line as an input with edge detection
+	In(pull gpio.Pull, edge gpio.Edge) error
+	// Read returns the current level of the line
+	Read() gpio.Level
+	// WaitForEdge blocks until the configured edge occurs or the timeout expires
+	WaitForEdge(timeout time.Duration) bool
+}
+
+// spiTransport accesses the MFRC522 register file over SPI
+type spiTransport struct {
+	port spi.PortCloser
+	conn spi.Conn
+}
+
+// ReadRegister reads a single register over SPI
+func (t *spiTransport) ReadRegister(reg byte) (byte, error) {
+	write := []byte{(reg << 1) | 0x80, 0x00}
+	read := make([]byte, 2)
+	if err := t.conn.Tx(write, read); err != nil {
+		return 0, err
+	}
+	return read[1], nil
+}
+
+// WriteRegister writes a single register over SPI
+func (t *spiTransport) WriteRegister(reg, value byte) error {
+	write := []byte{reg << 1, value}
+	return t.conn.Tx(write, nil)
+}
+
+// SetSpeed limits the SPI clock; the connection is opened at the highest clock
+func (t *spiTransport) SetSpeed(hz int) error {
+	return t.port.LimitSpeed(physic.Frequency(hz) * physic.Hertz)
+}
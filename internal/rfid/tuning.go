@@ -0,0 +1,120 @@
+package rfid
+
+import (
+	"fmt"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+// rxGainBits maps the receiver gains in dB to the RxGain field of RFCfgReg
+var rxGainBits = map[int]byte{
+	18: 0x00,
+	23: 0x01,
+	33: 0x04,
+	38: 0x05,
+	43: 0x06,
+	48: 0x07,
+}
+
+// rxGainDB returns the receiver gain in dB selected by the RxGain field of RFCfgReg;
+// 010 and 011 repeat 18 and 23 dB
+func rxGainDB(rfCfg byte) int {
+	return [8]int{18, 23, 18, 23, 33, 38, 43, 48}[rfCfg>>4&0x07]
+}
+
+// Tuning holds the antenna and receiver settings. Higher gain and lower thresholds
+// reach cards further away, at the cost of picking up more noise.
+type Tuning struct {
+	GainDB      int // Receiver gain: 18, 23, 33, 38, 43 or 48 dB (RFCfgReg RxGain)
+	RxMinLevel  int // Weakest signal the decoder accepts, 0 to 15 (RxThresholdReg MinLevel)
+	RxCollLevel int // Weakest half-bit still counted in a collision, 0 to 7 (RxThresholdReg CollLevel)
+	CWGsN       int // Conductance of the n-driver outside modulation, 0 to 15 (GsNReg CWGsN)
+	CWGsP       int // Conductance of the p-driver outside modulation, 0 to 63 (CWGsPReg)
+}
+
+// DefaultTuning returns the settings the MFRC522 has after a reset
+func DefaultTuning() Tuning {
+	return Tuning{GainDB: 33, RxMinLevel: 8, RxCollLevel: 4, CWGsN: 8, CWGsP: 32}
+}
+
+// Validate checks that every setting fits its register field
+func (t Tuning) Validate() error {
+	if _, ok := rxGainBits[t.GainDB]; !ok {
+		return fmt.Errorf("gain must be 18, 23, 33, 38, 43 or 48 dB, not %d", t.GainDB)
+	}
+	if t.RxMinLevel < 0 || t.RxMinLevel > 15 {
+		return fmt.Errorf("receiver minimum level must be between 0 and 15")
+	}
+	if t.RxCollLevel < 0 || t.RxCollLevel > 7 {
+		return fmt.Errorf("receiver collision level must be between 0 and 7")
+	}
+	if t.CWGsN < 0 || t.CWGsN > 15 {
+		return fmt.Errorf("n-driver conductance must be between 0 and 15")
+	}
+	if t.CWGsP < 0 || t.CWGsP > 63 {
+		return fmt.Errorf("p-driver conductance must be between 0 and 63")
+	}
+	return nil
+}
+
+// tuningFromConfig returns the configured settings; missing ones keep the reset values
+func tuningFromConfig(cfg config.RFIDConfig) Tuning {
+	t := DefaultTuning()
+	if cfg.AntennaGainDB != nil {
+		t.GainDB = *cfg.AntennaGainDB
+	}
+	if cfg.RxMinLevel != nil {
+		t.RxMinLevel = *cfg.RxMinLevel
+	}
+	if cfg.RxCollLevel != nil {
+		t.RxCollLevel = *cfg.RxCollLevel
+	}
+	if cfg.CWGsN != nil {
+		t.CWGsN = *cfg.CWGsN
+	}
+	if cfg.CWGsP != nil {
+		t.CWGsP = *cfg.CWGsP
+	}
+	return t
+}
+
+// Apply stores the tuning in the configuration, so that Config.Save persists it
+func (t Tuning) Apply(cfg *config.RFIDConfig) {
+	cfg.AntennaGainDB = &t.GainDB
+	cfg.RxMinLevel = &t.RxMinLevel
+	cfg.RxCollLevel = &t.RxCollLevel
+	cfg.CWGsN = &t.CWGsN
+	cfg.CWGsP = &t.CWGsP
+}
+
+// SetTuning changes the antenna and receiver settings. They are kept when the reader
+// is initialized again.
+func (r *Reader) SetTuning(t Tuning) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	return r.run(func() error {
+		r.tuning = t
+		r.applyTuning()
+		return nil
+	})
+}
+
+// Tuning returns the antenna and receiver settings in use
+func (r *Reader) Tuning() Tuning {
+	t, _ := call(r, func() (Tuning, error) {
+		return r.tuning, nil
+	})
+	return t
+}
+
+// applyTuning writes the tuning to the registers, keeping their other fields
+func (r *Reader) applyTuning() {
+	rfCfg := r.readRegister(RFCfgReg)&^0x70 | rxGainBits[r.tuning.GainDB]<<4
+	r.writeRegister(RFCfgReg, rfCfg)
+	r.writeRegister(RxThresholdReg, byte(r.tuning.RxMinLevel<<4|r.tuning.RxCollLevel))
+
+	gsN := r.readRegister(GsNReg)&0x0F | byte(r.tuning.CWGsN<<4)
+	r.writeRegister(GsNReg, gsN)
+	r.writeRegister(CWGsPReg, byte(r.tuning.CWGsP))
+}
@@ -0,0 +1,108 @@
+package rfid
+
+import (
+	"path/filepath"
+	"testing"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+func TestTuningRegisters(t *testing.T) {
+	sim := NewSimulator()
+	cfg := config.Default().RFID
+	gain := 48
+	cfg.AntennaGainDB = &gain
+	reader, err := NewReaderWithTransport(sim, cfg)
+	if err != nil {
+		t.Fatalf("NewReaderWithTransport() error = %v", err)
+	}
+	defer reader.Close()
+
+	// Reserved bits of RFCfgReg and ModGsN in GsNReg keep their reset values
+	checkRegisters := func(step string, want map[byte]byte) {
+		t.Helper()
+		for reg, value := range want {
+			if got, _ := sim.ReadRegister(reg); got != value {
+				t.Errorf("%s: register 0x%02x = 0x%02x, want 0x%02x", step, reg, got, value)
+			}
+		}
+	}
+	checkRegisters("config", map[byte]byte{RFCfgReg: 0x78, RxThresholdReg: 0x84, GsNReg: 0x88, CWGsPReg: 0x20})
+
+	tuning := Tuning{GainDB: 23, RxMinLevel: 5, RxCollLevel: 2, CWGsN: 15, CWGsP: 63}
+	if err := reader.SetTuning(tuning); err != nil {
+		t.Fatalf("SetTuning() error = %v", err)
+	}
+	want := map[byte]byte{RFCfgReg: 0x18, RxThresholdReg: 0x52, GsNReg: 0xF8, CWGsPReg: 0x3F}
+	checkRegisters("SetTuning", want)
+	if got := reader.Tuning(); got != tuning {
+		t.Errorf("Tuning() = %+v, want %+v", got, tuning)
+	}
+
+	// The self-test resets the chip; the tuning is applied again afterwards
+	if _, err := reader.Diagnostics(); err != nil {
+		t.Fatalf("Diagnostics() error = %v", err)
+	}
+	checkRegisters("after reset", want)
+}
+
+func TestTuningValidate(t *testing.T) {
+	if err := DefaultTuning().Validate(); err != nil {
+		t.Errorf("DefaultTuning().Validate() error = %v", err)
+	}
+
+	invalid := []Tuning{
+		{GainDB: 28, RxMinLevel: 8, RxCollLevel: 4, CWGsN: 8, CWGsP: 32},
+		{GainDB: 33, RxMinLevel: 16, RxCollLevel: 4, CWGsN: 8, CWGsP: 32},
+		{GainDB: 33, RxMinLevel: 8, RxCollLevel: 8, CWGsN: 8, CWGsP: 32},
+		{GainDB: 33, RxMinLevel: 8, RxCollLevel: 4, CWGsN: -1, CWGsP: 32},
+		{GainDB: 33, RxMinLevel: 8, RxCollLevel: 4, CWGsN: 8, CWGsP: 64},
+	}
+	reader, _ := newSimulatedReader(t)
+	for _, tuning := range invalid {
+		if err := reader.SetTuning(tuning); err == nil {
+			t.Errorf("SetTuning(%+v) succeeded, want error", tuning)
+		}
+	}
+	if got := reader.Tuning(); got != DefaultTuning() {
+		t.Errorf("Tuning() = %+v after rejected changes, want the defaults", got)
+	}
+
+	gain := 30
+	cfg := config.RFIDConfig{AntennaGainDB: &gain}
+	if _, err := NewReaderWithTransport(NewSimulator(), cfg); err == nil {
+		t.Error("NewReaderWithTransport() with a 30 dB gain succeeded, want error")
+	}
+}
+
+func TestTuningConfigRoundTrip(t *testing.T) {
+	// Zero is a valid setting for every field but the gain
+	tuning := Tuning{GainDB: 18, RxMinLevel: 0, RxCollLevel: 0, CWGsN: 0, CWGsP: 0}
+	cfg := config.Default()
+	tuning.Apply(&cfg.RFID)
+
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := cfg.Save(file); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := config.Load(file)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := tuningFromConfig(loaded.RFID); got != tuning {
+		t.Errorf("tuning after reload = %+v, want %+v", got, tuning)
+	}
+
+	if got := tuningFromConfig(config.Default().RFID); got != DefaultTuning() {
+		t.Errorf("tuning without settings = %+v, want the defaults", got)
+	}
+
+	level := 16
+	cfg.RFID.RxMinLevel = &level
+	if err := cfg.Save(file); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := config.Load(file); err == nil {
+		t.Error("Load() with rx_min_level 16 succeeded, want error")
+	}
+}
@@ -0,0 +1,249 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+)
+
+// PICCULWrite is the Ultralight/NTAG single page WRITE command
+const PICCULWrite = 0xA2
+
+// UltralightPageSize is the size of an Ultralight/NTAG memory page in bytes
+const UltralightPageSize = 4
+
+// ReadPages reads four consecutive pages (16 bytes) starting at page from an Ultralight or NTAG card.
+// Reads past the end of memory roll over to page 0, as implemented by the card.
+func (r *Reader) ReadPages(page int) ([]byte, error) {
+	return r.ReadPagesContext(context.Background(), page)
+}
+
+// ReadPagesContext is ReadPages bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) ReadPagesContext(ctx context.Context, page int) ([]byte, error) {
+	return callContext(ctx, r, func() ([]byte, error) {
+		return r.readPages(page)
+	})
+}
+
+// readPages implements ReadPages
+func (r *Reader) readPages(page int) ([]byte, error) {
+	if err := r.checkUltralightPage(page); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err := r.retry("read", func() error {
+		var err error
+		data, err = r.read(page)
+		return err
+	}, r.reactivate)
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %w", err)
+	}
+
+	return data, nil
+}
+
+// WritePage writes one 4-byte page of an Ultralight or NTAG card. It refuses the UID,
+// lock, OTP and configuration pages reported by Card.IsConfigPage; use WriteConfigPage
+// to write those.
+func (r *Reader) WritePage(page int, data []byte) error {
+	return r.WritePageContext(context.Background(), page, data)
+}
+
+// WritePageContext is WritePage bounded by ctx. Without a deadline on ctx the
+// operation timeout applies. A write already sent to the card is not interrupted.
+func (r *Reader) WritePageContext(ctx context.Context, page int, data []byte) error {
+	_, err := callContext(ctx, r, func() (struct{}, error) {
+		return struct{}{}, r.writePage(page, data, false)
+	})
+	return err
+}
+
+// WriteConfigPage writes one page like WritePage, including the UID, lock, OTP and
+// configuration pages. Lock and OTP bits can never be cleared again.
+func (r *Reader) WriteConfigPage(page int, data []byte) error {
+	return r.WriteConfigPageContext(context.Background(), page, data)
+}
+
+// WriteConfigPageContext is WriteConfigPage bounded by ctx. Without a deadline on ctx
+// the operation timeout applies. A write already sent to the card is not interrupted.
+func (r *Reader) WriteConfigPageContext(ctx context.Context, page int, data []byte) error {
+	_, err := callContext(ctx, r, func() (struct{}, error) {
+		return struct{}{}, r.writePage(page, data, true)
+	})
+	return err
+}
+
+// writePage implements WritePage and, with config set, WriteConfigPage
+func (r *Reader) writePage(page int, data []byte, config bool) error {
+	if err := r.checkUltralightWrite(page, config); err != nil {
+		return err
+	}
+
+	if len(data) != UltralightPageSize {
+		return fmt.Errorf("data must be exactly %d bytes", UltralightPageSize)
+	}
+
+	// The page may have been written even if the ACK was lost, so it is read back before
+	// writing it again
+	buff := []byte{PICCULWrite, byte(page)}
+	buff = append(buff, data...)
+	sent := false
+	err := r.retry("write", func() error {
+		if sent {
+			current, err := r.read(page)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(current[:UltralightPageSize], data) {
+				return nil
+			}
+		}
+
+		sent = true
+		return r.ackCommand(buff)
+	}, r.reactivate)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	return nil
+}
+
+// CompatibilityWritePage writes one page using the 16-byte MIFARE Classic WRITE frame.
+// Only the first four bytes are stored; the remaining twelve are sent as zeros. Like
+// WritePage it refuses the UID, lock, OTP and configuration pages.
+func (r *Reader) CompatibilityWritePage(page int, data []byte) error {
+	return r.CompatibilityWritePageContext(context.Background(), page, data)
+}
+
+// CompatibilityWritePageContext is CompatibilityWritePage bounded by ctx. Without a deadline
+// on ctx the operation timeout applies. A write already sent to the card is not interrupted.
+func (r *Reader) CompatibilityWritePageContext(ctx context.Context, page int, data []byte) error {
+	_, err := callContext(ctx, r, func() (struct{}, error) {
+		return struct{}{}, r.compatibilityWritePage(page, data)
+	})
+	return err
+}
+
+// compatibilityWritePage implements CompatibilityWritePage
+func (r *Reader) compatibilityWritePage(page int, data []byte) error {
+	if err := r.checkUltralightWrite(page, false); err != nil {
+		return err
+	}
+
+	if len(data) != UltralightPageSize {
+		return fmt.Errorf("data must be exactly %d bytes", UltralightPageSize)
+	}
+
+	// As with WritePage, a page that may have been written is read back before a retry
+	frame := make([]byte, 16)
+	copy(frame, data)
+	sent := false
+	err := r.retry("write", func() error {
+		if sent {
+			current, err := r.read(page)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(current[:UltralightPageSize], data) {
+				return nil
+			}
+		}
+
+		if err := r.ackCommand([]byte{PICCWrite, byte(page)}); err != nil {
+			return err
+		}
+		sent = true
+		return r.ackCommand(frame)
+	}, r.reactivate)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	return nil
+}
+
+// readUltralight reads every page of the last card.
+// Cards that answered GET_VERSION are dumped with FAST_READ, others four pages per READ.
+func (r *Reader) readUltralight() (map[int][]byte, error) {
+	card := r.lastCard
+	if card.Version != nil {
+		if data, ok := r.readUltralightFast(); ok {
+			return data, nil
+		}
+	}
+
+	data := make(map[int][]byte)
+
+	for page := 0; page < card.Blocks; page += 4 {
+		if err := r.canceled(); err != nil {
+			return nil, err
+		}
+
+		pages, err := r.read(page)
+		if err != nil {
+			log.Printf("Warning: Failed to read pages %d-%d: %v", page, page+3, err)
+			// The card NAKs protected pages and drops to IDLE
+			_ = r.reactivate()
+			continue
+		}
+
+		for i := 0; i < 4 && page+i < card.Blocks; i++ {
+			data[page+i] = pages[i*UltralightPageSize : (i+1)*UltralightPageSize]
+		}
+	}
+
+	return data, nil
+}
+
+// checkUltralightPage validates that the last card uses pages and that page is on it
+func (r *Reader) checkUltralightPage(page int) error {
+	if r.lastCard == nil {
+		return fmt.Errorf("no card selected")
+	}
+
+	if !r.lastCard.IsUltralight() {
+		return fmt.Errorf("%s cards do not use page access", r.lastCard.Type)
+	}
+
+	if page < 0 || page >= r.lastCard.Blocks {
+		return fmt.Errorf("page %d is out of range for %s", page, r.lastCard.Type)
+	}
+
+	return nil
+}
+
+// checkUltralightWrite validates a page write. The UID, lock, OTP and configuration pages
+// are only written with config set.
+func (r *Reader) checkUltralightWrite(page int, config bool) error {
+	if err := r.checkUltralightPage(page); err != nil {
+		return err
+	}
+
+	if !config && r.lastCard.IsConfigPage(page) {
+		return fmt.Errorf("refusing to write configuration page %d of %s without opting in", page, r.lastCard.Type)
+	}
+
+	return nil
+}
+
+// ackCommand sends a frame followed by its CRC_A; the card answers with a 4-bit ACK or NAK
+func (r *Reader) ackCommand(frame []byte) error {
+	backData, err := r.toCard2(PCDTransceive, r.appendCRC(frame))
+	if err != nil {
+		return err
+	}
+
+	if len(backData) != 1 {
+		return fmt.Errorf("%w: expected ACK, got %d bytes", ErrProtocol, len(backData))
+	}
+	if code := backData[0] & 0x0F; code != piccACK {
+		return r.nakError(code)
+	}
+
+	return nil
+}
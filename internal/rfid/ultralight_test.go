@@ -0,0 +1,179 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUltralightPages(t *testing.T) {
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	card := NewVirtualUltralight(uid)
+	reader, _ := newSimulatedReader(t, card)
+
+	scanned, err := reader.ScanForCard()
+	if err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if scanned.Type != CardTypeMifareUL {
+		t.Fatalf("ScanForCard() type = %v, want %v", scanned.Type, CardTypeMifareUL)
+	}
+
+	if err := reader.WritePage(4, []byte("NDEF")); err != nil {
+		t.Fatalf("WritePage() error = %v", err)
+	}
+	if err := reader.CompatibilityWritePage(5, []byte{0xCA, 0xFE, 0xBA, 0xBE}); err != nil {
+		t.Fatalf("CompatibilityWritePage() error = %v", err)
+	}
+
+	data, err := reader.ReadPages(4)
+	if err != nil {
+		t.Fatalf("ReadPages() error = %v", err)
+	}
+	want := []byte{'N', 'D', 'E', 'F', 0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(data, want) {
+		t.Errorf("ReadPages(4) = %x, want %x", data, want)
+	}
+
+	// Reads roll over at the end of memory
+	data, err = reader.ReadPages(14)
+	if err != nil {
+		t.Fatalf("ReadPages(14) error = %v", err)
+	}
+	if !bytes.Equal(data[8:12], card.Page(0)) {
+		t.Errorf("ReadPages(14) did not roll over to page 0: %x", data)
+	}
+
+	if err := reader.WritePage(0, []byte{0, 0, 0, 0}); err == nil {
+		t.Error("WritePage(0) succeeded on the read-only UID page")
+	}
+	if err := reader.WritePage(4, []byte{0}); err == nil {
+		t.Error("WritePage() accepted a short page")
+	}
+	if _, err := reader.ReadBlock(4); err == nil {
+		t.Error("ReadBlock() succeeded on an Ultralight card")
+	}
+}
+
+func TestUltralightLockBits(t *testing.T) {
+	card := NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	card.memory[10] = 0x10 // Lock page 4
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+	if err := reader.WritePage(4, []byte("LOCK")); err == nil {
+		t.Error("WritePage() succeeded on a locked page")
+	}
+}
+
+func TestCompatibilityWritePageRetry(t *testing.T) {
+	card := NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	reader, sim := newSimulatedReader(t, card)
+	reader.SetRetryPolicy(RetryPolicy{Retries: 2})
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// The ACK of the data frame is damaged; the page is read back instead of written again
+	data := []byte{0xCA, 0xFE, 0xBA, 0xBE}
+	sim.InjectError(1, errCRC)
+	err := reader.Session(context.Background(), func(s *Session) error {
+		return s.CompatibilityWritePage(6, data)
+	})
+	if err != nil {
+		t.Fatalf("Session.CompatibilityWritePage() error = %v", err)
+	}
+	if !bytes.Equal(card.Page(6), data) {
+		t.Errorf("page 6 = %x, want %x", card.Page(6), data)
+	}
+	if stats := reader.Stats()["write"]; stats.Retries != 1 {
+		t.Errorf("write stats = %+v, want 1 retry", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := reader.CompatibilityWritePageContext(ctx, 7, data); !errors.Is(err, context.Canceled) {
+		t.Errorf("CompatibilityWritePageContext() with a cancelled context error = %v", err)
+	}
+}
+
+func TestUltralightConfigPages(t *testing.T) {
+	ultralight := NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	reader, _ := newSimulatedReader(t, ultralight)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// The OTP page is refused unless the caller opts in
+	otp := []byte{0x00, 0x00, 0x00, 0x01}
+	if err := reader.WritePage(3, otp); err == nil {
+		t.Error("WritePage(3) succeeded on the OTP page")
+	}
+	if err := reader.CompatibilityWritePage(3, otp); err == nil {
+		t.Error("CompatibilityWritePage(3) succeeded on the OTP page")
+	}
+	if !bytes.Equal(ultralight.Page(3), make([]byte, 4)) {
+		t.Fatalf("OTP page = %x after refused writes", ultralight.Page(3))
+	}
+	if err := reader.WriteConfigPage(3, otp); err != nil {
+		t.Fatalf("WriteConfigPage(3) error = %v", err)
+	}
+	if !bytes.Equal(ultralight.Page(3), otp) {
+		t.Errorf("OTP page = %x, want %x", ultralight.Page(3), otp)
+	}
+
+	ntag := NewVirtualNTAG213(testNTAGUID)
+	reader, _ = newSimulatedReader(t, ntag)
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	// Pages 40-44 hold the dynamic lock bits, CFG0, CFG1, PWD and PACK
+	for page := 40; page <= 44; page++ {
+		if err := reader.WritePage(page, []byte{0, 0, 0, 0}); err == nil {
+			t.Errorf("WritePage(%d) succeeded on a configuration page", page)
+		}
+	}
+	if err := reader.WritePage(39, []byte("LAST")); err != nil {
+		t.Errorf("WritePage(39) on the last user page error = %v", err)
+	}
+
+	cfg0 := []byte{0x04, 0x00, 0x00, 0x10}
+	err := reader.Session(context.Background(), func(s *Session) error {
+		return s.WriteConfigPage(41, cfg0)
+	})
+	if err != nil {
+		t.Fatalf("Session.WriteConfigPage(41) error = %v", err)
+	}
+	if !bytes.Equal(ntag.Page(41), cfg0) {
+		t.Errorf("CFG0 = %x, want %x", ntag.Page(41), cfg0)
+	}
+}
+
+func TestUltralightReadCard(t *testing.T) {
+	card := NewVirtualUltralight([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	card.SetPage(6, []byte("RFID"))
+	reader, _ := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	data, err := reader.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+	if len(data) != 16 {
+		t.Errorf("ReadCard() returned %d pages, want 16", len(data))
+	}
+	if !bytes.Equal(data[6], []byte("RFID")) {
+		t.Errorf("ReadCard() page 6 = %x, want %x", data[6], []byte("RFID"))
+	}
+	if !bytes.Equal(data[0], card.Page(0)) {
+		t.Errorf("ReadCard() page 0 = %x, want %x", data[0], card.Page(0))
+	}
+}
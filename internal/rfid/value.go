@@ -0,0 +1,229 @@
+package rfid
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// EncodeValueBlock formats a MIFARE Classic value block: the value, its inverse and the
+// value again (little-endian), followed by the address byte, its inverse, the address
+// and its inverse
+func EncodeValueBlock(value int32, addr byte) []byte {
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint32(data[0:4], uint32(value))
+	binary.LittleEndian.PutUint32(data[4:8], ^uint32(value))
+	binary.LittleEndian.PutUint32(data[8:12], uint32(value))
+	data[12], data[13], data[14], data[15] = addr, ^addr, addr, ^addr
+	return data
+}
+
+// DecodeValueBlock parses a value block and checks its value and address redundancy
+func DecodeValueBlock(data []byte) (int32, byte, error) {
+	if len(data) != 16 {
+		return 0, 0, fmt.Errorf("value block must be exactly 16 bytes")
+	}
+
+	value := binary.LittleEndian.Uint32(data[0:4])
+	if binary.LittleEndian.Uint32(data[4:8]) != ^value || binary.LittleEndian.Uint32(data[8:12]) != value {
+		return 0, 0, fmt.Errorf("value redundancy check failed")
+	}
+
+	addr := data[12]
+	if data[13] != ^addr || data[14] != addr || data[15] != ^addr {
+		return 0, 0, fmt.Errorf("address redundancy check failed")
+	}
+
+	return int32(value), addr, nil
+}
+
+// ReadValue reads a value block and returns its value and address byte
+func (r *Reader) ReadValue(block int) (int32, byte, error) {
+	return r.ReadValueContext(context.Background(), block)
+}
+
+// ReadValueContext is ReadValue bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) ReadValueContext(ctx context.Context, block int) (int32, byte, error) {
+	var value int32
+	var addr byte
+	_, err := callContext(ctx, r, func() (struct{}, error) {
+		var err error
+		value, addr, err = r.readValue(block)
+		return struct{}{}, err
+	})
+	return value, addr, err
+}
+
+// readValue implements ReadValue
+func (r *Reader) readValue(block int) (int32, byte, error) {
+	data, err := r.readBlock(block)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	value, addr, err := DecodeValueBlock(data)
+	if err != nil {
+		return 0, 0, fmt.Errorf("block %d is not a value block: %w", block, err)
+	}
+
+	return value, addr, nil
+}
+
+// WriteValue formats a block as a value block holding value.
+// addr is stored alongside and is commonly used for the block's own number.
+func (r *Reader) WriteValue(block int, value int32, addr byte) error {
+	return r.WriteValueContext(context.Background(), block, value, addr)
+}
+
+// WriteValueContext is WriteValue bounded by ctx. Without a deadline on ctx the
+// operation timeout applies.
+func (r *Reader) WriteValueContext(ctx context.Context, block int, value int32, addr byte) error {
+	_, err := callContext(ctx, r, func() (struct{}, error) {
+		return struct{}{}, r.writeValue(block, value, addr)
+	})
+	return err
+}
+
+// writeValue implements WriteValue
+func (r *Reader) writeValue(block int, value int32, addr byte) error {
+	if r.lastCard != nil && r.lastCard.IsTrailer(block) {
+		return fmt.Errorf("block %d is a sector trailer", block)
+	}
+
+	return r.writeBlock(block, EncodeValueBlock(value, addr))
+}
+
+// Increment adds delta to a value block and keeps the result in the card's transfer buffer.
+// Call Transfer to store it; only its ACK confirms that the card took the increment.
+func (r *Reader) Increment(block int, delta uint32) error {
+	return r.run(func() error {
+		return r.increment(block, delta)
+	})
+}
+
+// increment implements Increment
+func (r *Reader) increment(block int, delta uint32) error {
+	return r.valueOperation(PICCIncrement, block, delta, accessIncrement)
+}
+
+// Decrement subtracts delta from a value block and keeps the result in the card's transfer
+// buffer. Call Transfer to store it; only its ACK confirms that the card took the decrement.
+func (r *Reader) Decrement(block int, delta uint32) error {
+	return r.run(func() error {
+		return r.decrement(block, delta)
+	})
+}
+
+// decrement implements Decrement
+func (r *Reader) decrement(block int, delta uint32) error {
+	return r.valueOperation(PICCDecrement, block, delta, accessDecrement)
+}
+
+// Restore loads a value block into the card's transfer buffer unchanged, so that Transfer
+// can copy it to another block of the same sector
+func (r *Reader) Restore(block int) error {
+	return r.run(func() error {
+		return r.restore(block)
+	})
+}
+
+// restore implements Restore
+func (r *Reader) restore(block int) error {
+	return r.valueOperation(PICCRestore, block, 0, accessDecrement)
+}
+
+// Transfer writes the card's transfer buffer to a value block. It must follow Increment,
+// Decrement or Restore on the same sector without another authentication in between.
+func (r *Reader) Transfer(block int) error {
+	return r.run(func() error {
+		return r.transfer(block)
+	})
+}
+
+// transfer implements Transfer
+func (r *Reader) transfer(block int) error {
+	sector, err := r.checkValueBlock(block)
+	if err != nil {
+		return err
+	}
+
+	if r.authSector != sector {
+		return fmt.Errorf("transfer requires a preceding value operation in sector %d", sector)
+	}
+	keyType, err := r.chooseKey(block, accessDecrement)
+	if err != nil {
+		return err
+	}
+	if keyType != r.authKey {
+		return fmt.Errorf("%s may not transfer to block %d", r.authKey, block)
+	}
+
+	if err := r.ackCommand([]byte{PICCTransfer, byte(block)}); err != nil {
+		_ = r.reactivate()
+		return fmt.Errorf("transfer failed: %w", err)
+	}
+
+	return nil
+}
+
+// valueOperation authenticates with a key allowed to perform op and sends an
+// increment, decrement or restore command with its operand. The card only answers the
+// operand to reject it, so silence cannot tell success from a card that left the field;
+// the ACK of the Transfer that must follow is what confirms the operation.
+func (r *Reader) valueOperation(command byte, block int, operand uint32, op accessOp) error {
+	sector, err := r.checkValueBlock(block)
+	if err != nil {
+		return err
+	}
+
+	keyType, err := r.chooseKey(block, op)
+	if err != nil {
+		return err
+	}
+	if err := r.authenticateSector(sector, keyType); err != nil {
+		return err
+	}
+
+	if err := r.ackCommand([]byte{command, byte(block)}); err != nil {
+		_ = r.reactivate()
+		return fmt.Errorf("%s failed: %w", op, err)
+	}
+
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, operand)
+	backData, err := r.toCard2(PCDTransceive, r.appendCRC(data))
+	switch {
+	case errors.Is(err, ErrNoTag):
+		return nil
+	case err == nil && len(backData) == 1:
+		err = r.nakError(backData[0] & 0x0F)
+	case err == nil:
+		err = fmt.Errorf("%w: unexpected answer to the operand", ErrProtocol)
+	}
+
+	_ = r.reactivate()
+	return fmt.Errorf("%s failed: %w", op, err)
+}
+
+// checkValueBlock validates that block is a data block of the last Classic card and returns its sector
+func (r *Reader) checkValueBlock(block int) (int, error) {
+	if r.lastCard == nil {
+		return 0, fmt.Errorf("no card selected")
+	}
+
+	if !r.lastCard.IsClassic() {
+		return 0, fmt.Errorf("%s cards do not support value blocks", r.lastCard.Type)
+	}
+
+	sector := r.lastCard.SectorOfBlock(block)
+	if sector < 0 {
+		return 0, fmt.Errorf("block %d is out of range for %s", block, r.lastCard.Type)
+	}
+	if block == 0 || r.lastCard.IsTrailer(block) {
+		return 0, fmt.Errorf("block %d cannot hold a value", block)
+	}
+
+	return sector, nil
+}
@@ -0,0 +1,120 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestValueBlockFormat(t *testing.T) {
+	want := []byte{
+		0x64, 0x00, 0x00, 0x00, 0x9B, 0xFF, 0xFF, 0xFF,
+		0x64, 0x00, 0x00, 0x00, 0x05, 0xFA, 0x05, 0xFA,
+	}
+	if got := EncodeValueBlock(100, 5); !bytes.Equal(got, want) {
+		t.Errorf("EncodeValueBlock(100, 5) = %x, want %x", got, want)
+	}
+
+	value, addr, err := DecodeValueBlock(EncodeValueBlock(-42, 9))
+	if err != nil || value != -42 || addr != 9 {
+		t.Errorf("DecodeValueBlock() = %d, %d, %v; want -42, 9", value, addr, err)
+	}
+
+	corrupt := EncodeValueBlock(100, 5)
+	corrupt[4] ^= 0x01
+	if _, _, err := DecodeValueBlock(corrupt); err == nil {
+		t.Error("DecodeValueBlock() accepted a bad inverted value")
+	}
+	corrupt = EncodeValueBlock(100, 5)
+	corrupt[15] = 0x05
+	if _, _, err := DecodeValueBlock(corrupt); err == nil {
+		t.Error("DecodeValueBlock() accepted a bad address")
+	}
+}
+
+func TestValueOperations(t *testing.T) {
+	// Sector 1 holds value blocks: increment needs Key B, decrement allows Key A or B
+	valueBlock, _ := DataBlockCondition(DataBlockPermissions{
+		Read: PermissionKeyAB, Write: PermissionKeyB, Increment: PermissionKeyB, Decrement: PermissionKeyAB,
+	})
+	trailerCondition, _ := TrailerCondition(TrailerPermissions{
+		WriteKeyA: PermissionKeyB, ReadAccessBits: PermissionKeyAB, WriteAccessBits: PermissionKeyB,
+		ReadKeyB: PermissionNever, WriteKeyB: PermissionKeyB,
+	})
+	trailer, err := EncodeTrailer(factoryKey(), AccessBits{valueBlock, valueBlock, valueBlock, trailerCondition}, 0x69, factoryKey())
+	if err != nil {
+		t.Fatalf("EncodeTrailer() error = %v", err)
+	}
+
+	card := NewVirtualClassic1K([]byte{0x41, 0x42, 0x43, 0x44})
+	card.SetBlock(7, trailer)
+	reader, sim := newSimulatedReader(t, card)
+
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Fatalf("ScanForCard() error = %v", err)
+	}
+
+	if err := reader.Transfer(4); err == nil {
+		t.Error("Transfer() succeeded without a value operation")
+	}
+	if err := reader.Increment(4, 1); err == nil {
+		t.Error("Increment() succeeded on a block that is not a value block")
+	}
+
+	if err := reader.WriteValue(4, 100, 4); err != nil {
+		t.Fatalf("WriteValue() error = %v", err)
+	}
+	if err := reader.Increment(4, 25); err != nil {
+		t.Fatalf("Increment() error = %v", err)
+	}
+	if err := reader.Transfer(4); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	if value, addr, err := reader.ReadValue(4); err != nil || value != 125 || addr != 4 {
+		t.Errorf("ReadValue(4) after increment = %d, %d, %v; want 125, 4", value, addr, err)
+	}
+
+	// Decrement into a backup block of the same sector
+	if err := reader.Decrement(4, 130); err != nil {
+		t.Fatalf("Decrement() error = %v", err)
+	}
+	if err := reader.Transfer(5); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	if value, _, err := reader.ReadValue(5); err != nil || value != -5 {
+		t.Errorf("ReadValue(5) after decrement = %d, %v; want -5", value, err)
+	}
+
+	if err := reader.Restore(4); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if err := reader.Transfer(6); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	if !bytes.Equal(card.Block(6), card.Block(4)) {
+		t.Errorf("block 6 after restore = %x, want %x", card.Block(6), card.Block(4))
+	}
+
+	if err := reader.Increment(7, 1); err == nil {
+		t.Error("Increment() accepted a sector trailer")
+	}
+
+	// The operand goes unanswered either way; only the transfer notices the card is gone
+	before := card.Block(4)
+	err = reader.Session(context.Background(), func(s *Session) error {
+		if err := s.Increment(4, 1); err != nil {
+			return err
+		}
+		sim.RemoveCard(card)
+		if err := s.Transfer(4); err == nil {
+			t.Error("Transfer() succeeded after the card left the field")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Increment() error = %v", err)
+	}
+	if !bytes.Equal(card.Block(4), before) {
+		t.Errorf("block 4 = %x after a failed transfer, want %x", card.Block(4), before)
+	}
+}
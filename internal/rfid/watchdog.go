@@ -0,0 +1,131 @@
+package rfid
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Health is the state of the MFRC522 as seen by the watchdog
+type Health int32
+
+const (
+	// HealthOK means the chip answers and keeps its configuration
+	HealthOK Health = iota
+	// HealthDegraded means the chip answers unreliably, or lost its configuration and
+	// could not be configured again
+	HealthDegraded
+	// HealthAbsent means the chip does not answer; card operations fail with ErrReaderAbsent
+	HealthAbsent
+)
+
+// String returns the name of the state
+func (h Health) String() string {
+	switch h {
+	case HealthOK:
+		return "ok"
+	case HealthDegraded:
+		return "degraded"
+	case HealthAbsent:
+		return "absent"
+	default:
+		return "unknown"
+	}
+}
+
+// initTMode is the TModeReg value written by init. The register resets to 0x00, so a
+// different value means the chip was reset behind the reader's back, e.g. by a brown-out.
+const initTMode = 0x8D
+
+// healthReads is the number of VersionReg reads in each check; a loose contact shows
+// up as some of them going wrong
+const healthReads = 3
+
+// Health returns the state found by the last check
+func (r *Reader) Health() Health {
+	return Health(r.health.Load())
+}
+
+// StartWatchdog checks the chip every interval until the reader is closed. A chip that
+// stopped answering or lost its configuration is reset and configured again, so a reader
+// that browned out, or was attached after startup, is picked up without a restart.
+// Checks yield to user operations like presence polling. Only the first call starts a
+// watchdog; later calls do nothing, so two watchdogs never reset the chip against each other.
+func (r *Reader) StartWatchdog(interval time.Duration) {
+	r.watchdogOnce.Do(func() {
+		go r.watchdog(interval)
+	})
+}
+
+// watchdog runs the checks of StartWatchdog until the reader is closed
+func (r *Reader) watchdog(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.sched.closing:
+			return
+		case <-ticker.C:
+			_ = r.exec(context.Background(), PriorityBackground, func() error {
+				r.setHealth(r.checkHealth())
+				return nil
+			})
+		}
+	}
+}
+
+// CheckHealth checks the chip at once, as the watchdog does, and returns its state
+func (r *Reader) CheckHealth() Health {
+	_ = r.exec(context.Background(), PriorityBackground, func() error {
+		r.setHealth(r.checkHealth())
+		return nil
+	})
+	return r.Health()
+}
+
+// checkHealth reads VersionReg and TModeReg and configures the chip again when needed
+func (r *Reader) checkHealth() Health {
+	answers := 0
+	for i := 0; i < healthReads; i++ {
+		version := r.readRegister(VersionReg)
+		if version == r.chipVersion || (r.Health() == HealthAbsent && version != 0x00 && version != 0xFF) {
+			answers++
+		}
+	}
+
+	switch {
+	case answers == 0 || r.Health() == HealthAbsent:
+		if err := r.reinit(); err != nil {
+			return HealthAbsent
+		}
+		return HealthOK
+	case r.readRegister(TModeReg) != initTMode:
+		log.Printf("MFRC522 lost its configuration, initializing it again")
+		if err := r.reinit(); err != nil {
+			log.Printf("Warning: Failed to initialize reader: %v", err)
+			return HealthDegraded
+		}
+		return HealthOK
+	case answers < healthReads:
+		return HealthDegraded
+	default:
+		return HealthOK
+	}
+}
+
+// setHealth records the state and logs changes
+func (r *Reader) setHealth(health Health) {
+	if previous := Health(r.health.Swap(int32(health))); previous != health {
+		log.Printf("Reader state: %s -> %s", previous, health)
+	}
+}
+
+// reinit resets and configures the chip again. The card in the field loses power, so
+// it has to be scanned again.
+func (r *Reader) reinit() error {
+	r.lastCard = nil
+	r.published.Store(nil)
+	r.authSector = -1
+	return r.init()
+}
@@ -0,0 +1,127 @@
+package rfid
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+// flakyVersion is a transport on which every second read of VersionReg goes wrong,
+// as with a loose contact
+type flakyVersion struct {
+	Transport
+	reads int
+}
+
+func (f *flakyVersion) ReadRegister(reg byte) (byte, error) {
+	value, err := f.Transport.ReadRegister(reg)
+	if reg == VersionReg {
+		f.reads++
+		if f.reads%2 == 0 {
+			value = 0xFF
+		}
+	}
+	return value, err
+}
+
+func TestHealthAttachedLater(t *testing.T) {
+	sim := NewSimulator()
+	sim.AddCard(NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}))
+	sim.SetDetached(true)
+
+	reader, err := NewReaderWithTransport(sim, config.Default().RFID)
+	if !errors.Is(err, ErrReaderAbsent) || reader == nil {
+		t.Fatalf("NewReaderWithTransport() = %v, %v, want an absent reader", reader, err)
+	}
+	defer reader.Close()
+
+	if reader.Health() != HealthAbsent {
+		t.Errorf("Health() = %s, want absent", reader.Health())
+	}
+	if _, err := reader.ScanForCard(); !errors.Is(err, ErrReaderAbsent) {
+		t.Errorf("ScanForCard() on an absent reader error = %v, want ErrReaderAbsent", err)
+	}
+	if health := reader.CheckHealth(); health != HealthAbsent {
+		t.Errorf("CheckHealth() while detached = %s, want absent", health)
+	}
+
+	sim.SetDetached(false)
+	if health := reader.CheckHealth(); health != HealthOK {
+		t.Fatalf("CheckHealth() after attaching = %s, want ok", health)
+	}
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Errorf("ScanForCard() after attaching error = %v", err)
+	}
+}
+
+func TestHealthBrownOut(t *testing.T) {
+	cfg := config.Default().RFID
+	gain := 48
+	cfg.AntennaGainDB = &gain
+	sim := NewSimulator()
+	sim.AddCard(NewVirtualClassic1K([]byte{0x11, 0x22, 0x33, 0x44}))
+	reader, err := NewReaderWithTransport(sim, cfg)
+	if err != nil {
+		t.Fatalf("NewReaderWithTransport() error = %v", err)
+	}
+	defer reader.Close()
+
+	if health := reader.CheckHealth(); health != HealthOK {
+		t.Errorf("CheckHealth() = %s, want ok", health)
+	}
+
+	// A brown-out resets every register
+	_ = sim.WriteRegister(CommandReg, PCDResetPhase)
+	if health := reader.CheckHealth(); health != HealthOK {
+		t.Errorf("CheckHealth() after a reset = %s, want ok", health)
+	}
+	for reg, want := range map[byte]byte{TModeReg: initTMode, RFCfgReg: 0x78} {
+		if got, _ := sim.ReadRegister(reg); got != want {
+			t.Errorf("register 0x%02x = 0x%02x after the check, want 0x%02x", reg, got, want)
+		}
+	}
+	if _, err := reader.ScanForCard(); err != nil {
+		t.Errorf("ScanForCard() after the check error = %v", err)
+	}
+}
+
+func TestHealthDegraded(t *testing.T) {
+	sim := NewSimulator()
+	reader, err := NewReaderWithTransport(sim, config.Default().RFID)
+	if err != nil {
+		t.Fatalf("NewReaderWithTransport() error = %v", err)
+	}
+	defer reader.Close()
+
+	_ = reader.run(func() error {
+		reader.transport = &flakyVersion{Transport: reader.transport}
+		return nil
+	})
+	if health := reader.CheckHealth(); health != HealthDegraded {
+		t.Errorf("CheckHealth() with a loose contact = %s, want degraded", health)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	reader, sim := newSimulatedReader(t)
+	defer reader.Close()
+	reader.StartWatchdog(5 * time.Millisecond)
+
+	waitFor := func(want Health) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for reader.Health() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Health() = %s, want %s", reader.Health(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	sim.SetDetached(true)
+	waitFor(HealthAbsent)
+	sim.SetDetached(false)
+	waitFor(HealthOK)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"rfid-tool-rpi/internal/config"
+)
+
+// CalibrateRequest starts an antenna calibration with the selected card
+type CalibrateRequest struct {
+	Attempts int  `json:"attempts"` // Attempts per setting, 10 if left out
+	Save     bool `json:"save"`     // Save the chosen setting to the configuration file
+}
+
+// CalibrationSetting is the success count of one calibration setting
+type CalibrationSetting struct {
+	GainDB         int     `json:"antenna_gain_db"`
+	RxMinLevel     int     `json:"rx_min_level"`
+	Attempts       int     `json:"attempts"`
+	Requests       int     `json:"requests"`
+	Anticollisions int     `json:"anticollisions"`
+	Reads          int     `json:"reads"`
+	Rate           float64 `json:"rate"`
+}
+
+// CalibrationData is the result of an antenna calibration
+type CalibrationData struct {
+	Best     TuningData           `json:"best"`
+	Settings []CalibrationSetting `json:"settings"`
+	Saved    bool                 `json:"saved"`
+}
+
+// handleCalibrate handles sweeping the antenna settings with the selected card as reference
+func (ws *WebServer) handleCalibrate(w http.ResponseWriter, r *http.Request) {
+	req := CalibrateRequest{Attempts: 10}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
+	if req.Save && (ws.config == nil || ws.configFile == "") {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "No configuration file to save to",
+		})
+		return
+	}
+
+	calibration, err := ws.reader.CalibrateContext(r.Context(), req.Attempts, nil)
+	if err != nil {
+		ws.writeError(w, "Calibration failed", err)
+		return
+	}
+
+	data := CalibrationData{
+		Best:     newTuningData(calibration.Best),
+		Settings: make([]CalibrationSetting, len(calibration.Results)),
+	}
+	for i, res := range calibration.Results {
+		data.Settings[i] = CalibrationSetting{
+			GainDB:         res.Tuning.GainDB,
+			RxMinLevel:     res.Tuning.RxMinLevel,
+			Attempts:       res.Attempts,
+			Requests:       res.Requests,
+			Anticollisions: res.Anticollisions,
+			Reads:          res.Reads,
+			Rate:           res.Rate(),
+		}
+	}
+
+	message := fmt.Sprintf("Calibrated to %d dB gain, MinLevel %d", calibration.Best.GainDB, calibration.Best.RxMinLevel)
+	if req.Save {
+		err := ws.updateConfig(func(cfg *config.Config) {
+			calibration.Best.Apply(&cfg.RFID)
+		})
+		if err != nil {
+			ws.writeJSON(w, APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("%s, but saving failed: %v", message, err),
+				Data:    data,
+			})
+			return
+		}
+		data.Saved = true
+		message += ", saved to " + ws.configFile
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    data,
+	})
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// DiagnosticsData is the MFRC522 diagnostics report
+type DiagnosticsData struct {
+	Version   string         `json:"version"`
+	Chip      string         `json:"chip"`
+	SelfTest  SelfTestData   `json:"self_test"`
+	Registers []RegisterData `json:"registers"`
+	SPISpeed  int            `json:"spi_speed"`
+	SPILink   []SPILinkData  `json:"spi_link,omitempty"`
+}
+
+// SPILinkData is the outcome of the SPI link test at one clock rate
+type SPILinkData struct {
+	Hz     int `json:"hz"`
+	Errors int `json:"errors"`
+}
+
+// RegisterData is the content of one MFRC522 register
+type RegisterData struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Value   string `json:"value"`
+}
+
+// SelfTestData is the outcome of the MFRC522 digital self-test
+type SelfTestData struct {
+	Passed    bool   `json:"passed"`
+	Result    string `json:"result,omitempty"`
+	Reference string `json:"reference,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleDiagnostics handles running the MFRC522 self-test and dumping its registers
+func (ws *WebServer) handleDiagnostics(w http.ResponseWriter, _ *http.Request) {
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
+
+	d, err := ws.reader.Diagnostics()
+	if d == nil {
+		ws.writeError(w, "Failed to run diagnostics", err)
+		return
+	}
+
+	data := DiagnosticsData{
+		Version: fmt.Sprintf("0x%02x", d.Version),
+		Chip:    d.Chip,
+		SelfTest: SelfTestData{
+			Passed:    d.SelfTest.Passed,
+			Result:    hex.EncodeToString(d.SelfTest.Result),
+			Reference: hex.EncodeToString(d.SelfTest.Reference),
+			Error:     d.SelfTest.Err,
+		},
+		Registers: make([]RegisterData, len(d.Registers)),
+		SPISpeed:  d.SPISpeed,
+	}
+	if d.SPILink != nil {
+		for _, res := range d.SPILink.Results {
+			data.SPILink = append(data.SPILink, SPILinkData{Hz: res.Hz, Errors: res.Errors})
+		}
+	}
+	for i, reg := range d.Registers {
+		data.Registers[i] = RegisterData{
+			Name:    reg.Name,
+			Address: fmt.Sprintf("0x%02x", reg.Address),
+			Value:   fmt.Sprintf("0x%02x", reg.Value),
+		}
+	}
+
+	message := "Self-test passed"
+	switch {
+	case err != nil:
+		message = fmt.Sprintf("Diagnostics completed, but the reader did not restart: %v", err)
+	case d.SelfTest.Err != "":
+		message = "Self-test did not complete"
+	case d.SelfTest.Reference == nil:
+		message = "No self-test reference for this chip version"
+	case !d.SelfTest.Passed:
+		message = "Self-test result differs from the reference"
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: err == nil,
+		Message: message,
+		Data:    data,
+	})
+}
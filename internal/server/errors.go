@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"rfid-tool-rpi/internal/rfid"
+)
+
+// errNoCard is returned by a session that finds no card, e.g. after presence polling lost it
+var errNoCard = errors.New("no card selected")
+
+// readerErrors maps reader errors to an HTTP status and the code reported in APIResponse.Error
+var readerErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{rfid.ErrNoTag, http.StatusNotFound, "no_tag"},
+	{rfid.ErrAuthDenied, http.StatusForbidden, "auth_denied"},
+	{rfid.ErrNAK, http.StatusForbidden, "nak"},
+	{rfid.ErrCollision, http.StatusConflict, "collision"},
+	{rfid.ErrTimeout, http.StatusGatewayTimeout, "timeout"},
+	{rfid.ErrCRC, http.StatusBadGateway, "crc"},
+	{rfid.ErrParity, http.StatusBadGateway, "parity"},
+	{rfid.ErrProtocol, http.StatusBadGateway, "protocol"},
+	{rfid.ErrBufferOverflow, http.StatusInternalServerError, "buffer_overflow"},
+	{rfid.ErrReaderClosed, http.StatusServiceUnavailable, "reader_closed"},
+	{rfid.ErrReaderAbsent, http.StatusServiceUnavailable, "reader_absent"},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, "deadline_exceeded"},
+}
+
+// writeError writes a failure response for a reader error. Errors reported by the card
+// or the MFRC522 get a matching HTTP status; any other error keeps the 200 used by the
+// remaining failure responses.
+func (ws *WebServer) writeError(w http.ResponseWriter, message string, err error) {
+	status := http.StatusOK
+	response := APIResponse{
+		Success: false,
+		Message: fmt.Sprintf("%s: %v", message, err),
+	}
+
+	for _, entry := range readerErrors {
+		if errors.Is(err, entry.err) {
+			status = entry.status
+			response.Error = entry.code
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
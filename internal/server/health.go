@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net/http"
+)
+
+// HealthData reports the state of the MFRC522 found by the watchdog
+type HealthData struct {
+	Health string `json:"health"`
+}
+
+// handleHealth handles reading the reader health
+func (ws *WebServer) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Health retrieved",
+		Data:    HealthData{Health: ws.reader.Health().String()},
+	})
+}
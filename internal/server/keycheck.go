@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"rfid-tool-rpi/internal/rfid"
+)
+
+// KeyCheckRequest starts a dictionary check
+type KeyCheckRequest struct {
+	Dictionary string `json:"dictionary"` // Contents of a .dic file
+}
+
+// KeyCheckProgress is the JSON form of rfid.KeyCheckProgress
+type KeyCheckProgress struct {
+	Sector   int `json:"sector"`
+	Sectors  int `json:"sectors"`
+	Attempts int `json:"attempts"`
+	Total    int `json:"total"`
+	Found    int `json:"found"`
+}
+
+// SectorKeys holds the keys found for a sector as hex strings
+type SectorKeys struct {
+	KeyA string `json:"key_a,omitempty"`
+	KeyB string `json:"key_b,omitempty"`
+}
+
+// KeyCheckStatus represents the state of the last dictionary check
+type KeyCheckStatus struct {
+	Sectors  map[string]SectorKeys `json:"sectors,omitempty"`
+	Error    string                `json:"error,omitempty"`
+	Progress KeyCheckProgress      `json:"progress"`
+	Running  bool                  `json:"running"`
+}
+
+// keyCheckState tracks the dictionary check running in the background
+type keyCheckState struct {
+	mu     sync.Mutex
+	status KeyCheckStatus
+	cancel context.CancelFunc // Stops the running check
+}
+
+// stop cancels the running dictionary check, if any
+func (s *keyCheckState) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// running reports whether a dictionary check is in progress
+func (s *keyCheckState) running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.Running
+}
+
+// snapshot returns a copy of the current status
+func (s *keyCheckState) snapshot() KeyCheckStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// handleKeyCheck starts a dictionary check of the selected card in the background.
+// Progress is reported over the WebSocket and by handleKeyCheckStatus. The check outlives
+// the request, so it is stopped by handleCancelKeyCheck rather than by the request context.
+func (ws *WebServer) handleKeyCheck(w http.ResponseWriter, r *http.Request) {
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+
+	card := ws.reader.GetLastCard()
+	if card == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "No card selected",
+		})
+		return
+	}
+	if !card.IsClassic() {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Key check requires a MIFARE Classic card",
+		})
+		return
+	}
+
+	var req KeyCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	dictionary, err := rfid.ParseDictionary(strings.NewReader(req.Dictionary))
+	if err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Invalid dictionary: %v", err),
+		})
+		return
+	}
+
+	ws.keyCheck.mu.Lock()
+	if ws.keyCheck.status.Running {
+		ws.keyCheck.mu.Unlock()
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Key check already running",
+		})
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ws.keyCheck.status = KeyCheckStatus{Running: true}
+	ws.keyCheck.cancel = cancel
+	ws.keyCheck.mu.Unlock()
+
+	go ws.runKeyCheck(ctx, dictionary)
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Key check started with %d keys", len(dictionary)),
+	})
+}
+
+// runKeyCheck performs the dictionary check and records its progress and result
+func (ws *WebServer) runKeyCheck(ctx context.Context, dictionary [][]byte) {
+	result, err := ws.reader.CheckKeysContext(ctx, dictionary, func(p rfid.KeyCheckProgress) {
+		ws.keyCheck.mu.Lock()
+		ws.keyCheck.status.Progress = KeyCheckProgress(p)
+		ws.keyCheck.mu.Unlock()
+	})
+
+	ws.keyCheck.mu.Lock()
+	defer ws.keyCheck.mu.Unlock()
+
+	ws.keyCheck.status.Running = false
+	ws.keyCheck.cancel()
+	ws.keyCheck.cancel = nil
+	if err != nil {
+		log.Printf("Key check failed: %v", err)
+		ws.keyCheck.status.Error = err.Error()
+	}
+	if result != nil {
+		ws.keyCheck.status.Sectors = make(map[string]SectorKeys, len(result.Sectors))
+		for sector, keys := range result.Sectors {
+			ws.keyCheck.status.Sectors[strconv.Itoa(sector)] = SectorKeys{
+				KeyA: hex.EncodeToString(keys.KeyA),
+				KeyB: hex.EncodeToString(keys.KeyB),
+			}
+		}
+	}
+}
+
+// handleKeyCheckStatus returns the progress or result of the last dictionary check
+func (ws *WebServer) handleKeyCheckStatus(w http.ResponseWriter, _ *http.Request) {
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Data:    ws.keyCheck.snapshot(),
+	})
+}
+
+// handleCancelKeyCheck stops the running dictionary check. The keys found so far are
+// reported by handleKeyCheckStatus.
+func (ws *WebServer) handleCancelKeyCheck(w http.ResponseWriter, _ *http.Request) {
+	if !ws.keyCheck.running() {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "No key check running",
+		})
+		return
+	}
+
+	ws.keyCheck.stop()
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Key check cancelled",
+	})
+}
+
+// rejectDuringKeyCheck answers requests that would disturb a running dictionary check
+func (ws *WebServer) rejectDuringKeyCheck(w http.ResponseWriter) bool {
+	if !ws.keyCheck.running() {
+		return false
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: false,
+		Message: "Key check in progress",
+	})
+	return true
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// RawRequest is a frame sent to the field as it is
+type RawRequest struct {
+	Data          string `json:"data"`           // Frame as hex
+	TxBits        int    `json:"tx_bits"`        // Bits of the last byte to send, 0 for all 8
+	RxAlign       int    `json:"rx_align"`       // Bit position of the first received bit
+	CRC           bool   `json:"crc"`            // Append CRC_A and check it on the answer
+	DisableParity bool   `json:"disable_parity"` // Send and receive without parity bits
+}
+
+// RawData is the answer to a raw frame
+type RawData struct {
+	Data      string `json:"data"`
+	Bits      int    `json:"bits"`
+	Collision int    `json:"collision,omitempty"`
+}
+
+// handleRaw handles sending a raw frame
+func (ws *WebServer) handleRaw(w http.ResponseWriter, r *http.Request) {
+	var req RawRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	frame, err := hex.DecodeString(req.Data)
+	if err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid hex data",
+		})
+		return
+	}
+
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
+
+	response, err := ws.reader.TransceiveContext(r.Context(), frame, req.TxBits, req.RxAlign, req.CRC, !req.DisableParity)
+	if err != nil {
+		ws.writeError(w, "Failed to transceive frame", err)
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Frame answered",
+		Data: RawData{
+			Data:      hex.EncodeToString(response.Data),
+			Bits:      response.Bits,
+			Collision: response.Collision,
+		},
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"rfid-tool-rpi/internal/config"
@@ -21,19 +22,76 @@ import (
 
 // WebServer represents the web server
 type WebServer struct {
-	server   *http.Server
-	reader   *rfid.Reader
-	config   *config.Config
-	upgrader websocket.Upgrader
+	server     *http.Server
+	reader     *rfid.Reader
+	config     *config.Config
+	configFile string
+	configMu   sync.Mutex // Guards changes to config and its file
+	upgrader   websocket.Upgrader
+	keyCheck   keyCheckState
 }
 
 // CardData represents card data for JSON responses
 type CardData struct {
-	Data   map[string]string `json:"data,omitempty"`
-	UID    string            `json:"uid"`
-	Type   string            `json:"type"`
-	Size   int               `json:"size"`
-	Blocks int               `json:"blocks"`
+	Data      map[string]string       `json:"data,omitempty"`
+	Sectors   map[string]SectorAccess `json:"sectors,omitempty"`
+	UID       string                  `json:"uid"`
+	Type      string                  `json:"type"`
+	ATQA      string                  `json:"atqa"`
+	SAK       string                  `json:"sak"`
+	Size      int                     `json:"size"`
+	Blocks    int                     `json:"blocks"`
+	BlockSize int                     `json:"block_size"`
+}
+
+// newCardData converts a card to its JSON representation
+func newCardData(card *rfid.Card) CardData {
+	return CardData{
+		UID:       hex.EncodeToString(card.UID),
+		Type:      string(card.Type),
+		ATQA:      fmt.Sprintf("%04x", card.ATQA),
+		SAK:       fmt.Sprintf("%02x", card.SAK),
+		Size:      card.Size,
+		Blocks:    card.Blocks,
+		BlockSize: blockSize(card),
+	}
+}
+
+// SectorAccess holds the decoded access conditions of a MIFARE Classic sector
+type SectorAccess struct {
+	Blocks       map[string]string `json:"blocks"` // Human-readable permissions per block
+	AccessBits   string            `json:"access_bits"`
+	KeyBReadable bool              `json:"key_b_readable"`
+}
+
+// sectorAccess decodes the access conditions of every sector whose trailer has been read
+func sectorAccess(card *rfid.Card) map[string]SectorAccess {
+	sectors := make(map[string]SectorAccess)
+	for sector := 0; sector < card.Sectors(); sector++ {
+		bits, ok := card.SectorAccess(sector)
+		if !ok {
+			continue
+		}
+
+		access := SectorAccess{
+			Blocks:       make(map[string]string),
+			AccessBits:   bits.String(),
+			KeyBReadable: bits.KeyBReadable(),
+		}
+		for block := card.FirstBlockOfSector(sector); block <= card.TrailerOfSector(sector); block++ {
+			access.Blocks[strconv.Itoa(block)] = bits.DescribeBlock(block)
+		}
+		sectors[strconv.Itoa(sector)] = access
+	}
+	return sectors
+}
+
+// blockSize returns the unit of reads and writes for a card: 16-byte blocks or 4-byte pages
+func blockSize(card *rfid.Card) int {
+	if card.IsUltralight() {
+		return rfid.UltralightPageSize
+	}
+	return 16
 }
 
 // APIResponse represents a standard API response
@@ -46,8 +104,32 @@ type APIResponse struct {
 
 // WriteRequest represents a write request
 type WriteRequest struct {
-	Data  string `json:"data"`
-	Block int    `json:"block"`
+	Data     string `json:"data"`
+	Password string `json:"password,omitempty"` // NTAG PWD_AUTH password in hex, for protected pages
+	Config   bool   `json:"config,omitempty"`   // Allow writing Ultralight/NTAG UID, lock and configuration pages
+	Block    int    `json:"block"`
+}
+
+// parsePassword decodes an optional 4-byte NTAG password given in hex
+func parsePassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	data, err := hex.DecodeString(password)
+	if err != nil || len(data) != 4 {
+		return nil, fmt.Errorf("password must be 4 bytes (8 hex characters)")
+	}
+	return data, nil
+}
+
+// unlock runs PWD_AUTH with password, if given, at the start of a session. The pages stay
+// unlocked until the session ends.
+func unlock(s *rfid.Session, password []byte) error {
+	if password == nil {
+		return nil
+	}
+	_, err := s.PasswordAuth(password)
+	return err
 }
 
 // NewWebServer creates a new web server instance
@@ -63,6 +145,22 @@ func NewWebServer(_ string, reader *rfid.Reader, cfg *config.Config) *WebServer
 	}
 }
 
+// SetConfigFile sets the file the configuration was loaded from, so that calibration
+// results can be saved to it
+func (ws *WebServer) SetConfigFile(filename string) {
+	ws.configFile = filename
+}
+
+// updateConfig changes the configuration and saves it to the configuration file. Updates
+// are serialized, so concurrent requests neither interleave changes nor writes of the file.
+func (ws *WebServer) updateConfig(update func(cfg *config.Config)) error {
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+
+	update(ws.config)
+	return ws.config.Save(ws.configFile)
+}
+
 // Start starts the web server
 func (ws *WebServer) Start() error {
 	router := mux.NewRouter()
@@ -77,10 +175,29 @@ func (ws *WebServer) Start() error {
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/scan", ws.handleScan).Methods("POST")
+	api.HandleFunc("/scan/all", ws.handleScanAll).Methods("POST")
+	api.HandleFunc("/cards/{uid}/select", ws.handleSelectCard).Methods("POST")
 	api.HandleFunc("/read", ws.handleRead).Methods("POST")
 	api.HandleFunc("/read/{block}", ws.handleReadBlock).Methods("GET")
 	api.HandleFunc("/write", ws.handleWrite).Methods("POST")
 	api.HandleFunc("/card/info", ws.handleCardInfo).Methods("GET")
+	api.HandleFunc("/stats", ws.handleStats).Methods("GET")
+	api.HandleFunc("/raw", ws.handleRaw).Methods("POST")
+	api.HandleFunc("/diagnostics", ws.handleDiagnostics).Methods("POST")
+	api.HandleFunc("/reader/tuning", ws.handleTuning).Methods("GET")
+	api.HandleFunc("/reader/tuning", ws.handleSetTuning).Methods("POST")
+	api.HandleFunc("/reader/calibrate", ws.handleCalibrate).Methods("POST")
+	api.HandleFunc("/reader/health", ws.handleHealth).Methods("GET")
+	api.HandleFunc("/trace", ws.handleTrace).Methods("GET")
+	api.HandleFunc("/trace", ws.handleSetTrace).Methods("POST")
+	api.HandleFunc("/trace", ws.handleClearTrace).Methods("DELETE")
+	api.HandleFunc("/trace/export", ws.handleExportTrace).Methods("GET")
+	api.HandleFunc("/keys/check", ws.handleKeyCheck).Methods("POST")
+	api.HandleFunc("/keys/check", ws.handleKeyCheckStatus).Methods("GET")
+	api.HandleFunc("/keys/check", ws.handleCancelKeyCheck).Methods("DELETE")
+	api.HandleFunc("/value/{block}", ws.handleReadValue).Methods("GET")
+	api.HandleFunc("/value/{block}", ws.handleWriteValue).Methods("POST")
+	api.HandleFunc("/value/{block}/{operation}", ws.handleValueOperation).Methods("POST")
 	api.HandleFunc("/websocket", ws.handleWebSocket)
 
 	// Web pages
@@ -100,8 +217,9 @@ func (ws *WebServer) Start() error {
 	return ws.server.ListenAndServe()
 }
 
-// Stop stops the web server
+// Stop stops the web server and a running key check
 func (ws *WebServer) Stop() error {
+	ws.keyCheck.stop()
 	if ws.server != nil {
 		const serverTimeout = 5 * time.Second
 		ctx, cancel := context.WithTimeout(context.Background(), serverTimeout)
@@ -239,6 +357,7 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
             <h3>Card Information</h3>
             <p><strong>UID:</strong> <span id="cardUID"></span></p>
             <p><strong>Type:</strong> <span id="cardType"></span></p>
+            <p><strong>ATQA / SAK:</strong> <span id="cardATQA"></span> / <span id="cardSAK"></span></p>
             <p><strong>Size:</strong> <span id="cardSize"></span> bytes</p>
             <p><strong>Blocks:</strong> <span id="cardBlocks"></span></p>
         </div>
@@ -251,6 +370,7 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
                         <th>Block</th>
                         <th>Data (Hex)</th>
                         <th>Data (ASCII)</th>
+                        <th>Access</th>
                         <th>Actions</th>
                     </tr>
                 </thead>
@@ -265,11 +385,31 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
                 <label>Block: <input type="number" id="writeBlock" min="0" max="63" value="1"></label>
             </div>
             <div style="margin: 10px 0;">
-                <label>Data (32 hex chars): <input type="text" id="writeData" class="hex-input" maxlength="32" placeholder="00112233445566778899AABBCCDDEEFF"></label>
+                <label>Data (<span id="writeHexChars">32</span> hex chars): <input type="text" id="writeData" class="hex-input" maxlength="32" placeholder="00112233445566778899AABBCCDDEEFF"></label>
             </div>
             <button id="writeBtn" class="button">Write Block</button>
         </div>
 
+        <div id="keyCheckSection" style="display:none;">
+            <h3>Key Dictionary Check</h3>
+            <div>
+                <textarea id="keyDictionary" class="hex-input" rows="6" placeholder="FFFFFFFFFFFF&#10;A0A1A2A3A4A5"></textarea>
+            </div>
+            <button id="keyCheckBtn" class="button">Check Keys</button>
+            <span id="keyCheckProgress"></span>
+            <table class="data-table" id="keyTable" style="display:none;">
+                <thead>
+                    <tr>
+                        <th>Sector</th>
+                        <th>Key A</th>
+                        <th>Key B</th>
+                    </tr>
+                </thead>
+                <tbody id="keyTableBody">
+                </tbody>
+            </table>
+        </div>
+
         <div id="messages"></div>
     </div>
 
@@ -316,6 +456,8 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
                 currentCard = null;
                 hideCardInfo();
                 showMessage('Card removed', 'success');
+            } else if (data.type === 'key_check_progress') {
+                showKeyCheckProgress(data.progress);
             }
         }
 
@@ -334,10 +476,16 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
         function displayCardInfo(card) {
             document.getElementById('cardUID').textContent = card.uid;
             document.getElementById('cardType').textContent = card.type;
+            document.getElementById('cardATQA').textContent = card.atqa;
+            document.getElementById('cardSAK').textContent = card.sak;
             document.getElementById('cardSize').textContent = card.size;
             document.getElementById('cardBlocks').textContent = card.blocks;
+            document.getElementById('writeBlock').max = Math.max(card.blocks - 1, 0);
+            document.getElementById('writeHexChars').textContent = card.block_size * 2;
+            document.getElementById('writeData').maxLength = card.block_size * 2;
             document.getElementById('cardInfo').style.display = 'block';
             document.getElementById('writeSection').style.display = 'block';
+            document.getElementById('keyCheckSection').style.display = card.block_size === 16 ? 'block' : 'none';
             document.getElementById('readBtn').disabled = false;
         }
 
@@ -345,13 +493,17 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
             document.getElementById('cardInfo').style.display = 'none';
             document.getElementById('dataSection').style.display = 'none';
             document.getElementById('writeSection').style.display = 'none';
+            document.getElementById('keyCheckSection').style.display = 'none';
             document.getElementById('readBtn').disabled = true;
         }
 
-        function displayCardData(data) {
+        function displayCardData(data, sectors) {
             const tbody = document.getElementById('dataTableBody');
             tbody.innerHTML = '';
 
+            const access = {};
+            Object.values(sectors || {}).forEach(sector => Object.assign(access, sector.blocks));
+
             Object.keys(data).sort((a, b) => parseInt(a) - parseInt(b)).forEach(block => {
                 const row = tbody.insertRow();
                 const hexData = data[block];
@@ -360,8 +512,9 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
                 row.insertCell(0).textContent = block;
                 row.insertCell(1).textContent = hexData;
                 row.insertCell(2).textContent = asciiData;
+                row.insertCell(3).textContent = access[block] || '';
 
-                const actionsCell = row.insertCell(3);
+                const actionsCell = row.insertCell(4);
                 const editBtn = document.createElement('button');
                 editBtn.textContent = 'Edit';
                 editBtn.className = 'button';
@@ -372,6 +525,51 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
             document.getElementById('dataSection').style.display = 'block';
         }
 
+        function showKeyCheckProgress(progress) {
+            document.getElementById('keyCheckProgress').textContent =
+                'Sector ' + progress.sector + ' of ' + progress.sectors + ', ' +
+                progress.attempts + '/' + progress.total + ' attempts, ' + progress.found + ' keys found';
+        }
+
+        function pollKeyCheck() {
+            fetch('/api/keys/check')
+                .then(response => response.json())
+                .then(data => {
+                    const status = data.data;
+                    showKeyCheckProgress(status.progress);
+                    if (status.running) {
+                        setTimeout(pollKeyCheck, 1000);
+                        return;
+                    }
+
+                    document.getElementById('keyCheckBtn').disabled = false;
+                    displayKeys(status.sectors || {});
+                    if (status.error) {
+                        showMessage('Key check failed: ' + status.error, 'error');
+                    } else {
+                        showMessage('Key check finished', 'success');
+                    }
+                })
+                .catch(error => {
+                    document.getElementById('keyCheckBtn').disabled = false;
+                    showMessage('Error checking keys: ' + error.message, 'error');
+                });
+        }
+
+        function displayKeys(sectors) {
+            const tbody = document.getElementById('keyTableBody');
+            tbody.innerHTML = '';
+
+            Object.keys(sectors).sort((a, b) => parseInt(a) - parseInt(b)).forEach(sector => {
+                const row = tbody.insertRow();
+                row.insertCell(0).textContent = sector;
+                row.insertCell(1).textContent = sectors[sector].key_a || '-';
+                row.insertCell(2).textContent = sectors[sector].key_b || '-';
+            });
+
+            document.getElementById('keyTable').style.display = 'table';
+        }
+
         function hexToAscii(hex) {
             let ascii = '';
             for (let i = 0; i < hex.length; i += 2) {
@@ -415,7 +613,7 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
                 .then(response => response.json())
                 .then(data => {
                     if (data.success) {
-                        displayCardData(data.data.data);
+                        displayCardData(data.data.data, data.data.sectors);
                         showMessage('Card data read successfully', 'success');
                     } else {
                         showMessage(data.message, 'error');
@@ -435,8 +633,9 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
                 return;
             }
 
-            if (data.length !== 32 || !/^[0-9A-Fa-f]+$/.test(data)) {
-                showMessage('Data must be exactly 32 hexadecimal characters', 'error');
+            const hexChars = currentCard.block_size * 2;
+            if (data.length !== hexChars || !/^[0-9A-Fa-f]+$/.test(data)) {
+                showMessage('Data must be exactly ' + hexChars + ' hexadecimal characters', 'error');
                 return;
             }
 
@@ -463,6 +662,31 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
             });
         });
 
+        document.getElementById('keyCheckBtn').addEventListener('click', function() {
+            fetch('/api/keys/check', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({
+                    dictionary: document.getElementById('keyDictionary').value
+                })
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.success) {
+                    document.getElementById('keyCheckBtn').disabled = true;
+                    showMessage(data.message, 'success');
+                    pollKeyCheck();
+                } else {
+                    showMessage(data.message, 'error');
+                }
+            })
+            .catch(error => {
+                showMessage('Error checking keys: ' + error.message, 'error');
+            });
+        });
+
         document.getElementById('clearBtn').addEventListener('click', function() {
             hideCardInfo();
             document.getElementById('messages').innerHTML = '';
@@ -480,7 +704,7 @@ func (ws *WebServer) handleIndex(w http.ResponseWriter, _ *http.Request) {
 }
 
 // handleScan handles card scanning requests
-func (ws *WebServer) handleScan(w http.ResponseWriter, _ *http.Request) {
+func (ws *WebServer) handleScan(w http.ResponseWriter, r *http.Request) {
 	if ws.reader == nil {
 		ws.writeJSON(w, APIResponse{
 			Success: false,
@@ -488,31 +712,90 @@ func (ws *WebServer) handleScan(w http.ResponseWriter, _ *http.Request) {
 		})
 		return
 	}
-	card, err := ws.reader.ScanForCard()
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
+	card, err := ws.reader.ScanForCardContext(r.Context())
 	if err != nil {
+		ws.writeError(w, "Failed to scan card", err)
+		return
+	}
+
+	cardData := newCardData(card)
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Card scanned successfully",
+		Data:    cardData,
+	})
+}
+
+// handleScanAll handles listing every card in the field
+func (ws *WebServer) handleScanAll(w http.ResponseWriter, r *http.Request) {
+	if ws.reader == nil {
 		ws.writeJSON(w, APIResponse{
 			Success: false,
-			Message: fmt.Sprintf("Failed to scan card: %v", err),
+			Message: "RFID reader not available",
 		})
 		return
 	}
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
+	cards, err := ws.reader.ScanForCardsContext(r.Context())
+	if err != nil {
+		ws.writeError(w, "Failed to scan cards", err)
+		return
+	}
 
-	cardData := CardData{
-		UID:    hex.EncodeToString(card.UID),
-		Type:   string(card.Type),
-		Size:   card.Size,
-		Blocks: card.Blocks,
+	cardData := make([]CardData, len(cards))
+	for i, card := range cards {
+		cardData[i] = newCardData(card)
 	}
 
 	ws.writeJSON(w, APIResponse{
 		Success: true,
-		Message: "Card scanned successfully",
+		Message: fmt.Sprintf("%d cards found", len(cards)),
 		Data:    cardData,
 	})
 }
 
+// handleSelectCard handles selecting one of several cards in the field by UID
+func (ws *WebServer) handleSelectCard(w http.ResponseWriter, r *http.Request) {
+	uid, err := hex.DecodeString(mux.Vars(r)["uid"])
+	if err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid UID",
+		})
+		return
+	}
+
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
+	card, err := ws.reader.SelectCardContext(r.Context(), uid)
+	if err != nil {
+		ws.writeError(w, "Failed to select card", err)
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Card selected successfully",
+		Data:    newCardData(card),
+	})
+}
+
 // handleRead handles reading all card data
-func (ws *WebServer) handleRead(w http.ResponseWriter, _ *http.Request) {
+func (ws *WebServer) handleRead(w http.ResponseWriter, r *http.Request) {
 	if ws.reader == nil {
 		ws.writeJSON(w, APIResponse{
 			Success: false,
@@ -527,29 +810,48 @@ func (ws *WebServer) handleRead(w http.ResponseWriter, _ *http.Request) {
 		})
 		return
 	}
-
-	data, err := ws.reader.ReadCard()
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
+	password, err := parsePassword(r.URL.Query().Get("password"))
 	if err != nil {
 		ws.writeJSON(w, APIResponse{
 			Success: false,
-			Message: fmt.Sprintf("Failed to read card: %v", err),
+			Message: err.Error(),
 		})
 		return
 	}
 
-	// Convert byte arrays to hex strings
-	hexData := make(map[string]string)
-	for block, blockData := range data {
-		hexData[strconv.Itoa(block)] = hex.EncodeToString(blockData)
-	}
+	// Presence polling must not replace the card between the dump and its description,
+	// nor halt it after PWD_AUTH
+	var cardData CardData
+	err = ws.reader.Session(r.Context(), func(s *rfid.Session) error {
+		if err := unlock(s, password); err != nil {
+			return err
+		}
 
-	card := ws.reader.GetLastCard()
-	cardData := CardData{
-		UID:    hex.EncodeToString(card.UID),
-		Type:   string(card.Type),
-		Size:   card.Size,
-		Blocks: card.Blocks,
-		Data:   hexData,
+		data, err := s.ReadCard()
+		if err != nil {
+			return err
+		}
+
+		// Convert byte arrays to hex strings
+		hexData := make(map[string]string)
+		for block, blockData := range data {
+			hexData[strconv.Itoa(block)] = hex.EncodeToString(blockData)
+		}
+
+		card := s.Card()
+		cardData = newCardData(card)
+		cardData.Data = hexData
+		if card.IsClassic() {
+			cardData.Sectors = sectorAccess(card)
+		}
+		return nil
+	})
+	if err != nil {
+		ws.writeError(w, "Failed to read card", err)
+		return
 	}
 
 	ws.writeJSON(w, APIResponse{
@@ -594,16 +896,24 @@ func (ws *WebServer) handleReadBlock(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-
-	data, err := ws.reader.ReadBlock(block)
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
+	password, err := parsePassword(r.URL.Query().Get("password"))
 	if err != nil {
 		ws.writeJSON(w, APIResponse{
 			Success: false,
-			Message: fmt.Sprintf("Failed to read block %d: %v", block, err),
+			Message: err.Error(),
 		})
 		return
 	}
 
+	data, err := ws.readBlockOrPage(r.Context(), block, password)
+	if err != nil {
+		ws.writeError(w, fmt.Sprintf("Failed to read block %d", block), err)
+		return
+	}
+
 	ws.writeJSON(w, APIResponse{
 		Success: true,
 		Message: fmt.Sprintf("Block %d read successfully", block),
@@ -622,13 +932,24 @@ func (ws *WebServer) handleWrite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ws.reader.GetLastCard() == nil {
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+	card := ws.reader.GetLastCard()
+	if card == nil {
 		ws.writeJSON(w, APIResponse{
 			Success: false,
 			Message: "No card selected",
 		})
 		return
 	}
+	if ws.rejectDuringKeyCheck(w) {
+		return
+	}
 
 	// Convert hex string to bytes
 	data, err := hex.DecodeString(req.Data)
@@ -640,28 +961,90 @@ func (ws *WebServer) handleWrite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(data) != 16 {
+	// The session checks the size again in case the card is replaced in the meantime
+	size := blockSize(card)
+	if len(data) != size {
 		ws.writeJSON(w, APIResponse{
 			Success: false,
-			Message: "Data must be exactly 16 bytes (32 hex characters)",
+			Message: fmt.Sprintf("Data must be exactly %d bytes (%d hex characters)", size, size*2),
 		})
 		return
 	}
 
-	if err := ws.reader.WriteBlock(req.Block, data); err != nil {
+	password, err := parsePassword(req.Password)
+	if err != nil {
 		ws.writeJSON(w, APIResponse{
 			Success: false,
-			Message: fmt.Sprintf("Failed to write block %d: %v", req.Block, err),
+			Message: err.Error(),
 		})
 		return
 	}
 
+	if err := ws.writeBlockOrPage(r.Context(), req.Block, data, password, req.Config); err != nil {
+		ws.writeError(w, fmt.Sprintf("Failed to write block %d", req.Block), err)
+		return
+	}
+
 	ws.writeJSON(w, APIResponse{
 		Success: true,
 		Message: fmt.Sprintf("Block %d written successfully", req.Block),
 	})
 }
 
+// readBlockOrPage reads a Classic block or, on Ultralight/NTAG cards, a single page. A
+// password unlocks protected NTAG pages in the same session as the read. The card type
+// is taken inside the session, so it is the type of the card actually read.
+func (ws *WebServer) readBlockOrPage(ctx context.Context, block int, password []byte) ([]byte, error) {
+	var data []byte
+	err := ws.reader.Session(ctx, func(s *rfid.Session) error {
+		card := s.Card()
+		if card == nil {
+			return errNoCard
+		}
+		if err := unlock(s, password); err != nil {
+			return err
+		}
+
+		if !card.IsUltralight() {
+			var err error
+			data, err = s.ReadBlock(block)
+			return err
+		}
+
+		pages, err := s.ReadPages(block)
+		if err != nil {
+			return err
+		}
+		data = pages[:rfid.UltralightPageSize]
+		return nil
+	})
+	return data, err
+}
+
+// writeBlockOrPage writes a Classic block or, on Ultralight/NTAG cards, a single page. A
+// password unlocks protected NTAG pages in the same session as the write; configuration
+// pages are only written with config set. The card type is taken inside the session, so a
+// card replaced since the request was checked cannot receive the other kind of write.
+func (ws *WebServer) writeBlockOrPage(ctx context.Context, block int, data, password []byte, config bool) error {
+	return ws.reader.Session(ctx, func(s *rfid.Session) error {
+		card := s.Card()
+		if card == nil {
+			return errNoCard
+		}
+		if err := unlock(s, password); err != nil {
+			return err
+		}
+
+		if card.IsUltralight() {
+			if config {
+				return s.WriteConfigPage(block, data)
+			}
+			return s.WritePage(block, data)
+		}
+		return s.WriteBlock(block, data)
+	})
+}
+
 // handleCardInfo handles getting current card information
 func (ws *WebServer) handleCardInfo(w http.ResponseWriter, _ *http.Request) {
 	if ws.reader == nil {
@@ -680,12 +1063,7 @@ func (ws *WebServer) handleCardInfo(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
-	cardData := CardData{
-		UID:    hex.EncodeToString(card.UID),
-		Type:   string(card.Type),
-		Size:   card.Size,
-		Blocks: card.Blocks,
-	}
+	cardData := newCardData(card)
 
 	ws.writeJSON(w, APIResponse{
 		Success: true,
@@ -693,6 +1071,22 @@ func (ws *WebServer) handleCardInfo(w http.ResponseWriter, _ *http.Request) {
 	})
 }
 
+// handleStats reports the operation and retry counters of the reader
+func (ws *WebServer) handleStats(w http.ResponseWriter, _ *http.Request) {
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Data:    ws.reader.Stats(),
+	})
+}
+
 // handleWebSocket handles WebSocket connections for real-time updates
 func (ws *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := ws.upgrader.Upgrade(w, r, nil)
@@ -711,21 +1105,49 @@ func (ws *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	defer ticker.Stop()
 
 	var lastCardPresent bool
+	lastHealth := rfid.HealthOK
 
 	for {
 		select {
 		case <-ticker.C:
+			// Without a reader there is no health or card to report
+			if ws.reader == nil {
+				continue
+			}
+
+			if health := ws.reader.Health(); health != lastHealth {
+				message := map[string]interface{}{
+					"type":   "reader_state",
+					"health": health.String(),
+				}
+
+				if err := conn.WriteJSON(message); err != nil {
+					log.Printf("WebSocket write error: %v", err)
+					return
+				}
+				lastHealth = health
+			}
+
+			// Scanning would reset the card in the middle of a key check, report progress instead
+			if ws.keyCheck.running() {
+				message := map[string]interface{}{
+					"type":     "key_check_progress",
+					"progress": ws.keyCheck.snapshot().Progress,
+				}
+
+				if err := conn.WriteJSON(message); err != nil {
+					log.Printf("WebSocket write error: %v", err)
+					return
+				}
+				continue
+			}
+
 			cardPresent := ws.reader.IsCardPresent()
 
 			if cardPresent && !lastCardPresent {
 				// Card detected
 				if card := ws.reader.GetLastCard(); card != nil {
-					cardData := CardData{
-						UID:    hex.EncodeToString(card.UID),
-						Type:   string(card.Type),
-						Size:   card.Size,
-						Blocks: card.Blocks,
-					}
+					cardData := newCardData(card)
 
 					message := map[string]interface{}{
 						"type": "card_detected",
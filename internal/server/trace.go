@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"rfid-tool-rpi/internal/rfid"
+)
+
+// TraceRequest starts or stops trace recording
+type TraceRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// TraceFrame is one recorded frame
+type TraceFrame struct {
+	Time    string `json:"time"`
+	Source  string `json:"source"`
+	Data    string `json:"data"`
+	Bits    int    `json:"bits"`
+	CRC     string `json:"crc,omitempty"`
+	Command string `json:"command,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// TraceData is the state of the trace recorder and the frames recorded so far
+type TraceData struct {
+	Enabled bool         `json:"enabled"`
+	Frames  []TraceFrame `json:"frames"`
+}
+
+// traceReader checks that a reader is available for trace requests
+func (ws *WebServer) traceReader(w http.ResponseWriter) bool {
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return false
+	}
+	return true
+}
+
+// handleTrace handles listing the recorded frames
+func (ws *WebServer) handleTrace(w http.ResponseWriter, _ *http.Request) {
+	if !ws.traceReader(w) {
+		return
+	}
+
+	trace := ws.reader.Trace()
+	data := TraceData{Enabled: ws.reader.Tracing(), Frames: make([]TraceFrame, len(trace))}
+	for i, e := range trace {
+		data.Frames[i] = TraceFrame{
+			Time:    e.Time.Format("15:04:05.000000"),
+			Source:  e.Source.String(),
+			Data:    hex.EncodeToString(e.Data),
+			Bits:    e.Bits,
+			CRC:     e.CRC.String(),
+			Command: e.Command,
+			Error:   e.Err,
+		}
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Trace retrieved",
+		Data:    data,
+	})
+}
+
+// handleSetTrace handles starting and stopping trace recording
+func (ws *WebServer) handleSetTrace(w http.ResponseWriter, r *http.Request) {
+	var req TraceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+	if !ws.traceReader(w) {
+		return
+	}
+
+	ws.reader.SetTracing(req.Enabled)
+
+	message := "Trace recording stopped"
+	if req.Enabled {
+		message = "Trace recording started"
+	}
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+// handleClearTrace handles dropping the recorded frames
+func (ws *WebServer) handleClearTrace(w http.ResponseWriter, _ *http.Request) {
+	if !ws.traceReader(w) {
+		return
+	}
+
+	ws.reader.ClearTrace()
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Trace cleared",
+	})
+}
+
+// handleExportTrace handles downloading the trace as an annotated text table
+// or, with format=proxmark, as a Proxmark3 trace file
+func (ws *WebServer) handleExportTrace(w http.ResponseWriter, r *http.Request) {
+	if !ws.traceReader(w) {
+		return
+	}
+
+	trace := ws.reader.Trace()
+	contentType, filename, write := "text/plain", "rfid.txt", rfid.Trace.WriteText
+	switch r.URL.Query().Get("format") {
+	case "", "text":
+	case "proxmark":
+		contentType, filename, write = "application/octet-stream", "rfid.trace", rfid.Trace.WriteProxmark
+	default:
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Unknown trace format",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := write(trace, w); err != nil {
+		log.Printf("Failed to export trace: %v", err)
+	}
+}
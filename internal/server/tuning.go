@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"rfid-tool-rpi/internal/rfid"
+)
+
+// TuningData holds the antenna and receiver settings
+type TuningData struct {
+	GainDB      int `json:"antenna_gain_db"`
+	RxMinLevel  int `json:"rx_min_level"`
+	RxCollLevel int `json:"rx_coll_level"`
+	CWGsN       int `json:"cw_gsn"`
+	CWGsP       int `json:"cw_gsp"`
+}
+
+// newTuningData converts reader tuning to its JSON form
+func newTuningData(t rfid.Tuning) TuningData {
+	return TuningData{
+		GainDB:      t.GainDB,
+		RxMinLevel:  t.RxMinLevel,
+		RxCollLevel: t.RxCollLevel,
+		CWGsN:       t.CWGsN,
+		CWGsP:       t.CWGsP,
+	}
+}
+
+// handleTuning handles reading the antenna and receiver settings
+func (ws *WebServer) handleTuning(w http.ResponseWriter, _ *http.Request) {
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Tuning retrieved",
+		Data:    newTuningData(ws.reader.Tuning()),
+	})
+}
+
+// handleSetTuning handles changing the antenna and receiver settings. Fields left out
+// of the request keep their current value.
+func (ws *WebServer) handleSetTuning(w http.ResponseWriter, r *http.Request) {
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return
+	}
+
+	req := newTuningData(ws.reader.Tuning())
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	tuning := rfid.Tuning{
+		GainDB:      req.GainDB,
+		RxMinLevel:  req.RxMinLevel,
+		RxCollLevel: req.RxCollLevel,
+		CWGsN:       req.CWGsN,
+		CWGsP:       req.CWGsP,
+	}
+	if err := ws.reader.SetTuning(tuning); err != nil {
+		ws.writeError(w, "Failed to change tuning", err)
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: "Tuning changed",
+		Data:    newTuningData(tuning),
+	})
+}
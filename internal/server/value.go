@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"rfid-tool-rpi/internal/rfid"
+
+	"github.com/gorilla/mux"
+)
+
+// ValueData represents a MIFARE Classic value block
+type ValueData struct {
+	Block   int   `json:"block"`
+	Value   int32 `json:"value"`
+	Address byte  `json:"address"`
+}
+
+// ValueRequest formats a value block or changes its value.
+// Increment, decrement and restore are transferred to TransferTo, which defaults to the block itself.
+type ValueRequest struct {
+	TransferTo *int   `json:"transfer_to,omitempty"`
+	Value      int32  `json:"value"`
+	Amount     uint32 `json:"amount"`
+	Address    byte   `json:"address"`
+}
+
+// valueBlockFromRequest parses the block number and checks that a Classic card is selected
+func (ws *WebServer) valueBlockFromRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	block, err := strconv.Atoi(mux.Vars(r)["block"])
+	if err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid block number",
+		})
+		return 0, false
+	}
+
+	if ws.reader == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "RFID reader not available",
+		})
+		return 0, false
+	}
+	if ws.reader.GetLastCard() == nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "No card selected",
+		})
+		return 0, false
+	}
+	if ws.rejectDuringKeyCheck(w) {
+		return 0, false
+	}
+
+	return block, true
+}
+
+// handleReadValue handles reading a value block
+func (ws *WebServer) handleReadValue(w http.ResponseWriter, r *http.Request) {
+	block, ok := ws.valueBlockFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	value, addr, err := ws.reader.ReadValueContext(r.Context(), block)
+	if err != nil {
+		ws.writeError(w, fmt.Sprintf("Failed to read value block %d", block), err)
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Value block %d read successfully", block),
+		Data:    ValueData{Block: block, Value: value, Address: addr},
+	})
+}
+
+// handleWriteValue handles formatting a block as a value block
+func (ws *WebServer) handleWriteValue(w http.ResponseWriter, r *http.Request) {
+	var req ValueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	block, ok := ws.valueBlockFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := ws.reader.WriteValueContext(r.Context(), block, req.Value, req.Address); err != nil {
+		ws.writeError(w, fmt.Sprintf("Failed to write value block %d", block), err)
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Value block %d written successfully", block),
+		Data:    ValueData{Block: block, Value: req.Value, Address: req.Address},
+	})
+}
+
+// handleValueOperation handles increment, decrement and restore on a value block. The
+// result is transferred to TransferTo; restore with TransferTo copies a value block.
+func (ws *WebServer) handleValueOperation(w http.ResponseWriter, r *http.Request) {
+	var req ValueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	block, ok := ws.valueBlockFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	target := block
+	if req.TransferTo != nil {
+		target = *req.TransferTo
+	}
+
+	operation := mux.Vars(r)["operation"]
+	var valueOperation func(s *rfid.Session) error
+	switch operation {
+	case "increment":
+		valueOperation = func(s *rfid.Session) error { return s.Increment(block, req.Amount) }
+	case "decrement":
+		valueOperation = func(s *rfid.Session) error { return s.Decrement(block, req.Amount) }
+	case "restore":
+		valueOperation = func(s *rfid.Session) error { return s.Restore(block) }
+	default:
+		ws.writeJSON(w, APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Unknown value operation %q", operation),
+		})
+		return
+	}
+
+	// The operation, its transfer and the read back must not be interleaved with presence
+	// polling. The transfer's ACK confirms that the card took the operation.
+	var value int32
+	var addr byte
+	var readErr error
+	err := ws.reader.Session(r.Context(), func(s *rfid.Session) error {
+		if err := valueOperation(s); err != nil {
+			return err
+		}
+		if err := s.Transfer(target); err != nil {
+			return err
+		}
+		value, addr, readErr = s.ReadValue(target)
+		return nil
+	})
+	if err != nil {
+		ws.writeError(w, fmt.Sprintf("Failed to %s value block %d", operation, block), err)
+		return
+	}
+	if readErr != nil {
+		ws.writeError(w, fmt.Sprintf("Failed to read value block %d", target), readErr)
+		return
+	}
+
+	ws.writeJSON(w, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Value block %d: %s successful", block, operation),
+		Data:    ValueData{Block: target, Value: value, Address: addr},
+	})
+}